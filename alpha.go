@@ -0,0 +1,81 @@
+package asevre
+
+import (
+	"image"
+	"image/draw"
+)
+
+// AlphaMode is how the decoded images store their alpha.
+type AlphaMode int
+
+const (
+	AlphaDefault       AlphaMode = iota // Cels and tiles are *image.NRGBA, composited frames *image.RGBA
+	AlphaStraight                       // Every image is *image.NRGBA (straight alpha)
+	AlphaPremultiplied                  // Every image is *image.RGBA (premultiplied alpha)
+)
+
+// convertAlpha stores every decoded image (frames, cels, tiles) with the
+// alpha of the mode
+func (f *ASEFile) convertAlpha(mode AlphaMode) {
+	if mode == AlphaDefault {
+		return
+	}
+
+	// Linked cels point to one image: map it to one converted copy, so they
+	// still share it after the conversion
+	converted := map[image.Image]image.Image{}
+	convert := func(img image.Image) image.Image {
+		if img == nil {
+			return nil
+		}
+		if c, exists := converted[img]; exists {
+			return c
+		}
+		c := withAlpha(img, mode)
+		converted[img] = c
+		return c
+	}
+
+	for i, img := range f.Images {
+		f.Images[i] = convert(img)
+	}
+	for i, tile := range f.Tileset.Tiles {
+		f.Tileset.Tiles[i] = convert(tile)
+	}
+	for i := range f.Tilemaps {
+		f.Tileset.setTileImages(&f.Tilemaps[i])
+	}
+	for _, cels := range f.frameCels {
+		for i := range cels {
+			cels[i].image = convert(cels[i].image)
+			if cels[i].tilemap != nil {
+				f.Tileset.setTileImages(cels[i].tilemap)
+			}
+		}
+	}
+	f.Tileset.resetFlips()
+	f.refreshStates()
+}
+
+// withAlpha returns the image as *image.NRGBA or *image.RGBA for the mode,
+// the image itself when it already is
+func withAlpha(img image.Image, mode AlphaMode) image.Image {
+	b := img.Bounds()
+	var dst draw.Image
+	switch mode {
+	case AlphaStraight:
+		if _, ok := img.(*image.NRGBA); ok {
+			return img
+		}
+		dst = image.NewNRGBA(b)
+	case AlphaPremultiplied:
+		if _, ok := img.(*image.RGBA); ok {
+			return img
+		}
+		dst = image.NewRGBA(b)
+	default:
+		return img
+	}
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,362 @@
+package asevre
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"slices"
+	"time"
+)
+
+// DefaultTagName is the name of the tag playing every frame of files without tags.
+const DefaultTagName = "default"
+
+// Advance moves the animation to its next frame following its direction.
+// Forward animations wrap around to LoopStart after the last frame, so the
+// frames before it are played only once; ping-pong animations bounce between
+// LoopStart and the last frame. Reverse animations ignore LoopStart.
+//
+// Animations with a Repeat count hold their final frame once they have been
+// played that many times (every direction of a ping-pong counts as one time).
+//
+// Advance sets LastChange to the current time; Update moves through the
+// frames the same way without reading the wall clock.
+func (a *Animation) Advance() {
+	if a.step() {
+		a.LastChange = time.Now()
+	}
+}
+
+// step moves the animation to its next frame, see Advance. It returns false
+// when the animation is finished or has just completed its last pass.
+func (a *Animation) step() bool {
+	if a.TotalFrames == 0 || a.finished {
+		return false
+	}
+
+	previous, passes := a.Index, a.passes
+	defer func() {
+		if a.Index != previous && a.OnFrameChanged != nil {
+			a.OnFrameChanged(a.Index)
+		}
+		// Starting over plays the frame again, even a single frame
+		if a.Index != previous || (a.passes != passes && !a.finished) {
+			a.fireTriggers()
+		}
+	}()
+
+	last := a.TotalFrames - 1
+	switch a.Direction {
+	case Reverse:
+		if a.Index <= 0 {
+			if a.completePass() {
+				return false
+			}
+			a.Index = last
+		} else {
+			a.Index--
+		}
+	case PingPong, PingPongReverse:
+		low := 0
+		if a.Direction == PingPong {
+			low = min(a.LoopStart, last)
+		}
+		// Turn around at both ends
+		if (a.backward && a.Index <= low) || (!a.backward && a.Index >= last) {
+			if a.completePass() {
+				return false
+			}
+			a.backward = !a.backward
+		}
+		if low < last {
+			if a.backward {
+				a.Index--
+			} else {
+				a.Index++
+			}
+		}
+	default:
+		if a.Index >= last {
+			if a.completePass() {
+				return false
+			}
+			a.Index = a.LoopStart
+		} else {
+			a.Index++
+		}
+	}
+	return true
+}
+
+// completePass counts a played pass of the frames and checks if it was the last one
+func (a *Animation) completePass() bool {
+	a.passes++
+	if a.Repeat != Infinite && a.passes >= int(a.Repeat) {
+		a.finished = true
+		if a.OnComplete != nil {
+			a.OnComplete()
+		}
+		return true
+	}
+	if a.OnLoop != nil {
+		a.OnLoop()
+	}
+	return false
+}
+
+// IsFinished checks if the animation has been played Repeat times and holds its final frame.
+// Animations repeated infinitely never finish.
+func (a *Animation) IsFinished() bool {
+	return a.finished
+}
+
+// Play starts (or resumes) advancing the animation on Update. The triggers of
+// the current frame are fired unless they were already.
+func (a *Animation) Play() {
+	a.playing = true
+	if !a.fired {
+		a.fireTriggers()
+	}
+}
+
+// IsPlaying checks if the animation advances on Update
+func (a *Animation) IsPlaying() bool {
+	return a.playing
+}
+
+// Pause stops advancing the animation on Update, holding the current frame
+// and the time already spent in it (hit-stop, cutscenes).
+func (a *Animation) Pause() {
+	a.playing = false
+}
+
+// Resume continues a paused animation from where it stopped, see Play.
+func (a *Animation) Resume() {
+	a.playing = true
+}
+
+// Update advances the animation by dt, moving through as many frames as their
+// durations allow. It does nothing until Play is called.
+//
+// Update only depends on the durations it is given, never on the wall clock,
+// so fixed-timestep loops, pauses and replays play the same frames every time.
+// LastChange is left untouched.
+func (a *Animation) Update(dt time.Duration) {
+	if !a.playing || a.TotalFrames == 0 {
+		return
+	}
+
+	a.elapsed += time.Duration(float64(dt) * a.Speed())
+	for !a.finished {
+		duration := a.frameDuration()
+		if a.elapsed < duration {
+			return
+		}
+		a.elapsed -= duration
+		a.step()
+		// Frames without a duration would never let the loop end
+		if duration <= 0 {
+			a.elapsed = 0
+			return
+		}
+	}
+	a.elapsed = 0
+}
+
+// SetSpeed sets how fast Update plays the frames: 2 plays them twice as fast,
+// 0.5 at half speed. Factors that aren't positive are ignored.
+func (a *Animation) SetSpeed(factor float64) {
+	if factor > 0 {
+		a.speed = factor
+	}
+}
+
+// Speed returns the playback speed factor, 1 unless changed with SetSpeed
+func (a *Animation) Speed() float64 {
+	if a.speed == 0 {
+		return 1
+	}
+	return a.speed
+}
+
+// SetFrameDuration overrides the duration of a frame of the animation,
+// relative to the first frame of the tag. The durations of the file and of
+// the other copies of the tag are left untouched.
+func (a *Animation) SetFrameDuration(frame int, d time.Duration) {
+	if frame < 0 || frame >= len(a.Duration) {
+		return
+	}
+	a.Duration = slices.Clone(a.Duration)
+	a.Duration[frame] = d
+}
+
+// SetDuration overrides the duration of every frame of the animation, see SetFrameDuration.
+func (a *Animation) SetDuration(d time.Duration) {
+	a.Duration = slices.Clone(a.Duration)
+	for i := range a.Duration {
+		a.Duration[i] = d
+	}
+}
+
+// CurrentFrame returns the index of the current frame, relative to the first frame of the tag.
+func (a *Animation) CurrentFrame() int {
+	return a.Index
+}
+
+// SeekFrame jumps to a frame, relative to the first frame of the tag, and
+// starts it over. Frames out of the tag are clamped to it. A finished
+// animation plays again from there; the passes already played still count
+// towards Repeat. OnFrameChanged isn't called, the triggers of the frame are
+// fired.
+func (a *Animation) SeekFrame(frame int) {
+	if a.TotalFrames == 0 {
+		return
+	}
+	a.Index = min(max(frame, 0), a.TotalFrames-1)
+	a.elapsed = 0
+	a.finished = false
+	a.fired = false
+	a.fireTriggers()
+}
+
+// Progress returns how much of the frames of the tag has been played in the
+// current pass, from 0 at the start of the first frame played to 1 at the end
+// of the last one, following the direction. Frames without a duration count
+// as whole frames. Finished animations return 1.
+func (a *Animation) Progress() float64 {
+	if a.finished {
+		return 1
+	}
+	if a.TotalFrames == 0 {
+		return 0
+	}
+
+	var played, total time.Duration
+	for i := 0; i < a.TotalFrames; i++ {
+		var d time.Duration
+		if i < len(a.Duration) {
+			d = a.Duration[i]
+		}
+		total += d
+		if (a.backward && i > a.Index) || (!a.backward && i < a.Index) {
+			played += d
+		}
+	}
+	if total <= 0 {
+		done := a.Index
+		if a.backward {
+			done = a.TotalFrames - 1 - a.Index
+		}
+		return float64(done) / float64(a.TotalFrames)
+	}
+	return min(float64(played+a.elapsed)/float64(total), 1)
+}
+
+// Reset moves the animation back to its first frame: the last one for
+// reverse and ping-pong reverse animations, and fires its triggers.
+func (a *Animation) Reset() {
+	a.rewind()
+	a.LastChange = time.Now()
+	a.fireTriggers()
+}
+
+// Elapsed returns the time spent by Update in the current frame
+func (a *Animation) Elapsed() time.Duration {
+	return a.elapsed
+}
+
+// rewind moves the animation back to its first frame without reading the wall clock
+func (a *Animation) rewind() {
+	a.Index = 0
+	a.backward = false
+	if a.Direction == Reverse || a.Direction == PingPongReverse {
+		a.Index = max(a.TotalFrames-1, 0)
+		a.backward = true
+	}
+	a.elapsed = 0
+	a.passes = 0
+	a.finished = false
+	a.fired = false
+}
+
+// frameDuration returns how long the current frame is displayed
+func (a *Animation) frameDuration() time.Duration {
+	if a.Index < len(a.Duration) {
+		return a.Duration[a.Index]
+	}
+	return 0
+}
+
+// CurrentFrame returns the image of the current frame of the tag animation.
+func (t *ASETag) CurrentFrame() image.Image {
+	if len(t.Frames) == 0 {
+		return nil
+	}
+	if !t.HasAnimations {
+		return t.Frames[0]
+	}
+	frame := t.Animation.CurrentFrame()
+	if frame < 0 || frame >= len(t.Frames) {
+		return nil
+	}
+	return t.Frames[frame]
+}
+
+// Validate checks the tag starts before it ends and its frames are among the
+// frames of a file of the given number of frames. The error wraps ErrTagRange.
+func (t ASETag) Validate(frames int) error {
+	if t.FromFrame < 0 || t.FromFrame > t.ToFrame || t.ToFrame >= frames {
+		return fmt.Errorf("%w: tag %q: frames %d to %d out of %d", ErrTagRange, t.Name, t.FromFrame, t.ToFrame, frames)
+	}
+	return nil
+}
+
+// Tag returns the first tag with the given name, false if there is none. The
+// tag points into State, so its animation can be played in place. State keeps
+// the tags in the order of the file.
+func (f *ASEFile) Tag(name string) (*ASETag, bool) {
+	for i := range f.State {
+		if f.State[i].Name == name {
+			return &f.State[i], true
+		}
+	}
+	return nil, false
+}
+
+// Tags returns the tags by name, the first one for names used more than once.
+// The map points into State: it must be built again after the tags change.
+func (f *ASEFile) Tags() map[string]*ASETag {
+	tags := make(map[string]*ASETag, len(f.State))
+	for i := range f.State {
+		if _, exists := tags[f.State[i].Name]; !exists {
+			tags[f.State[i].Name] = &f.State[i]
+		}
+	}
+	return tags
+}
+
+// addDefaultTag gives a file without tags a tag playing every frame forward
+// with their durations, so untagged sprites can be animated like tagged ones.
+// The tag isn't written back by EncodeAseprite.
+func (f *ASEFile) addDefaultTag() {
+	frames := f.frameCount()
+	if len(f.State) > 0 || frames == 0 {
+		return
+	}
+
+	tag := ASETag{
+		Name:          DefaultTagName,
+		FromFrame:     0,
+		ToFrame:       frames - 1,
+		Direction:     Forward,
+		Color:         color.NRGBA{A: 255},
+		FrameDuration: [][]time.Duration{{}},
+		synthesized:   true,
+	}
+	if frames > 1 {
+		tag.HasAnimations = true
+		tag.Animation = Animation{TotalFrames: frames, LastChange: time.Now()}
+	}
+	f.State = []ASETag{tag}
+	f.refreshStates()
+}
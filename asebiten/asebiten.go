@@ -0,0 +1,97 @@
+// Package asebiten converts the images decoded by asevre to ebiten images.
+// The asevre package itself doesn't depend on ebiten, so tools and tests can
+// read .aseprite files without a GPU.
+package asebiten
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/retroblast-engine/asevre"
+)
+
+// Sprites holds the ebiten images of a sprite and the one being drawn.
+type Sprites struct {
+	Current *ebiten.Image
+	All     []*ebiten.Image
+}
+
+// NewSprites creates the ebiten images of every frame of the file.
+func NewSprites(file asevre.ASEFile) Sprites {
+	sprites := Sprites{All: NewImages(file.Images)}
+	if len(sprites.All) > 0 {
+		sprites.Current = sprites.All[0]
+	}
+	return sprites
+}
+
+// NewImages creates an ebiten image from every image. Nil images stay nil, and
+// ebiten images (like the frames rewritten by NewAtlas) are used as they are.
+func NewImages(images []image.Image) []*ebiten.Image {
+	ebitenImages := make([]*ebiten.Image, len(images))
+	for i, img := range images {
+		if img != nil {
+			ebitenImages[i] = toEbitenImage(img)
+		}
+	}
+	return ebitenImages
+}
+
+// toEbitenImage returns img when it is an ebiten image already, so sub-images
+// of an atlas keep sharing its texture, or a new ebiten image with its pixels
+func toEbitenImage(img image.Image) *ebiten.Image {
+	if ebitenImage, ok := img.(*ebiten.Image); ok {
+		return ebitenImage
+	}
+	return ebiten.NewImageFromImage(img)
+}
+
+// Frames creates the ebiten images of the frames of a tag.
+func Frames(tag asevre.ASETag) []*ebiten.Image {
+	return NewImages(tag.Frames)
+}
+
+// Tiles creates the ebiten images of the tiles of a tileset.
+func Tiles(tileset asevre.ASETileset) []*ebiten.Image {
+	return NewImages(tileset.Tiles)
+}
+
+// TileGeoM returns the transformation that draws the unflipped tileset image
+// of a tile as it appears in the tilemap, with its flips applied. The result
+// covers the same width x height cell; concatenate the position of the tile
+// after it:
+//
+//	op := &ebiten.DrawImageOptions{GeoM: asebiten.TileGeoM(tile, w, h)}
+//	op.GeoM.Translate(x, y)
+//	screen.DrawImage(tiles[tile.ID], op)
+func TileGeoM(tile asevre.Tile, width, height int) ebiten.GeoM {
+	var m ebiten.GeoM
+	w, h := float64(width), float64(height)
+	if tile.DiagonalFlip {
+		// Swap the x and y axes
+		m.SetElement(0, 0, 0)
+		m.SetElement(0, 1, 1)
+		m.SetElement(1, 0, 1)
+		m.SetElement(1, 1, 0)
+		w, h = h, w
+	}
+	if tile.XFlip {
+		m.Scale(-1, 1)
+		m.Translate(w, 0)
+	}
+	if tile.YFlip {
+		m.Scale(1, -1)
+		m.Translate(0, h)
+	}
+	return m
+}
+
+// AspectGeoM returns the transformation that draws the images of a file with
+// its pixel ratio, so 2:1 sprites are drawn twice as wide. Concatenate the
+// rest of the transformation after it.
+func AspectGeoM(header asevre.Header) ebiten.GeoM {
+	var m ebiten.GeoM
+	x, y := header.PixelAspect()
+	m.Scale(float64(x), float64(y))
+	return m
+}
@@ -0,0 +1,86 @@
+package asebiten
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/retroblast-engine/asevre"
+	"github.com/retroblast-engine/asevre/export"
+)
+
+// Atlas holds the frames of several files in a single ebiten image, so
+// drawing any of them doesn't switch textures.
+type Atlas struct {
+	Image  *ebiten.Image
+	Frames [][]*ebiten.Image // Sub-images of the frames of every file, in the order of the files
+}
+
+// NewAtlas packs the frames of the files into one image and rewrites the
+// Frames of their tags to sub-images of it. The frames are a pixel apart so
+// filtering doesn't bleed between them.
+func NewAtlas(files ...*asevre.ASEFile) *Atlas {
+	images := make([][]image.Image, len(files))
+	for i, file := range files {
+		images[i] = file.Images
+	}
+	atlas := packFrames(images)
+
+	for i, file := range files {
+		frames := atlas.Frames[i]
+		for k := range file.State {
+			tag := &file.State[k]
+			for f := range tag.Frames {
+				if frame := tag.FromFrame + f; frame < len(frames) && frames[frame] != nil {
+					tag.Frames[f] = frames[frame]
+				}
+			}
+		}
+	}
+	return atlas
+}
+
+// NewAtlasSprites creates the images of every frame of the file like
+// NewSprites, as sub-images of a single texture. The file is left untouched.
+func NewAtlasSprites(file asevre.ASEFile) Sprites {
+	atlas := packFrames([][]image.Image{file.Images})
+	sprites := Sprites{All: atlas.Frames[0]}
+	if len(sprites.All) > 0 {
+		sprites.Current = sprites.All[0]
+	}
+	return sprites
+}
+
+// TagFrames returns the sub-images of the frames of a tag of the file-th file,
+// without creating any texture.
+func (a *Atlas) TagFrames(file int, tag asevre.ASETag) []*ebiten.Image {
+	frames := a.Frames[file]
+	if tag.FromFrame < 0 || tag.ToFrame >= len(frames) || tag.FromFrame > tag.ToFrame {
+		return nil
+	}
+	return frames[tag.FromFrame : tag.ToFrame+1]
+}
+
+// packFrames packs the frames of every file into one image
+func packFrames(images [][]image.Image) *Atlas {
+	var all []image.Image
+	for _, frames := range images {
+		all = append(all, frames...)
+	}
+	sheet := export.NewSheet(all, export.SheetOptions{Layout: export.LayoutPacked, Padding: 1})
+
+	atlas := &Atlas{
+		Image:  ebiten.NewImageFromImage(sheet.Image),
+		Frames: make([][]*ebiten.Image, len(images)),
+	}
+	rects := sheet.Rects
+	for i, frames := range images {
+		atlas.Frames[i] = make([]*ebiten.Image, len(frames))
+		for j, img := range frames {
+			if img != nil {
+				atlas.Frames[i][j] = atlas.Image.SubImage(rects[j]).(*ebiten.Image)
+			}
+		}
+		rects = rects[len(frames):]
+	}
+	return atlas
+}
@@ -0,0 +1,142 @@
+package asebiten
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/retroblast-engine/asevre"
+	"github.com/retroblast-engine/asevre/export"
+)
+
+// batchTiles is the number of tiles drawn by a DrawTriangles call: their
+// vertices must be reachable with uint16 indices
+const batchTiles = (1 << 16) / 4
+
+// TilemapBatch draws a whole tilemap with a single DrawTriangles call, taking
+// the tiles from one texture holding the tileset, instead of a DrawImage call
+// per tile. Maps of more than 16384 tiles take a call every 16384 tiles.
+type TilemapBatch struct {
+	Image *ebiten.Image // Tileset texture
+	X, Y  int           // Position of the cel in the canvas, in pixels
+
+	vertices []ebiten.Vertex // Corners of the tiles, relative to the tilemap origin
+	indices  []uint16        // Triangles of up to batchTiles tiles
+	drawn    []ebiten.Vertex // Vertices moved by the last Draw
+}
+
+// NewTilemapBatch creates the batched renderer of a tilemap, packing the tiles
+// of the tileset into a new texture.
+func NewTilemapBatch(tilemap asevre.ASETilemap, tileset asevre.ASETileset) *TilemapBatch {
+	texture, rects := tilesetTexture(tileset)
+	return newTilemapBatch(tilemap, texture, rects, tileset.TileWidth, tileset.TileHeight)
+}
+
+// TilemapBatches creates the batched renderers of the tilemap cels of a frame,
+// bottom layer first, placed where they are in the canvas. They share the
+// tileset texture.
+func TilemapBatches(file asevre.ASEFile, frame int) []*TilemapBatch {
+	cels := file.TilemapCels(frame)
+	if len(cels) == 0 {
+		return nil
+	}
+	texture, rects := tilesetTexture(file.Tileset)
+
+	batches := make([]*TilemapBatch, len(cels))
+	for i, cel := range cels {
+		batches[i] = newTilemapBatch(cel.Tilemap, texture, rects, file.Tileset.TileWidth, file.Tileset.TileHeight)
+		batches[i].X, batches[i].Y = cel.X, cel.Y
+	}
+	return batches
+}
+
+// tilesetTexture packs the tiles of the tileset into one image, a pixel apart
+// so filtering doesn't bleed between them, with the place of every tile
+func tilesetTexture(tileset asevre.ASETileset) (*ebiten.Image, []image.Rectangle) {
+	sheet := export.NewSheet(tileset.Tiles, export.SheetOptions{Layout: export.LayoutGrid, Padding: 1})
+	return ebiten.NewImageFromImage(sheet.Image), sheet.Rects
+}
+
+// newTilemapBatch builds the vertices of the tiles of the tilemap, flipped as
+// authored, from their place in the texture
+func newTilemapBatch(tilemap asevre.ASETilemap, texture *ebiten.Image, rects []image.Rectangle, tileWidth, tileHeight int) *TilemapBatch {
+	b := &TilemapBatch{Image: texture}
+	for row, tiles := range tilemap.Tiles {
+		for col, tile := range tiles {
+			// Tile 0 is the empty tile
+			if tile.ID <= 0 || tile.ID >= len(rects) || rects[tile.ID].Empty() {
+				continue
+			}
+			src := rects[tile.ID]
+			x, y := float32(col*tileWidth), float32(row*tileHeight)
+			for _, corner := range [4][2]float32{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				// Undo the flips of TileGeoM to find the corner of the tile drawn there
+				u, v := corner[0], corner[1]
+				if tile.YFlip {
+					v = 1 - v
+				}
+				if tile.XFlip {
+					u = 1 - u
+				}
+				if tile.DiagonalFlip {
+					u, v = v, u
+				}
+				b.vertices = append(b.vertices, ebiten.Vertex{
+					DstX:   x + corner[0]*float32(tileWidth),
+					DstY:   y + corner[1]*float32(tileHeight),
+					SrcX:   float32(src.Min.X) + u*float32(src.Dx()),
+					SrcY:   float32(src.Min.Y) + v*float32(src.Dy()),
+					ColorR: 1,
+					ColorG: 1,
+					ColorB: 1,
+					ColorA: 1,
+				})
+			}
+		}
+	}
+
+	// Every batch reuses the indices of the first one
+	quads := min(len(b.vertices)/4, batchTiles)
+	b.indices = make([]uint16, 0, quads*6)
+	for i := 0; i < quads; i++ {
+		first := uint16(i * 4)
+		b.indices = append(b.indices, first, first+1, first+2, first+1, first+3, first+2)
+	}
+	b.drawn = make([]ebiten.Vertex, len(b.vertices))
+	return b
+}
+
+// Tiles returns the number of tiles drawn, empty tiles left out.
+func (b *TilemapBatch) Tiles() int {
+	return len(b.vertices) / 4
+}
+
+// Draw draws every tile at the position of the cel. The GeoM of opts (e.g. a
+// camera) applies after the position, its ColorScale, Blend and Filter to
+// every tile. opts may be nil.
+func (b *TilemapBatch) Draw(dst *ebiten.Image, opts *ebiten.DrawImageOptions) {
+	if len(b.vertices) == 0 {
+		return
+	}
+
+	var geoM ebiten.GeoM
+	geoM.Translate(float64(b.X), float64(b.Y))
+	triangles := &ebiten.DrawTrianglesOptions{}
+	r, g, bl, a := float32(1), float32(1), float32(1), float32(1)
+	if opts != nil {
+		geoM.Concat(opts.GeoM)
+		r, g, bl, a = opts.ColorScale.R(), opts.ColorScale.G(), opts.ColorScale.B(), opts.ColorScale.A()
+		triangles.Blend = opts.Blend
+		triangles.Filter = opts.Filter
+	}
+
+	for i, vertex := range b.vertices {
+		x, y := geoM.Apply(float64(vertex.DstX), float64(vertex.DstY))
+		vertex.DstX, vertex.DstY = float32(x), float32(y)
+		vertex.ColorR, vertex.ColorG, vertex.ColorB, vertex.ColorA = r, g, bl, a
+		b.drawn[i] = vertex
+	}
+	for start := 0; start < len(b.drawn); start += batchTiles * 4 {
+		vertices := b.drawn[start:min(start+batchTiles*4, len(b.drawn))]
+		dst.DrawTriangles(vertices, b.indices[:len(vertices)/4*6], b.Image, triangles)
+	}
+}
@@ -0,0 +1,40 @@
+package asebiten
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/retroblast-engine/asevre"
+)
+
+// Controller is an asevre.AnimationController drawing with ebiten images.
+type Controller struct {
+	*asevre.AnimationController
+
+	frames map[*asevre.ASETag]*Images
+}
+
+// NewController creates a controller with every tag of the file as a state.
+func NewController(file asevre.ASEFile) *Controller {
+	return &Controller{
+		AnimationController: asevre.NewAnimationController(file),
+		frames:              map[*asevre.ASETag]*Images{},
+	}
+}
+
+// Image returns the ebiten image of the current frame of the current state,
+// creating it on first use.
+func (c *Controller) Image() *ebiten.Image {
+	tag := c.Current()
+	if tag == nil || len(tag.Frames) == 0 {
+		return nil
+	}
+	frames, exists := c.frames[tag]
+	if !exists {
+		frames = LazyFrames(*tag)
+		c.frames[tag] = frames
+	}
+	index := 0
+	if tag.HasAnimations {
+		index = tag.Animation.CurrentFrame()
+	}
+	return frames.At(index)
+}
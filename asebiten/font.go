@@ -0,0 +1,45 @@
+package asebiten
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/retroblast-engine/asevre"
+)
+
+// Font draws texts with a sprite font, the glyphs converted to ebiten images.
+type Font struct {
+	Font   *asevre.SpriteFont
+	glyphs map[rune]*ebiten.Image
+}
+
+// NewFont creates the ebiten images of the glyphs of a sprite font.
+func NewFont(font *asevre.SpriteFont) *Font {
+	f := &Font{Font: font, glyphs: map[rune]*ebiten.Image{}}
+	for r, glyph := range font.Glyphs {
+		if glyph.Image != nil {
+			f.glyphs[r] = ebiten.NewImageFromImage(glyph.Image)
+		}
+	}
+	return f
+}
+
+// DrawText draws a text with the top-left corner of its first line at the
+// origin. The GeoM of opts (position, scale) applies after the layout, its
+// ColorScale (e.g. to tint white glyphs), Blend and Filter to every glyph.
+// opts may be nil.
+func (f *Font) DrawText(dst *ebiten.Image, text string, opts *ebiten.DrawImageOptions) {
+	var base ebiten.DrawImageOptions
+	if opts != nil {
+		base = *opts
+	}
+	for _, placed := range f.Font.Layout(text) {
+		img := f.glyphs[placed.Rune]
+		if img == nil {
+			continue
+		}
+		op := base
+		op.GeoM.Reset()
+		op.GeoM.Translate(float64(placed.At.X), float64(placed.At.Y))
+		op.GeoM.Concat(base.GeoM)
+		dst.DrawImage(img, &op)
+	}
+}
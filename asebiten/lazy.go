@@ -0,0 +1,65 @@
+package asebiten
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/retroblast-engine/asevre"
+)
+
+// Images creates the ebiten image of every source image on first use, so no
+// texture is uploaded before it is drawn (or before the game loop starts).
+type Images struct {
+	source []image.Image
+	images []*ebiten.Image
+}
+
+// NewLazyImages wraps the images without creating any ebiten image yet.
+func NewLazyImages(images []image.Image) *Images {
+	return &Images{
+		source: images,
+		images: make([]*ebiten.Image, len(images)),
+	}
+}
+
+// LazyFrames wraps the frames of a tag.
+func LazyFrames(tag asevre.ASETag) *Images {
+	return NewLazyImages(tag.Frames)
+}
+
+// Len returns the number of images.
+func (l *Images) Len() int {
+	return len(l.source)
+}
+
+// At returns the ebiten image of the i-th image, creating it on the first call.
+// It returns nil for nil source images, and the source image itself when it is
+// an ebiten image (e.g. the frames rewritten by NewAtlas).
+func (l *Images) At(i int) *ebiten.Image {
+	if l.images[i] == nil && l.source[i] != nil {
+		l.images[i] = toEbitenImage(l.source[i])
+	}
+	return l.images[i]
+}
+
+// Materialize creates every ebiten image now, e.g. during a loading screen.
+func (l *Images) Materialize() []*ebiten.Image {
+	for i := range l.source {
+		l.At(i)
+	}
+	return l.images
+}
+
+// Release disposes the created ebiten images. They are created again on the
+// next use. Source ebiten images belong to their owner and are left alone.
+func (l *Images) Release() {
+	for i, img := range l.images {
+		if img == nil {
+			continue
+		}
+		if img != l.source[i] {
+			img.Deallocate()
+		}
+		l.images[i] = nil
+	}
+}
@@ -0,0 +1,96 @@
+package asebiten
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/retroblast-engine/asevre"
+)
+
+// NineSlice draws a 9-patch slice at any size, e.g. a UI panel: the corners
+// keep their size, the edges stretch along their side and the center along
+// both axes.
+type NineSlice struct {
+	Image  *ebiten.Image   // Image holding the slice, usually a frame
+	Bounds image.Rectangle // Bounds of the slice in the image
+	Center image.Rectangle // Center of the slice, relative to Bounds
+	Tile   bool            // Repeat the edges and the center instead of stretching them
+}
+
+// NewNineSlice creates the nine-slice of a slice as it is in a frame drawn in
+// img. It returns false if the slice isn't a 9-patch or isn't in the frame.
+func NewNineSlice(img *ebiten.Image, slice asevre.ASESlice, frame int) (*NineSlice, bool) {
+	if !slice.IsNinePatch() {
+		return nil, false
+	}
+	key, ok := slice.KeyAt(frame)
+	if !ok || key.Bounds.Empty() {
+		return nil, false
+	}
+	return &NineSlice{Image: img, Bounds: key.Bounds, Center: key.Center}, true
+}
+
+// Draw draws the slice over target, in dst coordinates. When target is
+// smaller than the corners, they are shrunk to fit.
+func (n *NineSlice) Draw(dst *ebiten.Image, target image.Rectangle) {
+	b, c := n.Bounds, n.Center.Add(n.Bounds.Min).Intersect(n.Bounds)
+	srcX := [4]int{b.Min.X, c.Min.X, c.Max.X, b.Max.X}
+	srcY := [4]int{b.Min.Y, c.Min.Y, c.Max.Y, b.Max.Y}
+	dstX := nineSliceSplit(target.Min.X, target.Max.X, srcX)
+	dstY := nineSliceSplit(target.Min.Y, target.Max.Y, srcY)
+
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			src := image.Rect(srcX[col], srcY[row], srcX[col+1], srcY[row+1])
+			to := image.Rect(dstX[col], dstY[row], dstX[col+1], dstY[row+1])
+			n.drawPatch(dst, src, to, n.Tile && col == 1, n.Tile && row == 1)
+		}
+	}
+}
+
+// nineSliceSplit places the borders of the source columns (or rows) src
+// between from and to, keeping the size of the first and last ones
+func nineSliceSplit(from, to int, src [4]int) [4]int {
+	size := to - from
+	start, end := src[1]-src[0], src[3]-src[2]
+	if start+end > size {
+		start = start * size / (start + end)
+		end = size - start
+	}
+	return [4]int{from, from + start, to - end, to}
+}
+
+// drawPatch draws the src part of the image over to, repeating it along the
+// tiled axes and stretching it along the others
+func (n *NineSlice) drawPatch(dst *ebiten.Image, src, to image.Rectangle, tileX, tileY bool) {
+	if src.Empty() || to.Empty() {
+		return
+	}
+	stepX, stepY := to.Dx(), to.Dy()
+	if tileX {
+		stepX = src.Dx()
+	}
+	if tileY {
+		stepY = src.Dy()
+	}
+
+	op := &ebiten.DrawImageOptions{}
+	for y := to.Min.Y; y < to.Max.Y; y += stepY {
+		for x := to.Min.X; x < to.Max.X; x += stepX {
+			// The last repetition is cut to fit
+			piece := src
+			width, height := min(stepX, to.Max.X-x), min(stepY, to.Max.Y-y)
+			if tileX {
+				piece.Max.X = piece.Min.X + width
+			}
+			if tileY {
+				piece.Max.Y = piece.Min.Y + height
+			}
+
+			op.GeoM.Reset()
+			op.GeoM.Scale(float64(width)/float64(piece.Dx()), float64(height)/float64(piece.Dy()))
+			op.GeoM.Translate(float64(x), float64(y))
+			dst.DrawImage(n.Image.SubImage(piece).(*ebiten.Image), op)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package asebiten
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/retroblast-engine/asevre"
+)
+
+// Tilemap draws a tilemap cel with the images of its tileset.
+type Tilemap struct {
+	Tilemap               asevre.ASETilemap
+	Tiles                 *Images // Tileset images, by tile ID
+	TileWidth, TileHeight int
+	X, Y                  int // Position of the cel in the canvas, in pixels
+}
+
+// NewTilemap creates the renderer of a tilemap. The tile images are created
+// on first draw.
+func NewTilemap(tilemap asevre.ASETilemap, tileset asevre.ASETileset) *Tilemap {
+	return &Tilemap{
+		Tilemap:    tilemap,
+		Tiles:      NewLazyImages(tileset.Tiles),
+		TileWidth:  tileset.TileWidth,
+		TileHeight: tileset.TileHeight,
+	}
+}
+
+// Tilemaps creates the renderers of the tilemap cels of a frame, bottom layer
+// first, placed where they are in the canvas. They share the tile images.
+func Tilemaps(file asevre.ASEFile, frame int) []*Tilemap {
+	tiles := NewLazyImages(file.Tileset.Tiles)
+
+	var tilemaps []*Tilemap
+	for _, cel := range file.TilemapCels(frame) {
+		tilemaps = append(tilemaps, &Tilemap{
+			Tilemap:    cel.Tilemap,
+			Tiles:      tiles,
+			TileWidth:  file.Tileset.TileWidth,
+			TileHeight: file.Tileset.TileHeight,
+			X:          cel.X,
+			Y:          cel.Y,
+		})
+	}
+	return tilemaps
+}
+
+// Size returns the size of the tilemap in pixels
+func (t *Tilemap) Size() (width, height int) {
+	return t.Tilemap.TilemapColumns * t.TileWidth, t.Tilemap.TilemapRows * t.TileHeight
+}
+
+// Draw draws every tile, flipped as authored, at the position of the cel.
+// The GeoM of opts (e.g. a camera) applies after the position, the rest of
+// the options to every tile. opts may be nil.
+func (t *Tilemap) Draw(dst *ebiten.Image, opts *ebiten.DrawImageOptions) {
+	t.draw(dst, t.X, t.Y, opts)
+}
+
+// Bake renders the whole tilemap into a single image of Size, to draw at X, Y.
+// Static layers then cost one draw call per frame.
+func (t *Tilemap) Bake() *ebiten.Image {
+	width, height := t.Size()
+	baked := ebiten.NewImage(max(width, 1), max(height, 1))
+	t.draw(baked, 0, 0, nil)
+	return baked
+}
+
+// draw draws the tiles with the tilemap origin at x, y
+func (t *Tilemap) draw(dst *ebiten.Image, x, y int, opts *ebiten.DrawImageOptions) {
+	op := &ebiten.DrawImageOptions{}
+	for row, tiles := range t.Tilemap.Tiles {
+		for col, tile := range tiles {
+			// Tile 0 is the empty tile
+			if tile.ID <= 0 || tile.ID >= t.Tiles.Len() {
+				continue
+			}
+			img := t.Tiles.At(tile.ID)
+			if img == nil {
+				continue
+			}
+
+			if opts != nil {
+				*op = *opts
+			}
+			op.GeoM = TileGeoM(tile, t.TileWidth, t.TileHeight)
+			op.GeoM.Translate(float64(x+col*t.TileWidth), float64(y+row*t.TileHeight))
+			if opts != nil {
+				op.GeoM.Concat(opts.GeoM)
+			}
+			dst.DrawImage(img, op)
+		}
+	}
+}
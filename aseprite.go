@@ -2,22 +2,21 @@ package asevre
 
 import (
 	"bytes"
-	"cmp"
 	"compress/zlib"
 	"embed"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 	"time"
-
-	"github.com/hajimehoshi/ebiten/v2"
 )
 
 // AsepriteSprite represents a parsed Aseprite file.
@@ -26,10 +25,6 @@ type AsepriteSprite struct {
 	States  map[string][]TileMap // Maps state names to their tilemaps
 }
 
-type Sprites struct {
-	Current *ebiten.Image
-	All     []*ebiten.Image
-}
 type Color struct {
 	Red   BYTE
 	Green BYTE
@@ -66,8 +61,14 @@ func parseChunk0x0004(data []byte) (*Chunk0x0004, error) {
 			return nil, err
 		}
 
-		packet.Colors = make([]Color, int(packet.NumberOfColorsInThisPacket))
-		for j := 0; j < int(packet.NumberOfColorsInThisPacket); j++ {
+		// 0 means 256 colors
+		numColors := int(packet.NumberOfColorsInThisPacket)
+		if numColors == 0 {
+			numColors = 256
+		}
+
+		packet.Colors = make([]Color, numColors)
+		for j := 0; j < numColors; j++ {
 			if err := binary.Read(reader, binary.LittleEndian, &packet.Colors[j]); err != nil {
 				return nil, err
 			}
@@ -102,6 +103,11 @@ type STRING struct {
 	Chars  []BYTE // characters (in UTF-8)
 }
 
+// MarshalJSON writes the string as text instead of bytes
+func (s STRING) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(s.Chars))
+}
+
 type Chunk2003 struct {
 	TilesetID              DWORD    // Tileset ID (4 bytes) // 4 bytes so far
 	TilesetFlags           DWORD    // Tileset flags (4 bytes) // 8 bytes so far
@@ -111,6 +117,8 @@ type Chunk2003 struct {
 	BaseIndex              SHORT    // Base index (2 bytes) just for UI purposes // 18 bytes so far
 	Reserved               [14]BYTE // Reserved for future use, set to zero (14 bytes) // 32 bytes so far
 	TilesetName            STRING   // Tileset name (variable length) // 34 bytes so far + variable length
+	ExternalFileID         DWORD    // ID of the external file, only if the tileset links to an external file (4 bytes)
+	ExternalTilesetID      DWORD    // Tileset ID in the external file (4 bytes)
 	SizeOfTilesetImage     DWORD    // Data length of the tileset image data (4 bytes) // 38 bytes so far + variable string chars length
 	CompressedTilesetImage []byte   // Compressed tileset image data (variable length)
 }
@@ -149,130 +157,24 @@ func parseChunk0x2023(data []byte) (*Chunk2003, error) {
 	}
 
 	flags := chunk.GetTilesetFlags()
-	if flags.IncludeTilesInsideFile {
-		if err := binary.Read(r, binary.LittleEndian, &chunk.SizeOfTilesetImage); err != nil {
+	if flags.IncludeLinkToExternalFile {
+		if err := binary.Read(r, binary.LittleEndian, &chunk.ExternalFileID); err != nil {
 			return nil, err
 		}
-		// fmt.Printf("Size of Tileset Image: %d\n", chunk.SizeOfTilesetImage)
-
-		sizeSoFar := 34 + len(chunk.TilesetName.Chars) + 4
-
-		chunk.CompressedTilesetImage = make([]byte, len(data)-sizeSoFar)
-		if err := binary.Read(r, binary.LittleEndian, &chunk.CompressedTilesetImage); err != nil {
+		if err := binary.Read(r, binary.LittleEndian, &chunk.ExternalTilesetID); err != nil {
 			return nil, err
 		}
-
-		decompressed, err := decompressZlib(chunk.CompressedTilesetImage)
-		if err != nil {
-			return nil, fmt.Errorf("error decompressing Tileset Image data: %v", err)
-		}
-
-		// Loop through []byte decompressed data to read PIXEL data
-		// Create a new RGBA image
-		// count := 0
-
-		// img := image.NewRGBA(image.Rect(0, 0, 11*8, 8)) // TODO: Hardcoded values!!!!!!!!!!!!!!!!
-
-		// Αυτα ειναι ΙΔΙΑ (704 pixels)
-		// Οπου το pixel ειναι ου
-
-		// How many []PIXEL do we have?
-		// Answer: (Tile Width) x (Tile Height x Number of Tiles)
-		tileWidth := int(chunk.TileWidth)
-		tileHeight := int(chunk.TileHeight)
-		// numTiles := int(chunk.NumberOfTiles)
-		// numPixels := tileWidth * tileHeight * numTiles
-
-		// fmt.Println("Number of PIXEL[] expected:", numPixels)
-		// fmt.Println("Decompressed Tileset Image Data Length:", len(decompressed), "bytes")
-
-		var tilesetTiles []byte
-
-		// Assuming tileWidth and tileHeight are defined
-		tileSize := tileWidth * tileHeight
-
-		// Loop through the decompressed data to extract each tile
-		for i := 0; i < len(decompressed); i += tileSize {
-			// Ensure we don't go out of bounds
-			if i+tileSize > len(decompressed) {
-				break
-			}
-
-			// Extract the current tile
-			tile := decompressed[i : i+tileSize]
-
-			// Append the current tile to the tilesetTile slice
-			tilesetTiles = append(tilesetTiles, tile...)
-		}
-
-		// for tile := 0; tile < numTiles; tile++ {
-		// 	printTile(tile, tilesetTiles, tileWidth, tileHeight)
-		// 	fmt.Println()
-		// 	fmt.Println()
-		// }
-
-		// fmt.Println()
-
-		// printTilesHorizontally(tilesetTiles, tileWidth, tileHeight)
-
-		// Save the image to disk
-		// file, err := os.Create("output.png")
-		// if err != nil {
-		// 	fmt.Println("Error creating file:", err)
-		// 	return nil, err
-		// }
-		// defer file.Close()
-
-		// err = png.Encode(file, img)
-		// if err != nil {
-		// 	fmt.Println("Error encoding PNG:", err)
-		// 	return nil, err
-		// }
-
-		// fmt.Println("Image saved to output.png")
-
 	}
-
-	return chunk, nil
-}
-
-func printTilesHorizontally(tilesetTiles []byte, tileWidth, tileHeight int) {
-	tileSize := tileWidth * tileHeight
-	numTiles := len(tilesetTiles) / tileSize
-
-	for row := 0; row < tileHeight; row++ {
-		for tile := 0; tile < numTiles; tile++ {
-			start := tile*tileSize + row*tileWidth
-			end := start + tileWidth
-			for i := start; i < end; i++ {
-				fmt.Printf("%d ", tilesetTiles[i])
-			}
+	if flags.IncludeTilesInsideFile {
+		if err := binary.Read(r, binary.LittleEndian, &chunk.SizeOfTilesetImage); err != nil {
+			return nil, err
 		}
-		fmt.Println()
-	}
-}
-
-func printTile(tileNumber int, tilesetTiles []byte, tileWidth, tileHeight int) {
-	tileSize := tileWidth * tileHeight
-	start := tileNumber * tileSize
-	end := start + tileSize
 
-	// Ensure the end index does not exceed the length of the tilesetTiles data
-	if end > len(tilesetTiles) {
-		fmt.Println("Tile number out of range")
-		return
+		// The image data is the rest of the chunk, kept without a copy
+		chunk.CompressedTilesetImage = data[len(data)-r.Len():]
 	}
 
-	// Extract the tile
-	tile := tilesetTiles[start:end]
-
-	// Print the tile in a readable format
-	for i := 0; i < tileHeight; i++ {
-		for j := 0; j < tileWidth; j++ {
-			fmt.Printf("%x ", tile[i*tileWidth+j])
-		}
-		fmt.Println()
-	}
+	return chunk, nil
 }
 
 // Define the PIXEL type
@@ -282,11 +184,27 @@ type PIXEL struct {
 	Indexed   BYTE    // BYTE, each pixel uses 1 byte (the index)
 }
 
+// indexedColor returns the palette color of an index, or a transparent color for
+// the transparent index (-1 when there isn't a transparent index)
+func indexedColor(palette []color.Color, index BYTE, transparentIdx int) color.Color {
+	if int(index) == transparentIdx {
+		return color.NRGBA{}
+	}
+	return palette[index]
+}
+
+// grayscaleColor converts a grayscale pixel (value + alpha) to a color
+func grayscaleColor(value, alpha BYTE) color.Color {
+	return color.NRGBA{R: value, G: value, B: value, A: alpha}
+}
+
 type CompressedTilesetImageData struct {
 	Length DWORD
 	Image  []BYTE
 }
 
+// TilesetFlags are the flags of a tileset chunk. The auto-match flags tell
+// which flipped versions of a tile the editor matches modified tiles against.
 type TilesetFlags struct {
 	IncludeLinkToExternalFile bool
 	IncludeTilesInsideFile    bool
@@ -358,37 +276,14 @@ type CompressedTilemap struct {
 	Tiles               []BYTE   // Compressed tile data using ZLIB. They are in Row by row, from top to bottom tile by tile
 }
 
-// Function to decompress ZLIB data
-func decompressZlib(data []byte) ([]byte, error) {
-	// Check if the input data is not empty
-	if len(data) == 0 {
-		return nil, fmt.Errorf("input data is empty")
-	}
-
-	// Print the data
-	// fmt.Printf("         >>> Decompressing %d bytes of ZLIB data...\n", len(data))
-
-	// Create a bytes.Reader from the input data:
-	b := bytes.NewReader(data)
-
-	// Create a new ZLIB reader:
-	r, err := zlib.NewReader(b)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create zlib reader: %w", err)
-	}
-	defer r.Close()
-
-	// Create a bytes.Buffer to hold the decompressed data:
-	var out bytes.Buffer
-
-	// Copy the decompressed data from the ZLIB reader to the buffer:
-	_, err = io.Copy(&out, r)
-	if err != nil {
-		return nil, fmt.Errorf("failed to copy decompressed data: %w", err)
+// Function to decompress ZLIB data, size is the expected size of the decompressed data
+func decompressZlib(data []byte, size int) ([]byte, error) {
+	// The buffer is sized from the expected bytes, it is never grown
+	out := make([]byte, size)
+	if err := inflate(out, data); err != nil {
+		return nil, err
 	}
-
-	// Return the decompressed data as a byte slice:
-	return out.Bytes(), nil
+	return out, nil
 }
 
 // Layer represents a layer with a specific z-index for a cel in a frame.
@@ -422,12 +317,7 @@ func ProcessZIndexes(layers Layers2005) {
 
 	// Sort layers based on their order and z-index using slices.SortFunc
 	slices.SortFunc(layers, func(a, b Layer2005) int {
-		orderA := a.Order()
-		orderB := b.Order()
-		if orderA == orderB {
-			return cmp.Compare(a.ZIndex, b.ZIndex)
-		}
-		return cmp.Compare(orderA, orderB)
+		return compareCelOrder(int(a.LayerIndex), int(a.ZIndex), int(b.LayerIndex), int(b.ZIndex))
 	})
 }
 
@@ -454,7 +344,6 @@ func parseChunk0x2005(data []byte) (*Chunk0x2005, error) {
 
 	l := reader.Len()
 	size := reader.Size()
-	// fmt.Println("     - Starting reading", l, "bytes of data")
 
 	// Read common fields
 	if err := binary.Read(reader, binary.LittleEndian, &chunk.LayerIndex); err != nil {
@@ -466,8 +355,6 @@ func parseChunk0x2005(data []byte) (*Chunk0x2005, error) {
 		return nil, fmt.Errorf("invalid data length: %d", l)
 	}
 
-	// fmt.Println("      > Layer Index:", chunk.LayerIndex)
-
 	if err := binary.Read(reader, binary.LittleEndian, &chunk.XPosition); err != nil {
 		return nil, err
 	}
@@ -477,8 +364,6 @@ func parseChunk0x2005(data []byte) (*Chunk0x2005, error) {
 		return nil, fmt.Errorf("invalid data length: %d", l)
 	}
 
-	// fmt.Println("      > X Position:", chunk.XPosition)
-
 	if err := binary.Read(reader, binary.LittleEndian, &chunk.YPosition); err != nil {
 		return nil, err
 	}
@@ -488,8 +373,6 @@ func parseChunk0x2005(data []byte) (*Chunk0x2005, error) {
 		return nil, fmt.Errorf("invalid data length: %d", l)
 	}
 
-	// fmt.Println("      > Y Position:", chunk.YPosition)
-
 	if err := binary.Read(reader, binary.LittleEndian, &chunk.OpacityLevel); err != nil {
 		return nil, err
 	}
@@ -499,8 +382,6 @@ func parseChunk0x2005(data []byte) (*Chunk0x2005, error) {
 		return nil, fmt.Errorf("invalid data length: %d", l)
 	}
 
-	// fmt.Println("      > Opacity Level:", chunk.OpacityLevel)
-
 	if err := binary.Read(reader, binary.LittleEndian, &chunk.CelType); err != nil {
 		return nil, err
 	}
@@ -510,20 +391,6 @@ func parseChunk0x2005(data []byte) (*Chunk0x2005, error) {
 		return nil, fmt.Errorf("invalid data length: %d", l)
 	}
 
-	// celtype := "Unknown"
-	// switch chunk.CelType {
-	// case RawImageData:
-	// 	celtype = "Raw Image"
-	// case LinkedCelData:
-	// 	celtype = "Linked Cel"
-	// case CompressedImageData:
-	// 	celtype = "Compressed Image"
-	// case CompressedTilemapData:
-	// 	celtype = "Compressed Tilemap"
-	// }
-
-	// fmt.Println("      > Cel Type:", celtype)
-
 	if err := binary.Read(reader, binary.LittleEndian, &chunk.ZIndex); err != nil {
 		return nil, err
 	}
@@ -533,17 +400,6 @@ func parseChunk0x2005(data []byte) (*Chunk0x2005, error) {
 		return nil, fmt.Errorf("invalid data length: %d", l)
 	}
 
-	// ztype := "Unknown"
-	// if chunk.ZIndex < 0 {
-	// 	ztype = fmt.Sprintf("-N: Show this cel %d layers back", chunk.ZIndex)
-	// } else if chunk.ZIndex > 0 {
-	// 	ztype = fmt.Sprintf("+N: Show this cel %d layers later", chunk.ZIndex)
-	// } else {
-	// 	ztype = "0: Default layer ordering"
-	// }
-
-	// fmt.Println("      > Z-Index:", ztype)
-
 	if err := binary.Read(reader, binary.LittleEndian, &chunk.Reserved); err != nil {
 		return nil, err
 	}
@@ -553,8 +409,6 @@ func parseChunk0x2005(data []byte) (*Chunk0x2005, error) {
 		return nil, fmt.Errorf("invalid data length: %d", l)
 	}
 
-	// fmt.Println("      > Reserved:", chunk.Reserved)
-
 	// Read the actual data based on the remaining length (size - 16)
 	remainingSize := int(size) - 16
 	chunk.Data = make([]byte, remainingSize)
@@ -567,7 +421,19 @@ func parseChunk0x2005(data []byte) (*Chunk0x2005, error) {
 		return nil, fmt.Errorf("data is empty")
 	}
 
-	// fmt.Printf("      > %s : %d bytes\n", celtype, len(chunk.Data))
+	// The fields of the cel type must be there before they are sliced
+	var celHeaderSize int
+	switch chunk.CelType {
+	case LinkedCelData:
+		celHeaderSize = 2
+	case RawImageData, CompressedImageData:
+		celHeaderSize = 4
+	case CompressedTilemapData:
+		celHeaderSize = 32
+	}
+	if len(chunk.Data) < celHeaderSize {
+		return nil, fmt.Errorf("cel data of %d bytes: %w", len(chunk.Data), io.ErrUnexpectedEOF)
+	}
 
 	// Read specific fields based on CelType
 	switch chunk.CelType {
@@ -577,158 +443,36 @@ func parseChunk0x2005(data []byte) (*Chunk0x2005, error) {
 		rawImage.Width = WORD(chunk.Data[0]) | WORD(chunk.Data[1])<<8
 		rawImage.Height = WORD(chunk.Data[2]) | WORD(chunk.Data[3])<<8
 		rawImage.Pixels = chunk.Data[4:]
-		fmt.Printf("      > Raw Image Data: %dx%d pixels\n", rawImage.Width, rawImage.Height)
 	case LinkedCelData:
 		// Linked Cel Data
 		linkedCel := LinkedCel{}
 		linkedCel.FramePosition = WORD(chunk.Data[0]) | WORD(chunk.Data[1])<<8
-		fmt.Printf("      > Linked Cel Data: Frame Position: %d\n", linkedCel.FramePosition)
 	case CompressedImageData:
 		// Compressed Image Data
 		compressedImage := CompressedImage{}
 		compressedImage.Width = WORD(chunk.Data[0]) | WORD(chunk.Data[1])<<8
 		compressedImage.Height = WORD(chunk.Data[2]) | WORD(chunk.Data[3])<<8
 		compressedImage.Pixels = chunk.Data[4:]
-		// fmt.Printf("      > Compressed Image Data: %dx%d pixels\n", compressedImage.Width, compressedImage.Height)
-
-		// decompressedPixels, err := decompressZlib(compressedImage.Pixels)
-		// if err != nil {
-		// 	return nil, fmt.Errorf("error decompressing tile data: %v", err)
-		// }
-
-		// fmt.Printf("         >>> Decompressed Image: %d bytes\n", len(decompressedPixels))
 
 	case CompressedTilemapData:
 		// Compressed Tilemap Data
 		compressedTilemap := CompressedTilemap{}
 		compressedTilemap.Width = WORD(chunk.Data[0]) | WORD(chunk.Data[1])<<8
-		// fmt.Printf("       >> Width in number of Tiles: %d columns\n", compressedTilemap.Width)
 		compressedTilemap.Height = WORD(chunk.Data[2]) | WORD(chunk.Data[3])<<8
-		// fmt.Printf("       >> Height in number of Tiles: %d rows\n", compressedTilemap.Height)
 		compressedTilemap.BitsPerTile = WORD(chunk.Data[4]) | WORD(chunk.Data[5])<<8
-		// fmt.Printf("       >> Bits per tile: %d\n", compressedTilemap.BitsPerTile)
 		compressedTilemap.TileIDBitmask = DWORD(chunk.Data[6]) | DWORD(chunk.Data[7])<<8 | DWORD(chunk.Data[8])<<16 | DWORD(chunk.Data[9])<<24
-		// fmt.Printf("       >> Tile ID Bitmask: 0x%08x\n", compressedTilemap.TileIDBitmask)
 		compressedTilemap.XFlipBitmask = DWORD(chunk.Data[10]) | DWORD(chunk.Data[11])<<8 | DWORD(chunk.Data[12])<<16 | DWORD(chunk.Data[13])<<24
-		// fmt.Printf("       >> X Flip Bitmask: 0x%08x\n", compressedTilemap.XFlipBitmask)
 		compressedTilemap.YFlipBitmask = DWORD(chunk.Data[14]) | DWORD(chunk.Data[15])<<8 | DWORD(chunk.Data[16])<<16 | DWORD(chunk.Data[17])<<24
-		// fmt.Printf("       >> Y Flip Bitmask: 0x%08x\n", compressedTilemap.YFlipBitmask)
 		compressedTilemap.DiagonalFlipBitmask = DWORD(chunk.Data[18]) | DWORD(chunk.Data[19])<<8 | DWORD(chunk.Data[20])<<16 | DWORD(chunk.Data[21])<<24
-		// fmt.Printf("       >> Diagonal Flip Bitmask: 0x%08x\n", compressedTilemap.DiagonalFlipBitmask)
 		compressedTilemap.Reserved = [10]BYTE{chunk.Data[22], chunk.Data[23], chunk.Data[24], chunk.Data[25], chunk.Data[26], chunk.Data[27], chunk.Data[28], chunk.Data[29], chunk.Data[30], chunk.Data[31]}
-		// fmt.Printf("       >> Reserved: %v\n", compressedTilemap.Reserved)
 		compressedTilemap.Tiles = chunk.Data[32:]
-		// fmt.Printf("       >> Size of Compressed (ZLIB) Tilemap: %d bytes\n", len(compressedTilemap.Tiles))
-
-		// Decompress the tile zlib data
-		decompressedTiles, err := decompressZlib(compressedTilemap.Tiles)
-		if err != nil {
-			return nil, fmt.Errorf("error decompressing tile data: %v", err)
-		}
-
-		// fmt.Printf("         >>> Decompressed Tilemap: %d bytes\n", len(decompressedTiles))
-
-		// Update the compressed tilemap data with the decompressed data
-		compressedTilemap.Tiles = decompressedTiles
-
-		// Recondstruct the tiles
-		// Row by row, from top to bottom tile by tile
-		// Each tile is has Bits per tile: 32 bits (4 bytes)
-
-		// Calculate the number of tiles
-		numTiles := int(compressedTilemap.Width) * int(compressedTilemap.Height)
-
-		// verify that the number of tiles is correct
-		bytesPerTile := int(compressedTilemap.BitsPerTile) / 8
-		if numTiles != len(decompressedTiles)/bytesPerTile {
-			return nil, fmt.Errorf("invalid number of tiles: %d", numTiles)
-		}
-
-		// fmt.Printf("         >>> Number of Tiles: %d\n", numTiles)
-
-		// Calculate the tilemap resolution in tile units (not pixels)
-		tilemapRows := int(compressedTilemap.Height)
-		tilemapCols := int(compressedTilemap.Width)
-
-		// Print tilemap resolution
-		// fmt.Printf("         >>> Tilemap Rows: %d\n", tilemapRows)
-		// fmt.Printf("         >>> Tilemap Columns: %d\n", tilemapCols)
-
-		var tiles []Tile
-
-		// Iterate over the tiles row by row
-		for row := 0; row < tilemapRows; row++ {
-			// Iterate over the tiles in the row
-			for col := 0; col < tilemapCols; col++ {
-				// Calculate the offset in the decompressed tile data
-				offset := (row*tilemapCols + col) * bytesPerTile
-
-				// Read the tile data based on the bits per tile
-				tileData := decompressedTiles[offset : offset+bytesPerTile]
-
-				// tiledata is []4 bytes
-				// Bitmasking: First, a bitmask is applied to isolate the bit of interest.
-				//             For example, 0x80000000 isolates the highest bit (bit 31),
-				//							0x40000000 isolates the second highest bit (bit 30),
-				// 						and 0x20000000 isolates the third highest bit (bit 29).
-				// Shifting: After applying the bitmask, the result is shifted right to move the bit of interest to the least significant bit (bit 0).
-				//           This converts the bit into a boolean-like value (0 or 1).
-
-				tileID := binary.LittleEndian.Uint32(tileData) & uint32(compressedTilemap.TileIDBitmask)
-				xFlip := binary.LittleEndian.Uint32(tileData) & uint32(compressedTilemap.XFlipBitmask) >> 31
-				yFlip := binary.LittleEndian.Uint32(tileData) & uint32(compressedTilemap.YFlipBitmask) >> 30
-				diagonalFlip := binary.LittleEndian.Uint32(tileData) & uint32(compressedTilemap.DiagonalFlipBitmask) >> 29
-
-				// Create a new tile
-				tile := Tile{
-					Width:        8, // 8 pixels
-					Height:       8, // 8 pixels
-					ID:           int(tileID),
-					XFlip:        xFlip == 1,
-					YFlip:        yFlip == 1,
-					DiagonalFlip: diagonalFlip == 1,
-				}
-
-				// Append the tile to the list of tiles
-				tiles = append(tiles, tile)
-			}
-		}
-
-		// Print the tilemap
-		// fmt.Println("         >>> Tilemap:")
-		for row := 0; row < tilemapRows; row++ {
-			rowStr := ""
-			for col := 0; col < tilemapCols; col++ {
-				// Calculate the index of the tile in the tiles slice
-				index := row*tilemapCols + col
-
-				// Print the tile information
-				var str string
-				id := tiles[index].ID
-				str += fmt.Sprintf("%02d", id)
-				if tiles[index].XFlip {
-					str += "X"
-				}
-				if tiles[index].YFlip {
-					str += "Y"
-				}
-				if tiles[index].DiagonalFlip {
-					str += "D"
-				}
-
-				// Append the tile information to the row string
-				rowStr += fmt.Sprintf("%-4s", str)
-			}
-			// fmt.Printf("           >>>> [ %s ]\n", rowStr)
-		}
-
 	}
 
 	return &chunk, nil
 }
 
-// TODO: Not implemented yet.
 // This is about color profile, like sRGB and ICC profiles, so colors are correctly displayed on different devices.
+// See ColorProfile and WithColorManagement.
 
 // Chunk0x2007 represents the color profile chunk
 type Chunk0x2007 struct {
@@ -791,35 +535,30 @@ func parse0x2007(data []byte) (*Chunk0x2007, error) {
 	if err := binary.Read(reader, binary.LittleEndian, &chunk.Type); err != nil {
 		return nil, err
 	}
-	// fmt.Println("Color Profile Type:", chunk.GetTypeDescription())
 
 	if err := binary.Read(reader, binary.LittleEndian, &chunk.Flags); err != nil {
 		return nil, err
 	}
-	// fmt.Println("Uses Special Fixed Gamma:", chunk.UsesSpecialFixedGamma())
 
 	if err := binary.Read(reader, binary.LittleEndian, &chunk.FixedGamma); err != nil {
 		return nil, err
 	}
-	// fmt.Println("Fixed Gamma:", chunk.FixedGamma)
 
 	if err := binary.Read(reader, binary.LittleEndian, &chunk.Reserved); err != nil {
 		return nil, err
 	}
-	// fmt.Printf("Reserved: %v\n", chunk.Reserved)
 
 	// If type is not ICC, then skip the ICC profile data
 	if chunk.Type == UseEmbeddedICCProfile {
 		if err := binary.Read(reader, binary.LittleEndian, &chunk.ICCProfileLength); err != nil {
 			return nil, err
 		}
-		// fmt.Println("ICC Profile Length:", chunk.ICCProfileLength)
 
-		chunk.ICCProfileData = make([]BYTE, chunk.ICCProfileLength)
-		if err := binary.Read(reader, binary.LittleEndian, &chunk.ICCProfileData); err != nil {
+		profile, err := readBytes(reader, int(chunk.ICCProfileLength))
+		if err != nil {
 			return nil, err
 		}
-		// fmt.Println("ICC Profile Data:", chunk.ICCProfileData)
+		chunk.ICCProfileData = profile
 	}
 
 	if !chunk.IsChunkValid() {
@@ -843,11 +582,29 @@ func (c *Chunk0x2007) IsChunkValid() bool {
 }
 
 type Chucnk0x2019 struct {
-	NewPaletteSize DWORD   // New palette size, total number of entries (4 bytes)
-	FirstColor     DWORD   // First color index to change (4 bytes)
-	LastColor      DWORD   // Last color index to change (4 bytes)
-	Reserved       [8]BYTE // Reserved (set to 0) (8 bytes)
+	NewPaletteSize DWORD          // New palette size, total number of entries (4 bytes)
+	FirstColor     DWORD          // First color index to change (4 bytes)
+	LastColor      DWORD          // Last color index to change (4 bytes)
+	Reserved       [8]BYTE        // Reserved (set to 0) (8 bytes)
+	Entries        []PaletteEntry // Entries from FirstColor to LastColor (variable length)
+}
+
+// Palette entry flags (0x2019 chunk)
+const PaletteEntryHasName WORD = 1
+
+// PaletteEntry is a color of the new palette chunk (0x2019).
+type PaletteEntry struct {
+	Flags WORD   // Entry flags (2 bytes)
+	Red   BYTE   // Red (0-255) (1 byte)
+	Green BYTE   // Green (0-255) (1 byte)
+	Blue  BYTE   // Blue (0-255) (1 byte)
+	Alpha BYTE   // Alpha (0-255) (1 byte)
+	Name  STRING // Color name, if flags has bit 1 (variable length)
+}
 
+// Color returns the entry as a non-premultiplied color
+func (e PaletteEntry) Color() color.NRGBA {
+	return color.NRGBA{R: e.Red, G: e.Green, B: e.Blue, A: e.Alpha}
 }
 
 func parseChunk0x2019(data []byte) (*Chucnk0x2019, error) {
@@ -867,6 +624,48 @@ func parseChunk0x2019(data []byte) (*Chucnk0x2019, error) {
 		return nil, err
 	}
 
+	if chunk.LastColor < chunk.FirstColor {
+		return nil, fmt.Errorf("invalid palette range: %d-%d", chunk.FirstColor, chunk.LastColor)
+	}
+	if chunk.LastColor >= chunk.NewPaletteSize {
+		return nil, fmt.Errorf("palette range %d-%d past the palette size of %d", chunk.FirstColor, chunk.LastColor, chunk.NewPaletteSize)
+	}
+	// Every entry takes at least 6 bytes, check the range fits in the chunk
+	if count := int64(chunk.LastColor-chunk.FirstColor) + 1; count*6 > int64(r.Len()) {
+		return nil, fmt.Errorf("palette range %d-%d larger than the %d bytes of the chunk", chunk.FirstColor, chunk.LastColor, r.Len())
+	}
+
+	for i := chunk.FirstColor; i <= chunk.LastColor; i++ {
+		entry := PaletteEntry{}
+		if err := binary.Read(r, binary.LittleEndian, &entry.Flags); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &entry.Red); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &entry.Green); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &entry.Blue); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &entry.Alpha); err != nil {
+			return nil, err
+		}
+		if entry.Flags&PaletteEntryHasName != 0 {
+			if err := binary.Read(r, binary.LittleEndian, &entry.Name.Length); err != nil {
+				return nil, err
+			}
+			chars, err := readBytes(r, int(entry.Name.Length))
+			if err != nil {
+				return nil, err
+			}
+			entry.Name.Chars = chars
+		}
+
+		chunk.Entries = append(chunk.Entries, entry)
+	}
+
 	return chunk, nil
 }
 
@@ -922,6 +721,40 @@ type Chunk struct {
 	ChunkSize DWORD  // Size of the chunk (4 bytes)
 	ChunkType WORD   // Type of the chunk (2 bytes)
 	ChunkData []BYTE // Data of the chunk (variable length)
+	Offset    int64  // Offset of the chunk in the file
+}
+
+// chunkTypeNames are the chunk types of the .aseprite specification
+var chunkTypeNames = map[WORD]string{
+	0x0004: "Old palette",
+	0x0011: "Old palette (6-bit)",
+	0x2004: "Layer",
+	0x2005: "Cel",
+	0x2006: "Cel extra",
+	0x2007: "Color profile",
+	0x2008: "External files",
+	0x2016: "Mask (deprecated)",
+	0x2017: "Path (never used)",
+	0x2018: "Tags",
+	0x2019: "Palette",
+	0x2020: "User data",
+	0x2022: "Slice",
+	0x2023: "Tileset",
+}
+
+// isKnownChunkType checks if the chunk type is part of the specification
+func isKnownChunkType(chunkType WORD) bool {
+	_, known := chunkTypeNames[chunkType]
+	return known
+}
+
+// ChunkTypeName returns the name of a chunk type, "Unknown" for types that
+// are not in the specification.
+func ChunkTypeName(chunkType WORD) string {
+	if name, known := chunkTypeNames[chunkType]; known {
+		return name
+	}
+	return "Unknown"
 }
 
 // IsValid checks if the chunk size is valid
@@ -931,11 +764,12 @@ func (c *Chunk) IsValid() bool {
 }
 
 // checkFrameSize checks if the total chunk size plus frame header size equals BytesInFrame
-func checkFrameSize(totalChunkSize uint32, frameHeader *FrameHeader) {
+func checkFrameSize(totalChunkSize uint32, frameHeader *FrameHeader) error {
 	const frameHeaderSize = 16
 	if totalChunkSize+frameHeaderSize != frameHeader.BytesInFrame {
-		panic(fmt.Sprintf("Frame size mismatch: expected %d, got %d", frameHeader.BytesInFrame, totalChunkSize+frameHeaderSize))
+		return fmt.Errorf("frame size mismatch: expected %d, got %d", frameHeader.BytesInFrame, totalChunkSize+frameHeaderSize)
 	}
+	return nil
 }
 
 // PrintData prints the chunk data
@@ -1028,55 +862,33 @@ func (h *Header) GetGridSize() (uint16, uint16) {
 	return uint16(h.GridWidth), uint16(h.GridHeight)
 }
 
-// formatFileSize converts the file size to a human-readable format
-func formatFileSize(size uint32) string {
-	if size < 1024 {
-		return fmt.Sprintf("%d bytes", size)
-	} else if size < 1024*1024 {
-		return fmt.Sprintf("%.1fK", float64(size)/1024)
-	} else if size < 1024*1024*1024 {
-		return fmt.Sprintf("%.1fM", float64(size)/(1024*1024))
-	} else {
-		return fmt.Sprintf("%.1fG", float64(size)/(1024*1024*1024))
-	}
-}
-
-// printHeader prints the fields of the Header struct
-func (header *Header) printHeader() {
-	fmt.Println("Sprite Information:")
-	fmt.Println("===================")
-	fmt.Printf("Size: %d x %d pixels (%s)\n", header.Width, header.Height, formatFileSize(header.FileSize))
-	fmt.Printf("Type: colormode %s, colors %d, depth %d bpp\n", header.GetColorDepthDescription(), header.GetNumColors(), header.ColorDepth)
-	// msgFlag := "Layer opacity has invalid value"
-	// if header.IsLayerOpacityValid() {
-	// 	msgFlag = "Layer opacity has valid value"
-	// }
-	// fmt.Printf("Flags: %d (%s)\n", header.Flags, msgFlag)
-	// fmt.Printf("Speed: %d ms between frames\n", header.Speed)
-	fmt.Printf("Transparent Index: %d\n", header.TransparentIdx)
-	fmt.Printf("Aspect Ratio: %s\n", header.GetPixelRatio())
-	gridWidth, gridHeight := header.GetGridSize()
-	fmt.Printf("Grid Size: %d x %d\n", gridWidth, gridHeight)
-	fmt.Printf("Number of Frames: %d\n", header.FrameCount)
-}
-
 // readAsepriteFile reads and parses the header, frame headers, and chunks of an .aseprite or .ase file
-func readAsepriteFile(assets embed.FS, filePath string) (*Header, []Frame, error) {
+func readAsepriteFile(assets fs.FS, filePath string, options ParseOptions) (*Header, []Frame, []error, error) {
 	ext := filepath.Ext(filePath)
 	if ext != ".aseprite" && ext != ".ase" {
-		return nil, nil, fmt.Errorf("unsupported file type: %s", ext)
+		return nil, nil, nil, fmt.Errorf("unsupported file type: %s", ext)
 	}
 
 	file, err := assets.Open(filePath)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	defer file.Close()
 
 	// Read the file content into a byte slice
-	fileContent, err := io.ReadAll(file)
+	fileContent, err := readAll(file, options.Limits.MaxFileSize)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+	return readAsepriteData(fileContent, options)
+}
+
+// readAsepriteData reads and parses the header, frame headers, and chunks of
+// the content of an .aseprite or .ase file. The data of the chunks is sliced
+// out of fileContent, never copied.
+func readAsepriteData(fileContent []byte, options ParseOptions) (*Header, []Frame, []error, error) {
+	if int64(len(fileContent)) > options.Limits.MaxFileSize {
+		return nil, nil, nil, fmt.Errorf("%w: file larger than %d bytes", ErrLimitExceeded, options.Limits.MaxFileSize)
 	}
 
 	// Create a bytes.Reader to read from the byte slice
@@ -1087,34 +899,53 @@ func readAsepriteFile(assets embed.FS, filePath string) (*Header, []Frame, error
 
 	// Read the header (128 bytes)
 	header := &Header{}
-	err = binary.Read(reader, binary.LittleEndian, header)
+	err := binary.Read(reader, binary.LittleEndian, header)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+
+	if header.MagicNumberHeader != 0xA5E0 {
+		return nil, nil, nil, fmt.Errorf("%w: header 0x%04x", ErrBadMagic, header.MagicNumberHeader)
 	}
 
 	// What is the size of the header?
 	headerSize := binary.Size(header)
 	if headerSize != 128 {
-		return nil, nil, fmt.Errorf("invalid header size: %d", headerSize)
+		return nil, nil, nil, fmt.Errorf("invalid header size: %d", headerSize)
 	}
 
 	// Read frames
 	var frames []Frame
+	var warnings []error
+
+	// truncated stops at a frame cut by the end of the data, keeping the
+	// frames read so far
+	truncated := func(frame int, frameOffset int64, err error) (*Header, []Frame, []error, error) {
+		if !isShortRead(err) || options.Strict {
+			return nil, nil, nil, err
+		}
+		return header, frames, warnings, &TruncatedError{Frame: frame, Frames: int(header.FrameCount), FrameOffset: frameOffset, Size: fileSize, Err: err}
+	}
 
 	for i := 0; i < int(header.FrameCount); i++ {
+		frameOffset := fileSize - int64(reader.Len())
+
 		// Read the Frame Header (16 bytes)
 		// Each frame has this little header of 16 bytes:
 		// ==============================================
 		frameHeader := &FrameHeader{}
 		err = binary.Read(reader, binary.LittleEndian, frameHeader)
 		if err != nil {
-			fmt.Println("Error reading frame header:", err)
-			return nil, nil, err
+			return truncated(i, frameOffset, err)
+		}
+
+		if frameHeader.MagicNumber != 0xF1FA {
+			return nil, nil, nil, fmt.Errorf("%w: frame %d 0x%04x", ErrBadMagic, i, frameHeader.MagicNumber)
 		}
 
 		frameHeaderSize := binary.Size(frameHeader)
 		if frameHeaderSize != 16 {
-			return nil, nil, fmt.Errorf("invalid frame header size: %d", frameHeaderSize)
+			return nil, nil, nil, fmt.Errorf("invalid frame header size: %d", frameHeaderSize)
 		}
 		// ==============================================
 
@@ -1124,33 +955,41 @@ func readAsepriteFile(assets embed.FS, filePath string) (*Header, []Frame, error
 
 		for j := 0; j < int(frameHeader.NumberOfChunks()); j++ {
 			chunk := Chunk{}
+			chunk.Offset = fileSize - int64(reader.Len())
 
 			// Chunk size info (takes 4 bytes to store it)
 			err = binary.Read(reader, binary.LittleEndian, &chunk.ChunkSize)
 			if err != nil {
-				return nil, nil, err
+				return truncated(i, frameOffset, newChunkError(i, chunk, err))
 			}
 
 			// Chunk type info (takes 2 bytes to store it)
 			err = binary.Read(reader, binary.LittleEndian, &chunk.ChunkType)
 			if err != nil {
-				return nil, nil, err
+				return truncated(i, frameOffset, newChunkError(i, chunk, err))
 			}
 
 			// Check if the chunk is valid
 			if !chunk.IsValid() {
-				return nil, nil, fmt.Errorf("invalid chunk detected: size %d", chunk.ChunkSize)
+				return nil, nil, nil, newChunkError(i, chunk, fmt.Errorf("invalid chunk detected: size %d", chunk.ChunkSize))
 			}
 
-			chunk.ChunkData = make([]BYTE, chunk.ChunkSize-6) // 6 bytes are already read (4 bytes for ChunkSize + 2 bytes for ChunkType)
-			err = binary.Read(reader, binary.LittleEndian, &chunk.ChunkData)
-			if err != nil {
-				return nil, nil, err
+			// The chunk data can't be longer than what is left in the file
+			if int64(chunk.ChunkSize-6) > int64(reader.Len()) {
+				return truncated(i, frameOffset, newChunkError(i, chunk, fmt.Errorf("%w: size %d, %d bytes left", ErrTruncatedChunk, chunk.ChunkSize, reader.Len())))
+			}
+
+			// 6 bytes are already read (4 bytes for ChunkSize + 2 bytes for ChunkType)
+			dataOffset := fileSize - int64(reader.Len())
+			dataEnd := dataOffset + int64(chunk.ChunkSize-6)
+			chunk.ChunkData = fileContent[dataOffset:dataEnd:dataEnd]
+			if _, err := reader.Seek(int64(chunk.ChunkSize-6), io.SeekCurrent); err != nil {
+				return nil, nil, nil, newChunkError(i, chunk, err)
 			}
 
 			// Check if the chunk size matches the length of the chunk data
 			if chunk.ChunkSize != uint32(len(chunk.ChunkData)+6) {
-				return nil, nil, fmt.Errorf("chunk size mismatch: expected %d, got %d", chunk.ChunkSize, len(chunk.ChunkData)+6)
+				return nil, nil, nil, fmt.Errorf("chunk size mismatch: expected %d, got %d", chunk.ChunkSize, len(chunk.ChunkData)+6)
 			}
 
 			// Append the chunk to the list of chunks
@@ -1161,7 +1000,12 @@ func readAsepriteFile(assets embed.FS, filePath string) (*Header, []Frame, error
 		}
 
 		// Check if the total chunk size plus frame header size equals BytesInFrame
-		checkFrameSize(totalChunkSize, frameHeader)
+		if err := checkFrameSize(totalChunkSize, frameHeader); err != nil {
+			if options.Strict {
+				return nil, nil, nil, fmt.Errorf("frame %d: %w", i, err)
+			}
+			warnings = append(warnings, fmt.Errorf("frame %d: %w", i, err))
+		}
 
 		// Create a Frame struct and append it to the frames slice
 		frame := Frame{
@@ -1172,26 +1016,17 @@ func readAsepriteFile(assets embed.FS, filePath string) (*Header, []Frame, error
 	}
 
 	// Check if there are any bytes left non-parsed
-	currentOffset, err := reader.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return nil, nil, err
-	}
-	if currentOffset < fileSize {
-		fmt.Printf("Warning: %d bytes left non-parsed\n", fileSize-currentOffset)
-		panic("Not all bytes were parsed")
+	if reader.Len() > 0 {
+		err := fmt.Errorf("%d bytes left non-parsed", reader.Len())
+		if options.Strict {
+			return nil, nil, nil, err
+		}
+		warnings = append(warnings, err)
 	}
 
-	return header, frames, nil
+	return header, frames, warnings, nil
 }
 
-// From https://github.com/aseprite/aseprite/blob/main/docs/ase-file-specs.md#references
-
-// Function to parse FLI color chunks
-// Usage:
-// fliData := []byte{11, 0, 0, 0, 255, 0, 0, 0, 255, 0, 0, 0, 255} // Example FLI color chunk data
-// colorChunk, err := parseFLIColorChunk(fliData)
-// log.Printf("Parsed FLI Color Chunk: %+v\n", colorChunk)
-
 type Chucnk0x2018 struct {
 	NumberOfTags WORD    // 2 bytes
 	Reserved     [8]BYTE // 8 bytes
@@ -1208,6 +1043,21 @@ const (
 	PingPongReverse                               // 3 = ping-pong reverse
 )
 
+var directionNames = map[LoopAnimationDirection]string{
+	Forward:         "Forward",
+	Reverse:         "Reverse",
+	PingPong:        "Ping-pong",
+	PingPongReverse: "Ping-pong reverse",
+}
+
+// String returns the name of the direction
+func (d LoopAnimationDirection) String() string {
+	if name, exists := directionNames[d]; exists {
+		return name
+	}
+	return "Unknown direction"
+}
+
 // RepeatTimes represents the repeat times for the animation section.
 type RepeatTimes WORD
 
@@ -1321,81 +1171,158 @@ func decompress(data []byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// Define constants for FLI chunk types
+// FLI color chunk types. Aseprite keeps them as its old palette chunks:
+// FLI_COLOR_256 as 0x0004 and FLI_COLOR_64 as 0x0011, see the references of
+// https://github.com/aseprite/aseprite/blob/main/docs/ase-file-specs.md
 const (
-	FLI_COLOR_256 = 11
-	FLI_COLOR_64  = 4
+	FLI_COLOR_256 = 4  // Colors in 0-255
+	FLI_COLOR_64  = 11 // Colors in 0-63
 )
 
-// Define a structure for FLI color chunks
+// FLIColorChunk is a color chunk of FLI/FLC animations, the old palette chunks
 type FLIColorChunk struct {
-	Type   int
-	Colors []PIXEL
+	Type    int      // FLI_COLOR_256 or FLI_COLOR_64
+	Packets []Packet // Colors as stored, every packet after skipping some entries
 }
 
-// Function to parse FLI color chunks
-// Usage:
-// fliData := []byte{11, 0, 0, 0, 255, 0, 0, 0, 255, 0, 0, 0, 255} // Example FLI color chunk data
-// colorChunk, err := parseFLIColorChunk(fliData)
-// log.Printf("Parsed FLI Color Chunk: %+v\n", colorChunk)
-func parseFLIColorChunk(data []byte) (*FLIColorChunk, error) {
-	if len(data) < 2 {
-		return nil, errors.New("data too short")
+// parseFLIColorChunk parses a FLI color chunk of the given type, the packets of
+// the old palette chunks
+func parseFLIColorChunk(chunkType int, data []byte) (*FLIColorChunk, error) {
+	if chunkType != FLI_COLOR_256 && chunkType != FLI_COLOR_64 {
+		return nil, fmt.Errorf("unknown FLI color chunk type: %d", chunkType)
 	}
+	chunk, err := parseChunk0x0004(data)
+	if err != nil {
+		return nil, err
+	}
+	return &FLIColorChunk{Type: chunkType, Packets: chunk.Packets}, nil
+}
 
-	chunkType := int(data[0])
-	var colors []PIXEL
-
-	switch chunkType {
-	case FLI_COLOR_256:
-		colors = make([]PIXEL, 256)
-		for i := 0; i < 256; i++ {
-			colors[i] = PIXEL{
-				RGBA: [4]BYTE{data[1+i*3], data[2+i*3], data[3+i*3], 255},
-			}
-		}
-	case FLI_COLOR_64:
-		colors = make([]PIXEL, 64)
-		for i := 0; i < 64; i++ {
-			colors[i] = PIXEL{
-				RGBA: [4]BYTE{data[1+i*3], data[2+i*3], data[3+i*3], 255},
-			}
-		}
-	default:
-		return nil, errors.New("unknown FLI color chunk type")
+// fliColorChunkType returns the FLI color chunk type of an old palette chunk
+func fliColorChunkType(chunkType WORD) int {
+	if chunkType == 0x0011 {
+		return FLI_COLOR_64
 	}
+	return FLI_COLOR_256
+}
 
-	return &FLIColorChunk{
-		Type:   chunkType,
-		Colors: colors,
-	}, nil
+// Palette writes the colors of the chunk over palette, growing it as needed,
+// with 6-bit colors scaled to 8 bits.
+func (c *FLIColorChunk) Palette(palette color.Palette) color.Palette {
+	return applyOldPalette(palette, c.Packets, c.Type == FLI_COLOR_64)
 }
 
 type Animation struct {
 	TotalFrames int
 	Index       int
-	Duration    []time.Duration // how long the current frame should be displayed
-	LastChange  time.Time       // is updated to the current time each time the frame changes
+	Duration    []time.Duration        // how long every frame is displayed, the Durations of the tag
+	LastChange  time.Time              // is updated to the current time each time Advance or Reset change the frame (never by Update)
+	LoopStart   int                    // first frame of the looping section (frames before it are an intro played once)
+	Direction   LoopAnimationDirection // playback direction of the frames
+	Repeat      RepeatTimes            // times the frames are played before holding the final one (0 = infinite)
+	Triggers    [][]Trigger            // triggers of every frame, the Triggers of the tag, see OnTrigger
+
+	OnFrameChanged func(frame int) // called with the new frame index every time the frame changes
+	OnLoop         func()          // called every time the frames start over (or a ping-pong turns around)
+	OnComplete     func()          // called once the frames have been played Repeat times
+	OnTrigger      func(Trigger)   // called with every trigger of a frame each time the animation enters it, starts, loops, is reset or seeks to it
+
+	playing  bool          // Advanced by Update
+	speed    float64       // Playback speed factor set with SetSpeed, 0 means 1
+	elapsed  time.Duration // Time spent in the current frame
+	backward bool          // Ping-pong animation is going back to the first frame
+	passes   int           // Times the frames have been played
+	finished bool          // Played Repeat times
+	fired    bool          // Triggers of the current frame fired, see Play
 }
 
 type ASEFile struct {
-	State   []ASETag
-	Tileset ASETileset
-	Sprites Sprites
+	Header        Header
+	Palette       color.Palette
+	Layers        []ASELayer
+	State         []ASETag
+	Tileset       ASETileset
+	Tilemaps      []ASETilemap             // Tilemaps of every frame, in frame order, all layers together (see TilemapLayers)
+	Images        []image.Image            // Composited image layers of every frame (canvas-sized), in frame order
+	Indices       [][]byte                 // Palette indices of every image (row by row), only for indexed sprites with WithPaletteIndices
+	TrimOffsets   []image.Point            // Position in the canvas of every trimmed image, only with WithTrim
+	Durations     []time.Duration          // Duration of every frame
+	LayerFrames   map[string][]image.Image // Canvas-sized frames of every layer by name, untrimmed, only with WithLayerFrames
+	FrameData     []ASEFrame               // What else belongs to every frame, in frame order
+	Slices        []ASESlice
+	Masks         []Mask         // Masks of the deprecated mask chunks of old files
+	CustomChunks  []CustomChunk  // Chunks decoded by the handlers of RegisterChunkHandler, in file order
+	ExternalFiles []ExternalFile // Entries of the external files chunk
+	UserData      *UserData      // Sprite user data, nil if not set
+	ColorProfile  *ColorProfile  // Color profile of the pixels, nil if the file has none
+	Warnings      []error        // Problems skipped while parsing, never set in strict mode
+
+	frameCels      [][]frameCel         // Decoded cels of every frame
+	showHidden     bool                 // Hidden layers are composited, see WithHiddenLayers
+	colorConverted bool                 // Colors converted from ColorProfile to sRGB, see WithColorManagement
+	paletteNames   []string             // Names of the palette colors (from the 0x2019 chunk)
+	unsupported    map[Feature][]string // Unsupported features found while parsing
+}
+
+// ASEFrame holds what belongs to a frame of the file besides its image.
+type ASEFrame struct {
+	// RawChunks are the chunks of the frame that asevre doesn't decode
+	// (unknown types and paths) as they were read, so EncodeAseprite writes
+	// them back and nothing authored in newer versions of Aseprite is lost.
+	RawChunks []Chunk
 }
 
 type ASETag struct {
 	Name          string
+	FromFrame     int                    // First frame of the tag
+	ToFrame       int                    // Last frame of the tag (inclusive)
+	Direction     LoopAnimationDirection // Loop animation direction
+	Repeat        RepeatTimes            // Repeat N times
+	Color         color.Color            // Tag color shown in the editor timeline
+	Extra         BYTE                   // Extra byte of the tag (zero unless written by other tools)
 	Tilemaps      []ASETilemap
-	Frames        []*ebiten.Image
-	FrameDuration [][]time.Duration
+	Frames        []image.Image   // Composited image layers of every frame of the tag
+	Durations     []time.Duration // Duration of every frame of the tag, played by Animation
+	Triggers      [][]Trigger     // Triggers of every frame of the tag, nil if it has none
 	HasAnimations bool
 	Animation     Animation
+	UserData      *UserData // Tag user data, nil if not set
+
+	// Deprecated: use Durations. FrameDuration has an entry per tag of the
+	// file, only the one at the index of the tag is set (to Durations).
+	FrameDuration [][]time.Duration
+
+	synthesized bool // Default tag of a file without tags, not written back
 }
 
 type ASETileset struct {
+	ID                    int // Tileset ID
 	Tiles                 []image.Image
 	TileHeight, TileWidth int
+	Flags                 TilesetFlags // Flags of the tileset chunk of the sprite
+	Name                  string       // Tileset name
+	BaseIndex             int          // Number the editor shows for the first tile
+	ExternalFileID        uint32       // Entry of ASEFile.ExternalFiles holding the tiles, when Flags.IncludeLinkToExternalFile is set
+	ExternalTilesetID     int          // ID of the tileset in the external file
+	UserData              *UserData    // Tileset user data, nil if not set
+	TileUserData          []*UserData  // User data of every tile, nil entries for tiles without user data
+
+	indices [][]byte   // Palette indices of every tile, only for indexed sprites
+	flips   *flipCache // Flipped versions of the tiles, see FlippedTile
+}
+
+// isEmptyTile checks if a tile ID is the empty tile: tile 0 when the flags
+// say so, or when the tileset has no flags (built in code, see encodeChunk0x2023)
+func (t *ASETileset) isEmptyTile(id int) bool {
+	return id == 0 && (t.Flags.TileIDZeroAsEmptyTile || t.Flags == TilesetFlags{})
+}
+
+// tileProperties returns a copy of the properties of a tile for a tile instance
+func (t *ASETileset) tileProperties(id int) map[string]string {
+	if id < 0 || id >= len(t.TileUserData) {
+		return nil
+	}
+	return t.TileUserData[id].TileProperties()
 }
 
 type ASETilemap struct {
@@ -1455,8 +1382,54 @@ func parseChunk0x2018(data []byte) (*Chucnk0x2018, error) {
 	return chunk, nil
 }
 
-func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
-	asepriteFile := ASEFile{}
+// ParseAseprite parses an .aseprite or .ase file embedded with go:embed.
+//
+// Files cut before their last frame return the frames read completely along
+// with a *TruncatedError (an error without any frame in strict mode), so
+// partial assets can still be inspected.
+func ParseAseprite(assets embed.FS, f string, opts ...ParseOption) (ASEFile, error) {
+	return parseAseprite(assets, f, opts...)
+}
+
+// ParseAsepriteFS parses an .aseprite or .ase file from any file system
+// (embed.FS, os.DirFS, fstest.MapFS, a zip.Reader...). Linked files are read
+// from the same file system, relative to name.
+func ParseAsepriteFS(fsys fs.FS, name string, opts ...ParseOption) (ASEFile, error) {
+	return parseAseprite(fsys, name, opts...)
+}
+
+// LoadAseprite parses an .aseprite or .ase file from disk.
+func LoadAseprite(filePath string, opts ...ParseOption) (ASEFile, error) {
+	return parseAseprite(os.DirFS(filepath.Dir(filePath)), filepath.Base(filePath), opts...)
+}
+
+// ParseAsepriteBytes parses the content of an .aseprite or .ase file held in
+// memory, e.g. embedded with go:embed as a []byte, without any file I/O. The
+// parsed file shares the chunk data with data (ASEFile.FrameData), which
+// must not be modified afterwards. Linked files (external tilesets) can't be
+// read: they are reported by Unsupported.
+func ParseAsepriteBytes(data []byte, opts ...ParseOption) (ASEFile, error) {
+	if data == nil {
+		// parseAsepriteData reads a file for nil data, this is empty data
+		data = []byte{}
+	}
+	return parseAsepriteData(nil, "", data, opts...)
+}
+
+// parseAseprite parses an .aseprite or .ase file from any file system
+func parseAseprite(assets fs.FS, f string, opts ...ParseOption) (ASEFile, error) {
+	return parseAsepriteData(assets, f, nil, opts...)
+}
+
+// parseAsepriteData parses the content of an .aseprite or .ase file, read
+// from file f of assets when data is nil
+func parseAsepriteData(assets fs.FS, f string, data []byte, opts ...ParseOption) (ASEFile, error) {
+	options := newParseOptions(opts)
+	resolve := options.ResolveExternal
+	if resolve == nil {
+		resolve = defaultResolver(assets, f, options)
+	}
+	asepriteFile := ASEFile{showHidden: options.HiddenLayers}
 	tileset := ASETileset{}
 	tilemaps := []ASETilemap{}
 	states := []ASETag{}
@@ -1464,98 +1437,415 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 	framesDuration := []time.Duration{}
 
 	var palette []color.Color
-	header, frames, err := readAsepriteFile(assets, f)
+	var newPalette []color.Color
+	var paletteNames []string
+	logger := options.Logger.With("file", f)
+	var header *Header
+	var frames []Frame
+	var warnings []error
+	var err error
+	if data != nil {
+		header, frames, warnings, err = readAsepriteData(data, options)
+	} else {
+		header, frames, warnings, err = readAsepriteFile(assets, f, options)
+	}
+	var truncated *TruncatedError
+	if errors.As(err, &truncated) {
+		// The frames read completely are parsed, the file ends after them
+		logger.Warn("file truncated", "error", err)
+		header.FrameCount = WORD(len(frames))
+	} else if err != nil {
+		logger.Debug("reading file failed", "error", err)
+		return ASEFile{}, err
+	}
+	asepriteFile.Warnings = warnings
+	for _, warning := range warnings {
+		logger.Warn("file does not follow the specification", "error", warning)
+	}
+
+	// skipChunk handles a chunk that can't be decoded: an error in strict mode,
+	// otherwise a warning and the rest of the file is still parsed
+	skipChunk := func(frame int, chunk Chunk, err error) error {
+		err = newChunkError(frame, chunk, err)
+		if options.Strict {
+			return err
+		}
+		logger.Warn("chunk skipped", "error", err)
+		asepriteFile.Warnings = append(asepriteFile.Warnings, err)
+		return nil
+	}
+
+	// Frames left out by the frame and tag selection, their cels are not decoded
+	selected, err := selectFrames(frames, options)
 	if err != nil {
-		fmt.Println("Error:", err)
 		return ASEFile{}, err
 	}
 
-	// Parse the palette
-	for _, frame := range frames {
+	// Every frame is composited on a canvas-sized image
+	budget := budget{limits: options.Limits}
+	if err := budget.take("canvas", int(header.Width), int(header.Height), len(frames)); err != nil {
+		return ASEFile{}, err
+	}
+
+	asepriteFile.FrameData = make([]ASEFrame, len(frames))
+
+	// Parse the palette and the layers
+	for frameIndex, frame := range frames {
 		framesDuration = append(framesDuration, time.Duration(frame.Header.FrameDuration)*time.Millisecond)
 		for _, chunk := range frame.Chunks {
+			// Chunks with a registered handler are never unknown
+			handler, handled := chunkHandler(chunk.ChunkType)
+			if handled {
+				value, err := handler(*header, frameIndex, chunk.ChunkData)
+				if err != nil {
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+				} else {
+					asepriteFile.CustomChunks = append(asepriteFile.CustomChunks, CustomChunk{Frame: frameIndex, Type: chunk.ChunkType, Value: value})
+				}
+			}
+
+			if !isKnownChunkType(chunk.ChunkType) {
+				asepriteFile.FrameData[frameIndex].RawChunks = append(asepriteFile.FrameData[frameIndex].RawChunks, chunk)
+				if handled {
+					continue
+				}
+				if err := skipChunk(frameIndex, chunk, errors.New("unknown chunk type")); err != nil {
+					return ASEFile{}, err
+				}
+				continue
+			}
 
 			switch chunk.ChunkType {
-			case 0x0004:
-				paletteChunk, err := parseChunk0x0004(chunk.ChunkData)
+			case 0x2019:
+				paletteChunk, err := parseChunk0x2019(chunk.ChunkData)
 				if err != nil {
-					fmt.Println("Error parsing 0x0004 chunk:", err)
-					os.Exit(1)
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
 				}
 
-				for _, packet := range paletteChunk.Packets {
-					for _, c := range packet.Colors {
-						// Create a new color
+				// Resize the palette, keeping the colors that are not changed. Every
+				// color of the new palette is either kept or set by the chunk.
+				size := int(paletteChunk.NewPaletteSize)
+				if int(paletteChunk.FirstColor) > len(newPalette) || size > max(len(newPalette), int(paletteChunk.LastColor)+1) {
+					err := fmt.Errorf("palette size of %d with colors %d-%d past the %d colors defined", size, paletteChunk.FirstColor, paletteChunk.LastColor, len(newPalette))
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
+				}
+				if header.ColorDepth == ColorDepthIndexed && size > 256 {
+					if err := skipChunk(frameIndex, chunk, fmt.Errorf("palette of %d colors for an indexed sprite", size)); err != nil {
+						return ASEFile{}, err
+					}
+					continue
+				}
+				if err := budget.take("palette", size, 1, 1); err != nil {
+					return ASEFile{}, err
+				}
+				for len(newPalette) < size {
+					newPalette = append(newPalette, color.NRGBA{})
+					paletteNames = append(paletteNames, "")
+				}
+				newPalette = newPalette[:size]
+				paletteNames = paletteNames[:size]
 
-						newRGBAColor := color.RGBA{R: c.Red, G: c.Green, B: c.Blue, A: 255}
+				for i, entry := range paletteChunk.Entries {
+					index := int(paletteChunk.FirstColor) + i
+					if index >= size {
+						break
+					}
+					newPalette[index] = entry.Color()
+					paletteNames[index] = string(entry.Name.Chars)
+				}
 
-						// 255 alpha value means: the color is fully opaque (not transparent)
-						// but if the  RGB is 0, then the color is fully transparent
-						if newRGBAColor.R == 0 && newRGBAColor.G == 0 && newRGBAColor.B == 0 {
-							newRGBAColor.A = 0
-						}
+			case 0x2004:
+				layerChunk, err := parseChunk0x2004(chunk.ChunkData, header.Flags)
+				if err != nil {
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					// Keep the index of the next layers, the cels refer to them by index
+					asepriteFile.Layers = append(asepriteFile.Layers, ASELayer{Index: len(asepriteFile.Layers), Flags: LayerFlagVisible, Opacity: 255})
+					continue
+				}
+				asepriteFile.Layers = append(asepriteFile.Layers, newASELayer(len(asepriteFile.Layers), layerChunk, header))
 
-						// Append the new color to the palette
-						palette = append(palette, newRGBAColor)
+			case 0x2007:
+				colorProfileChunk, err := parse0x2007(chunk.ChunkData)
+				if err != nil {
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
 					}
+					continue
 				}
+				asepriteFile.ColorProfile = newColorProfile(colorProfileChunk)
+
+			case 0x2008:
+				externalFilesChunk, err := parseChunk0x2008(chunk.ChunkData)
+				if err != nil {
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
+				}
+				asepriteFile.ExternalFiles = append(asepriteFile.ExternalFiles, externalFilesChunk.Entries...)
+
+			case 0x2016:
+				maskChunk, err := parseChunk0x2016(chunk.ChunkData)
+				if err != nil {
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
+				}
+				asepriteFile.Masks = append(asepriteFile.Masks, newMask(maskChunk))
+
+			case 0x2017:
+				asepriteFile.FrameData[frameIndex].RawChunks = append(asepriteFile.FrameData[frameIndex].RawChunks, chunk)
+				asepriteFile.noteUnsupported(FeaturePath, fmt.Sprintf("frame %d, %d bytes", frameIndex, len(chunk.ChunkData)))
+
+			case 0x0004, 0x0011:
+				colorChunk, err := parseFLIColorChunk(fliColorChunkType(chunk.ChunkType), chunk.ChunkData)
+				if err != nil {
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
+				}
+				palette = colorChunk.Palette(palette)
+
+			}
+		}
+	}
 
+	// The new palette chunk has alpha and names, prefer it over the old one
+	if newPalette != nil {
+		palette = newPalette
+		asepriteFile.paletteNames = paletteNames
+	}
+
+	// Colors in another profile are converted to sRGB if asked, otherwise they
+	// are shown as if they were sRGB
+	var converter *colorConverter
+	if !asepriteFile.ColorProfile.IsSRGB() {
+		if options.ColorManagement {
+			converter, err = newColorConverter(asepriteFile.ColorProfile)
+			if err != nil {
+				if options.Strict {
+					return ASEFile{}, err
+				}
+				asepriteFile.Warnings = append(asepriteFile.Warnings, err)
 			}
 		}
+		if converter == nil {
+			asepriteFile.noteUnsupported(FeatureColorProfile, asepriteFile.ColorProfile.String())
+		}
+	}
+	if converter != nil {
+		asepriteFile.colorConverted = true
+		for i, c := range palette {
+			palette[i] = converter.convertColor(c)
+		}
 	}
 
-	// fmt.Println("======================")
-	// for i, k := range framesDuration {
-	// 	fmt.Printf("Frame %d: %v\n", i, k)
-	// }
-	// fmt.Println("======================")
+	// Frames marked as the start of a loop section through cel user data
+	loopStarts := map[int]bool{}
+
+	// Decoded cels of every frame
+	frameCels := make([][]frameCel, len(frames))
+
+	// Entity the next user data chunk is attached to
+	target := userDataNone
+	targetIndex := 0
+	layerCount := 0
+
+	// User data of the tags, in tag order
+	var tagUserData []*UserData
 
-	// // Print palette colors
-	// for i, c := range palette {
-	// 	fmt.Printf("Color %d: %v\n", i, c)
-	// }
+	// Image cels to decode once every chunk is read, and the cels linked to them
+	var celJobs []celJob
+	var celLinks []celLink
+
+	// Layers left out by the layer filter, their cels are skipped
+	excluded := asepriteFile.excludedLayers(options.KeepLayer)
 
 	// Parse the tileset and tilemap
-	for _, frame := range frames {
+	for frameIndex, frame := range frames {
+		// User data never refers to an entity of another frame
+		target = userDataNone
+	chunks:
 		for _, chunk := range frame.Chunks {
+			// User data only follows the chunk of its entity (the cel extra
+			// chunk sits between a cel and its user data)
+			if chunk.ChunkType != 0x2020 && chunk.ChunkType != 0x2006 {
+				target = userDataNone
+			}
 
 			switch chunk.ChunkType {
 
+			case 0x2020:
+				userDataChunk, err := parseChunk0x2020(chunk.ChunkData)
+				if err != nil {
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
+				}
+				// Entities without user data still get an empty chunk (e.g. tags, tiles)
+				var userData *UserData
+				if userDataChunk.Flags != 0 {
+					userData = newUserData(userDataChunk)
+				}
+
+				switch target {
+				case userDataSprite:
+					asepriteFile.UserData = userData
+				case userDataLayer:
+					if targetIndex < len(asepriteFile.Layers) {
+						asepriteFile.Layers[targetIndex].UserData = userData
+					}
+				case userDataCel:
+					frameCels[frameIndex][targetIndex].userData = userData
+					if isLoopStart(userData) {
+						loopStarts[frameIndex] = true
+					}
+				case userDataTags:
+					// Every tag has its own user data chunk, keep collecting
+					tagUserData = append(tagUserData, userData)
+					continue
+				case userDataTileset:
+					tileset.UserData = userData
+					// The user data of every tile follows the one of the tileset
+					target = userDataTile
+					targetIndex = 0
+					continue
+				case userDataTile:
+					if targetIndex < len(tileset.Tiles) {
+						if tileset.TileUserData == nil {
+							tileset.TileUserData = make([]*UserData, len(tileset.Tiles))
+						}
+						tileset.TileUserData[targetIndex] = userData
+					}
+					targetIndex++
+					continue
+				case userDataSlice:
+					asepriteFile.Slices[targetIndex].UserData = userData
+				}
+				target = userDataNone
+
+			case 0x2019:
+				// User data after the palette of the first frame belongs to the sprite
+				if frameIndex == 0 {
+					target = userDataSprite
+				}
+
+			case 0x2004:
+				target = userDataLayer
+				targetIndex = layerCount
+				layerCount++
+
+			case 0x2018:
+				target = userDataTags
+
+			case 0x2006:
+				celExtraChunk, err := parseChunk0x2006(chunk.ChunkData)
+				if err != nil {
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
+				}
+				if target == userDataCel && celExtraChunk.Flags&CelExtraPreciseBounds != 0 {
+					frameCels[frameIndex][targetIndex].bounds = &CelBounds{
+						X:      fixedToFloat(celExtraChunk.X),
+						Y:      fixedToFloat(celExtraChunk.Y),
+						Width:  fixedToFloat(celExtraChunk.Width),
+						Height: fixedToFloat(celExtraChunk.Height),
+					}
+				}
+
+			case 0x2022:
+				sliceChunk, err := parseChunk0x2022(chunk.ChunkData)
+				if err != nil {
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
+				}
+				asepriteFile.Slices = append(asepriteFile.Slices, newASESlice(sliceChunk))
+				target = userDataSlice
+				targetIndex = len(asepriteFile.Slices) - 1
+
 			case 0x2023:
 
 				tilesetChunk, err := parseChunk0x2023(chunk.ChunkData)
 				if err != nil {
-					fmt.Println("Error parsing 0x2023 chunk:", err)
-					os.Exit(1)
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
 				}
 
-				decompressed, err := decompressZlib(tilesetChunk.CompressedTilesetImage)
-				if err != nil {
-					return ASEFile{}, fmt.Errorf("error decompressing Tileset Image data: %v", err)
+				// Tiles stored in an external file are loaded through the resolver
+				if !tilesetChunk.GetTilesetFlags().IncludeTilesInsideFile {
+					detail := fmt.Sprintf("tileset %d (%s)", tilesetChunk.TilesetID, tilesetChunk.TilesetName.Chars)
+					externalFile, ok := asepriteFile.externalFile(tilesetChunk.ExternalFileID)
+					if !tilesetChunk.GetTilesetFlags().IncludeLinkToExternalFile || !ok {
+						asepriteFile.noteUnsupported(FeatureExternalTileset, detail)
+						continue
+					}
+					external, err := resolveTileset(resolve, externalFile, int(tilesetChunk.ExternalTilesetID))
+					if err != nil {
+						asepriteFile.noteUnsupported(FeatureExternalTileset, fmt.Sprintf("%s: %v", detail, err))
+						continue
+					}
+					tileset = external
+					tileset.ID = int(tilesetChunk.TilesetID)
+					tileset.Flags = tilesetChunk.GetTilesetFlags()
+					tileset.Name = string(tilesetChunk.TilesetName.Chars)
+					tileset.BaseIndex = int(tilesetChunk.BaseIndex)
+					tileset.ExternalFileID = tilesetChunk.ExternalFileID
+					tileset.ExternalTilesetID = int(tilesetChunk.ExternalTilesetID)
+					target = userDataTileset
+					continue
 				}
 
 				tileWidth := int(tilesetChunk.TileWidth)
 				tileHeight := int(tilesetChunk.TileHeight)
 				numTiles := int(tilesetChunk.NumberOfTiles)
-				var tilesetTiles []byte
-				tileSize := tileWidth * tileHeight
+				bytesPerPixel := int(header.ColorDepth) / 8
+				tileSize := tileWidth * tileHeight * bytesPerPixel
 
-				// Loop through the decompressed data to extract each tile
-				for i := 0; i < len(decompressed); i += tileSize {
-					// Ensure we don't go out of bounds
-					if i+tileSize > len(decompressed) {
-						break
+				if tileSize == 0 {
+					if err := skipChunk(frameIndex, chunk, fmt.Errorf("invalid tile size: %dx%d", tileWidth, tileHeight)); err != nil {
+						return ASEFile{}, err
 					}
+					continue
+				}
+				if err := budget.take("tileset", tileWidth, tileHeight, numTiles); err != nil {
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
+				}
 
-					// Extract the current tile
-					tile := decompressed[i : i+tileSize]
-
-					// Append the current tile to the tilesetTile slice
-					tilesetTiles = append(tilesetTiles, tile...)
+				decompressed, err := decompressZlib(tilesetChunk.CompressedTilesetImage, numTiles*tileSize)
+				if err != nil {
+					if err := skipChunk(frameIndex, chunk, fmt.Errorf("error decompressing Tileset Image data: %v", err)); err != nil {
+						return ASEFile{}, err
+					}
+					continue
 				}
 
-				if numTiles != len(tilesetTiles)/tileSize {
-					return ASEFile{}, fmt.Errorf("number of tiles does not match the number of tiles extracted from the tileset image data")
+				if len(decompressed) != numTiles*tileSize {
+					if err := skipChunk(frameIndex, chunk, fmt.Errorf("number of tiles does not match the number of tiles extracted from the tileset image data")); err != nil {
+						return ASEFile{}, err
+					}
+					continue
 				}
 
 				// Create a  PNG image for each tile
@@ -1563,74 +1853,116 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 
 				tileImages := make([]image.Image, numTiles)
 
-				for tile := 0; tile < numTiles; tile++ {
-					// Initialize all the pixels of the tile image to be transparent
-					tileImage := image.NewRGBA(image.Rect(0, 0, tileWidth, tileHeight))
+				// Indexed tiles also keep their palette indices
+				var tileIndices [][]byte
+				if header.ColorDepth == ColorDepthIndexed {
+					tileIndices = make([][]byte, numTiles)
+				}
 
-					tileSize := tileWidth * tileHeight
+				for tile := 0; tile < numTiles; tile++ {
+					// Every tile is a slice of the decompressed data, never copied
 					start := tile * tileSize
-					end := start + tileSize
+					isolatedTile := decompressed[start : start+tileSize : start+tileSize]
 
-					// Ensure the end index does not exceed the length of the tilesetTiles data
-					if end > len(tilesetTiles) {
-						fmt.Println("Tile number out of range")
-						return ASEFile{}, fmt.Errorf("tile number out of range")
+					var tileImage *image.NRGBA
+					switch header.ColorDepth {
+					case ColorDepthRGBA:
+						// RGBA pixels have the layout of NRGBA images
+						tileImage = &image.NRGBA{Pix: isolatedTile, Stride: tileWidth * 4, Rect: image.Rect(0, 0, tileWidth, tileHeight)}
+					default:
+						tileImage = image.NewNRGBA(image.Rect(0, 0, tileWidth, tileHeight))
+						for i := 0; i < tileHeight; i++ {
+							for j := 0; j < tileWidth; j++ {
+								offset := (i*tileWidth + j) * bytesPerPixel
+								t := isolatedTile[offset : offset+bytesPerPixel]
+
+								var col color.Color
+								if header.ColorDepth == ColorDepthGrayscale {
+									col = grayscaleColor(t[0], t[1])
+								} else {
+									// Get the color from the palette
+									col = indexedColor(palette, t[0], int(header.TransparentIdx))
+								}
+								tileImage.Set(j, i, col)
+							}
+						}
 					}
 
-					// Extract the tile
-					isolatedTile := tilesetTiles[start:end]
-
-					// Print the tile in a readable format
-					for i := 0; i < tileHeight; i++ {
-						for j := 0; j < tileWidth; j++ {
-							t := isolatedTile[i*tileWidth+j]
-							// fmt.Printf("%x ", t)
-
-							// Set the pixels of the PNG Image
-							// Get the color from the palette
-							color := palette[t]
-
-							// Set the pixel color in the tile image
-							tileImage.Set(j, i, color)
-
-						}
+					// Indexed tiles use the converted palette
+					if converter != nil && header.ColorDepth != ColorDepthIndexed {
+						converter.convertImage(tileImage)
 					}
 
 					// append the image to the tileImages slice
 					tileImages[tile] = tileImage
+					if tileIndices != nil {
+						tileIndices[tile] = isolatedTile
+					}
 				}
 
 				tileset = ASETileset{
-					Tiles:      tileImages,
-					TileHeight: tileHeight,
-					TileWidth:  tileWidth,
+					ID:                int(tilesetChunk.TilesetID),
+					Tiles:             tileImages,
+					TileHeight:        tileHeight,
+					TileWidth:         tileWidth,
+					Flags:             tilesetChunk.GetTilesetFlags(),
+					Name:              string(tilesetChunk.TilesetName.Chars),
+					BaseIndex:         int(tilesetChunk.BaseIndex),
+					ExternalFileID:    tilesetChunk.ExternalFileID,
+					ExternalTilesetID: int(tilesetChunk.ExternalTilesetID),
+					indices:           tileIndices,
 				}
+				target = userDataTileset
 
 			case 0x2005:
 				celChunk, err := parseChunk0x2005(chunk.ChunkData)
 				if err != nil {
-					fmt.Println("Error parsing 0x2005 chunk:", err)
-					os.Exit(1)
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
 				}
 
-				// fmt.Printf("Cel Chunk Position X: %d, Y: %d\n", celChunk.XPosition, celChunk.YPosition)
+				if int(celChunk.LayerIndex) < len(excluded) && excluded[celChunk.LayerIndex] {
+					continue
+				}
 
 				// Read specific fields based on CelType
 				switch celChunk.CelType {
-				case RawImageData:
-					// Raw Image Data
-					rawImage := RawImage{}
-					rawImage.Width = WORD(celChunk.Data[0]) | WORD(celChunk.Data[1])<<8
-					rawImage.Height = WORD(celChunk.Data[2]) | WORD(celChunk.Data[3])<<8
-					rawImage.Pixels = celChunk.Data[4:]
-					// fmt.Printf("      > Raw Image Data: %dx%d pixels\n", rawImage.Width, rawImage.Height)
 				case LinkedCelData:
 					// Linked Cel Data
 					linkedCel := LinkedCel{}
 					linkedCel.FramePosition = WORD(celChunk.Data[0]) | WORD(celChunk.Data[1])<<8
-					// fmt.Printf("      > Linked Cel Data: Frame Position: %d\n", linkedCel.FramePosition)
-				case CompressedImageData:
-					// Compressed Image Data
+
+					// The cel shares the data (pixels or tiles, user data) of the cel of the same layer in an earlier frame
+					linkedIndex := celIndex(frameCels, int(linkedCel.FramePosition), int(celChunk.LayerIndex))
+					if linkedIndex < 0 || int(linkedCel.FramePosition) >= frameIndex {
+						if err := skipChunk(frameIndex, chunk, fmt.Errorf("frame %d, layer %d: linked cel refers to missing frame %d", frameIndex, celChunk.LayerIndex, linkedCel.FramePosition)); err != nil {
+							return ASEFile{}, err
+						}
+						continue
+					}
+					linked := frameCels[linkedCel.FramePosition][linkedIndex]
+					linked.x = int(celChunk.XPosition)
+					linked.y = int(celChunk.YPosition)
+					linked.opacity = celChunk.OpacityLevel
+					linked.zIndex = int(celChunk.ZIndex)
+					if linked.tilemap != nil {
+						tilemaps = append(tilemaps, *linked.tilemap)
+					}
+					frameCels[frameIndex] = append(frameCels[frameIndex], linked)
+					target = userDataCel
+					targetIndex = len(frameCels[frameIndex]) - 1
+
+					// The pixels of the linked cel may not be decoded yet
+					celLinks = append(celLinks, celLink{
+						frame:       frameIndex,
+						index:       targetIndex,
+						linkedFrame: int(linkedCel.FramePosition),
+						linkedIndex: linkedIndex,
+					})
+				case RawImageData, CompressedImageData:
+					// Raw and Compressed Image Data, the same pixels with or without zlib
 
 					// Get GetColorDepthDescription from the header
 					colorDepth := header.GetColorDepthDescription()
@@ -1647,173 +1979,87 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 					}
 
 					if bitsPerPixel == 0 {
-						return ASEFile{}, fmt.Errorf("unknown color depth: %s", colorDepth)
+						if err := skipChunk(frameIndex, chunk, fmt.Errorf("unknown color depth: %s", colorDepth)); err != nil {
+							return ASEFile{}, err
+						}
+						continue
 					}
 
-					// fmt.Println("Color Depth:", colorDepth)
-					// fmt.Println("Bits per Pixel:", bitsPerPixel)
-
 					compressedImage := CompressedImage{}
 					compressedImage.Width = WORD(celChunk.Data[0]) | WORD(celChunk.Data[1])<<8
 					compressedImage.Height = WORD(celChunk.Data[2]) | WORD(celChunk.Data[3])<<8
 					compressedImage.Pixels = celChunk.Data[4:]
-					// fmt.Printf("      > Compressed Image Data: %dx%d pixels\n", compressedImage.Width, compressedImage.Height)
 
-					decompressedPixels, err := decompressZlib(compressedImage.Pixels)
-					if err != nil {
-						return ASEFile{}, fmt.Errorf("error decompressing image data: %v", err)
+					// The transparent index is a regular color in the background layer
+					transparentIdx := int(header.TransparentIdx)
+					if int(celChunk.LayerIndex) < len(asepriteFile.Layers) && asepriteFile.Layers[celChunk.LayerIndex].IsBackground() {
+						transparentIdx = -1
 					}
 
-					var pixels []PIXEL
-
-					rowsOfPixels := make([][]PIXEL, compressedImage.Height)
-					columnsOfPixels := make([]PIXEL, compressedImage.Width)
-
-					// Adjust dimensions to account for transparent rows and columns
-					totalWidth := uint16(celChunk.XPosition) + compressedImage.Width
-					totalHeight := uint16(celChunk.YPosition) + compressedImage.Height
-
-					rowsOfPixels = make([][]PIXEL, totalHeight)
-					for i := range rowsOfPixels {
-						rowsOfPixels[i] = make([]PIXEL, totalWidth)
-					}
-
-					// Iterate over the decompressed pixels
-					// Each pixel has bits per pixel: bitsPerPixel bits
-					// The pixels are stored in rows, from top to bottom, left to right
-					for i := 0; i < len(decompressedPixels); i += bitsPerPixel / 8 {
-						// Ensure we don't go out of bounds
-						if i+bitsPerPixel/8 > len(decompressedPixels) {
-							break
-						}
-
-						// Extract the current pixel
-						pixel := decompressedPixels[i : i+bitsPerPixel/8]
-
-						// Depending of the bitsPerPixel, we can have different color depths
-						// and store them in different ways (RGBA, Grayscale, Indexed) in pixels
-						switch bitsPerPixel {
-						case 32:
-							// RGBA color depth
-							// Each pixel is stored as 4 bytes (32 bits)
-							// The order of the bytes is: RGBA (Red, Green, Blue, Alpha)
-							// The color values are in the range [0, 255]
-							pixels = append(pixels, PIXEL{
-								RGBA: [4]BYTE{pixel[0], pixel[1], pixel[2], pixel[3]},
-							})
-						// case 16:
-						// 	// Grayscale color depth
-						// 	// Each pixel is stored as 2 bytes (16 bits)
-						// 	// The color value is in the range [0, 255]
-						// 	// The grayscale value is stored in the Red channel
-						// 	pixels = append(pixels, PIXEL{
-						// 		Grayscale: pixel[0:2],
-						// 	})
-						case 8:
-							// Indexed color depth
-							// Each pixel is stored as 1 byte (8 bits)
-							// The color value is an index to the palette
-							// The color value is in the range [0, 255]
-							pixels = append(pixels, PIXEL{
-								Indexed: pixel[0],
-							})
+					frameCels[frameIndex] = append(frameCels[frameIndex], frameCel{
+						layerIndex: int(celChunk.LayerIndex),
+						x:          int(celChunk.XPosition),
+						y:          int(celChunk.YPosition),
+						opacity:    celChunk.OpacityLevel,
+						zIndex:     int(celChunk.ZIndex),
+					})
+					target = userDataCel
+					targetIndex = len(frameCels[frameIndex]) - 1
+
+					if err := budget.take("cel", int(compressedImage.Width), int(compressedImage.Height), 1); err != nil {
+						if err := skipChunk(frameIndex, chunk, err); err != nil {
+							return ASEFile{}, err
 						}
+						continue
 					}
 
-					// Reconstruct the pixels
-					// Row by row, from top to bottom, left to right
-					for row := 0; row < int(compressedImage.Height); row++ {
-						// Iterate over the pixels in the row
-						for col := 0; col < int(compressedImage.Width); col++ {
-							// Calculate the offset in the decompressed pixel data
-							offset := row*int(compressedImage.Width) + col
-
-							// Read the pixel
-							pixel := pixels[offset]
-
-							// Store the pixel in the columnsOfPixels slice
-							rowsOfPixels[int16(row)+celChunk.YPosition][int16(col)+celChunk.XPosition] = pixel
-							columnsOfPixels[col] = pixel
-						}
-
-						// // Store the columnsOfPixels slice in the rowsOfPixels slice
-						// rowsOfPixels[row] = make([]PIXEL, len(columnsOfPixels))
-						// copy(rowsOfPixels[row], columnsOfPixels)
-					}
-
-					// Save into a PNG image
-					// Create a new RGBA image with the adjusted dimensions
-					img := image.NewRGBA(image.Rect(0, 0, int(totalWidth), int(totalHeight)))
-
-					// Set the pixels of the PNG Image
-					for i := 0; i < int(totalHeight); i++ {
-						for j := 0; j < int(totalWidth); j++ {
-							p := rowsOfPixels[i][j]
-
-							var col color.Color
-							if bitsPerPixel == 8 {
-								col = palette[p.Indexed]
-							} else {
-								col = color.RGBA{R: p.RGBA[0], G: p.RGBA[1], B: p.RGBA[2], A: p.RGBA[3]}
-							}
-
-							img.Set(j, i, col)
-						}
-					}
-
-					// Save the PNG image to a file
-
-					// // Create a new file
-					// f, err := os.Create(fmt.Sprintf("frame_%d.png", len(frameImages)))
-					// if err != nil {
-					// 	return ASEFile{}, fmt.Errorf("error creating PNG file: %v", err)
-					// }
-
-					// // Encode the PNG image
-					// err = png.Encode(f, img)
-					// if err != nil {
-					// 	return ASEFile{}, fmt.Errorf("error encoding PNG image: %v", err)
-					// }
-
-					// // Close the file
-					// err = f.Close()
-					// if err != nil {
-					// 	return ASEFile{}, fmt.Errorf("error closing PNG file: %v", err)
-					// }
-
-					// Append img to frameImages
-					frameImages = append(frameImages, img)
+					// The pixels are decoded later, concurrently with the other cels
+					celJobs = append(celJobs, celJob{
+						frame:          frameIndex,
+						index:          targetIndex,
+						chunk:          chunk,
+						image:          compressedImage,
+						raw:            celChunk.CelType == RawImageData,
+						bitsPerPixel:   bitsPerPixel,
+						transparentIdx: transparentIdx,
+					})
 
 				case CompressedTilemapData:
 					// Compressed Tilemap Data
 					compressedTilemap := CompressedTilemap{}
 					compressedTilemap.Width = WORD(celChunk.Data[0]) | WORD(celChunk.Data[1])<<8
-					// fmt.Printf("       >> Width in number of Tiles: %d columns\n", compressedTilemap.Width)
 					compressedTilemap.Height = WORD(celChunk.Data[2]) | WORD(celChunk.Data[3])<<8
-					// fmt.Printf("       >> Height in number of Tiles: %d rows\n", compressedTilemap.Height)
 					compressedTilemap.BitsPerTile = WORD(celChunk.Data[4]) | WORD(celChunk.Data[5])<<8
-					// fmt.Printf("       >> Bits per tile: %d\n", compressedTilemap.BitsPerTile)
 					compressedTilemap.TileIDBitmask = DWORD(celChunk.Data[6]) | DWORD(celChunk.Data[7])<<8 | DWORD(celChunk.Data[8])<<16 | DWORD(celChunk.Data[9])<<24
-					// fmt.Printf("       >> Tile ID Bitmask: 0x%08x\n", compressedTilemap.TileIDBitmask)
 					compressedTilemap.XFlipBitmask = DWORD(celChunk.Data[10]) | DWORD(celChunk.Data[11])<<8 | DWORD(celChunk.Data[12])<<16 | DWORD(celChunk.Data[13])<<24
-					// fmt.Printf("       >> X Flip Bitmask: 0x%08x\n", compressedTilemap.XFlipBitmask)
 					compressedTilemap.YFlipBitmask = DWORD(celChunk.Data[14]) | DWORD(celChunk.Data[15])<<8 | DWORD(celChunk.Data[16])<<16 | DWORD(celChunk.Data[17])<<24
-					// fmt.Printf("       >> Y Flip Bitmask: 0x%08x\n", compressedTilemap.YFlipBitmask)
 					compressedTilemap.DiagonalFlipBitmask = DWORD(celChunk.Data[18]) | DWORD(celChunk.Data[19])<<8 | DWORD(celChunk.Data[20])<<16 | DWORD(celChunk.Data[21])<<24
-					// fmt.Printf("       >> Diagonal Flip Bitmask: 0x%08x\n", compressedTilemap.DiagonalFlipBitmask)
 					compressedTilemap.Reserved = [10]BYTE{celChunk.Data[22], celChunk.Data[23], celChunk.Data[24], celChunk.Data[25], celChunk.Data[26], celChunk.Data[27], celChunk.Data[28], celChunk.Data[29], celChunk.Data[30], celChunk.Data[31]}
-					// fmt.Printf("       >> Reserved: %v\n", compressedTilemap.Reserved)
 					compressedTilemap.Tiles = celChunk.Data[32:]
-					// fmt.Printf("       >> Size of Compressed (ZLIB) Tilemap: %d bytes\n", len(compressedTilemap.Tiles))
+
+					// Only 32-bit tiles exist so far
+					if compressedTilemap.BitsPerTile != 32 {
+						if err := skipChunk(frameIndex, chunk, fmt.Errorf("unsupported bits per tile: %d", compressedTilemap.BitsPerTile)); err != nil {
+							return ASEFile{}, err
+						}
+						continue
+					}
+					if err := budget.take("tilemap", int(compressedTilemap.Width), int(compressedTilemap.Height), 1); err != nil {
+						if err := skipChunk(frameIndex, chunk, err); err != nil {
+							return ASEFile{}, err
+						}
+						continue
+					}
 
 					// Decompress the tile zlib data
-					decompressedTiles, err := decompressZlib(compressedTilemap.Tiles)
+					decompressedTiles, err := decompressZlib(compressedTilemap.Tiles, int(compressedTilemap.Width)*int(compressedTilemap.Height)*4)
 					if err != nil {
-						return ASEFile{}, fmt.Errorf("error decompressing tile data: %v", err)
+						if err := skipChunk(frameIndex, chunk, fmt.Errorf("error decompressing tile data: %v", err)); err != nil {
+							return ASEFile{}, err
+						}
+						continue
 					}
 
-					// fmt.Printf("         >>> Decompressed Tilemap: %d bytes\n", len(decompressedTiles))
-
 					// Update the compressed tilemap data with the decompressed data
 					compressedTilemap.Tiles = decompressedTiles
 
@@ -1827,7 +2073,10 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 					// verify that the number of tiles is correct
 					bytesPerTile := int(compressedTilemap.BitsPerTile) / 8
 					if numTiles != len(decompressedTiles)/bytesPerTile {
-						return ASEFile{}, fmt.Errorf("invalid number of tiles: %d", numTiles)
+						if err := skipChunk(frameIndex, chunk, fmt.Errorf("invalid number of tiles: %d", numTiles)); err != nil {
+							return ASEFile{}, err
+						}
+						continue
 					}
 					tilemap := &ASETilemap{
 						Tiles:          make([][]Tile, numTiles),
@@ -1835,16 +2084,11 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 						TilemapColumns: int(compressedTilemap.Width),
 						NumberOfTiles:  numTiles,
 					}
-					// fmt.Printf("         >>> Number of Tiles: %d\n", numTiles)
 
 					// Calculate the tilemap resolution in tile units (not pixels)
 					tilemapRows := int(compressedTilemap.Height)
 					tilemapCols := int(compressedTilemap.Width)
 
-					// Print tilemap resolution
-					// fmt.Printf("         >>> Tilemap Rows: %d\n", tilemapRows)
-					// fmt.Printf("         >>> Tilemap Columns: %d\n", tilemapCols)
-
 					var tiles []Tile
 
 					// Iterate over the tiles row by row
@@ -1870,14 +2114,23 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 							yFlip := binary.LittleEndian.Uint32(tileData) & uint32(compressedTilemap.YFlipBitmask) >> 30
 							diagonalFlip := binary.LittleEndian.Uint32(tileData) & uint32(compressedTilemap.DiagonalFlipBitmask) >> 29
 
+							if int(tileID) >= len(tileset.Tiles) {
+								if err := skipChunk(frameIndex, chunk, fmt.Errorf("tile %d not in the tileset", tileID)); err != nil {
+									return ASEFile{}, err
+								}
+								continue chunks
+							}
+
 							// Create a new tile
 							tile := Tile{
-								Width:        8, // 8 pixels
-								Height:       8, // 8 pixels
+								Width:        tileset.TileWidth,
+								Height:       tileset.TileHeight,
 								ID:           int(tileID),
+								TilesetID:    tileset.ID,
 								XFlip:        xFlip == 1,
 								YFlip:        yFlip == 1,
 								DiagonalFlip: diagonalFlip == 1,
+								Properties:   tileset.tileProperties(int(tileID)),
 								Image:        tileset.Tiles[tileID],
 							}
 
@@ -1886,9 +2139,6 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 						}
 					}
 
-					// Print the tilemap
-					// fmt.Println("         >>> Tilemap:")
-
 					// Ensure the Tilemap is initialized
 					tilemap.Tiles = make([][]Tile, tilemapRows) // Outer slice with 'rows' number of elements
 
@@ -1899,37 +2149,24 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 					// Iterate over the tiles row by row
 
 					for row := 0; row < tilemapRows; row++ {
-						rowStr := ""
 						for col := 0; col < tilemapCols; col++ {
-							// Calculate the index of the tile in the tiles slice
-							index := row*tilemapCols + col
-
-							// Print the tile information
-							var str string
-							id := tiles[index].ID
-							str += fmt.Sprintf("%02d", id)
-							if tiles[index].XFlip {
-								str += "X"
-							}
-							if tiles[index].YFlip {
-								str += "Y"
-							}
-							if tiles[index].DiagonalFlip {
-								str += "D"
-							}
-
-							// Append the tile information to the row string
-							rowStr += fmt.Sprintf("%-4s", str)
-
-							// fmt.Printf("%v ", tiles[index].ID)
-							tilemap.Tiles[row][col] = tiles[index]
-
+							tilemap.Tiles[row][col] = tiles[row*tilemapCols+col]
 						}
-						// fmt.Println()
 					}
 
 					tilemaps = append(tilemaps, *tilemap)
 
+					frameCels[frameIndex] = append(frameCels[frameIndex], frameCel{
+						layerIndex: int(celChunk.LayerIndex),
+						x:          int(celChunk.XPosition),
+						y:          int(celChunk.YPosition),
+						opacity:    celChunk.OpacityLevel,
+						zIndex:     int(celChunk.ZIndex),
+						tilemap:    tilemap,
+					})
+					target = userDataCel
+					targetIndex = len(frameCels[frameIndex]) - 1
+
 				}
 
 			}
@@ -1937,7 +2174,64 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 		}
 	}
 
-	for _, frame := range frames {
+	// Decode the pixels of the image cels, then composite the frames
+	celJobs = neededCelJobs(celJobs, celLinks, selected)
+	progress := newProgress(options.Progress, len(celJobs)+len(frames))
+	workers := options.workers()
+	celErrs := make([]error, len(celJobs))
+	_ = parallel(len(celJobs), workers, func(i int) error {
+		defer progress.step(1)
+		job := celJobs[i]
+		img, indices, err := decodeImageCel(job.image, job.raw, job.bitsPerPixel, palette, job.transparentIdx)
+		if err != nil {
+			celErrs[i] = err
+			return nil
+		}
+		if converter != nil && job.bitsPerPixel != 8 {
+			converter.convertImage(img.(*image.NRGBA))
+		}
+		frameCels[job.frame][job.index].image = img
+		frameCels[job.frame][job.index].indices = indices
+		return nil
+	})
+	for i, err := range celErrs {
+		if err == nil {
+			continue
+		}
+		// Broken cels are left empty
+		if err := skipChunk(celJobs[i].frame, celJobs[i].chunk, err); err != nil {
+			return ASEFile{}, err
+		}
+	}
+	for _, link := range celLinks {
+		linked := frameCels[link.linkedFrame][link.linkedIndex]
+		frameCels[link.frame][link.index].image = linked.image
+		frameCels[link.frame][link.index].indices = linked.indices
+	}
+
+	asepriteFile.Header = *header
+	asepriteFile.Tileset = tileset
+	asepriteFile.frameCels = frameCels
+	if excluded != nil {
+		asepriteFile.dropLayers(excluded)
+	}
+	asepriteFile.applyMetaLayer(options.MetaLayer)
+
+	// Composite the image layers of every frame, tilemaps are kept apart as tiles
+	if asepriteFile.hasImageCels() {
+		frameImages = make([]image.Image, len(frames))
+		_ = parallel(len(frames), workers, func(i int) error {
+			if selected == nil || selected[i] {
+				frameImages[i] = asepriteFile.compositeFrame(i, false)
+			}
+			progress.step(1)
+			return nil
+		})
+	} else {
+		progress.step(len(frames))
+	}
+
+	for frameIndex, frame := range frames {
 
 		for _, chunk := range frame.Chunks {
 
@@ -1947,39 +2241,52 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 				// Tags Chunk
 				tagsChunk, err := parseChunk0x2018(chunk.ChunkData)
 				if err != nil {
-					fmt.Println("Error parsing 0x2018 chunk:", err)
-					os.Exit(1)
+					if err := skipChunk(frameIndex, chunk, err); err != nil {
+						return ASEFile{}, err
+					}
+					continue
 				}
 
 				for stateIndex, tag := range tagsChunk.Tags {
 					name := string(tag.TagName.Chars)
 					from := tag.FromFrame
 					to := tag.ToFrame
+					if err := (ASETag{Name: name, FromFrame: int(from), ToFrame: int(to)}).Validate(len(frames)); err != nil {
+						if err := skipChunk(frameIndex, chunk, err); err != nil {
+							return ASEFile{}, err
+						}
+						continue
+					}
 					state := ASETag{
-						Name: name,
+						Name:      name,
+						FromFrame: int(from),
+						ToFrame:   int(to),
+						Direction: tag.AnimationDirection,
+						Repeat:    tag.Repeat,
+						Extra:     tag.ExtraByte,
+					}
+					// The color of the user data replaces the deprecated one of Aseprite v1.2.x
+					state.Color = color.NRGBA{R: tag.Deprecated[0], G: tag.Deprecated[1], B: tag.Deprecated[2], A: 255}
+					if stateIndex < len(tagUserData) {
+						state.UserData = tagUserData[stateIndex]
+						if state.UserData != nil && state.UserData.Color != nil {
+							state.Color = state.UserData.Color
+						}
 					}
 
 					for i := from; i <= to; i++ {
-						if len(tilemaps) != 0 {
+						if int(i) < len(tilemaps) {
 							state.Tilemaps = append(state.Tilemaps, tilemaps[i])
 						}
 
 						if len(frameImages) != 0 {
-							state.Frames = append(state.Frames, ebiten.NewImageFromImage(frameImages[i]))
+							state.Frames = append(state.Frames, frameImages[i])
 						}
 					}
 
-					// Calculate the number of frames for the current state
-					numFrames := to - from + 1
-
-					// Initialize the inner slice for the current state
+					state.Durations = slices.Clone(framesDuration[from : to+1])
 					state.FrameDuration = make([][]time.Duration, len(tagsChunk.Tags))
-
-					// Populate the inner slice with the appropriate elements from framesDuration
-					state.FrameDuration[stateIndex] = make([]time.Duration, numFrames)
-					for i := 0; i < int(numFrames); i++ {
-						state.FrameDuration[stateIndex][i] = framesDuration[int(from)+i]
-					}
+					state.FrameDuration[stateIndex] = state.Durations
 
 					if len(state.Frames) > 1 {
 						state.HasAnimations = true
@@ -1988,8 +2295,21 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 							TotalFrames: len(state.Frames),
 							Index:       0,
 							LastChange:  time.Now(),
-							Duration:    state.FrameDuration[stateIndex],
+							Duration:    state.Durations,
+							Direction:   tag.AnimationDirection,
+							Repeat:      tag.Repeat,
 						}
+
+						// The first marked frame inside the tag starts the loop section
+						for i := from; i <= to; i++ {
+							if loopStarts[int(i)] {
+								state.Animation.LoopStart = int(i - from)
+								break
+							}
+						}
+
+						// Reverse animations start at the last frame
+						state.Animation.Reset()
 					}
 
 					states = append(states, state)
@@ -1998,12 +2318,45 @@ func ParseAseprite(assets embed.FS, f string) (ASEFile, error) {
 		}
 	}
 
-	asepriteFile.Tileset = tileset
+	asepriteFile.Palette = palette
+	asepriteFile.Tilemaps = tilemaps
+	asepriteFile.Images = frameImages
+	if options.KeepIndices && header.ColorDepth == ColorDepthIndexed && len(frameImages) > 0 {
+		for i := range frames {
+			asepriteFile.Indices = append(asepriteFile.Indices, asepriteFile.compositeIndices(i))
+		}
+	}
+	asepriteFile.Durations = framesDuration
 
 	asepriteFile.State = states
-	// for stateIdx, state := range states {
-	// 	for
-	// }
+	asepriteFile.refreshStates()
+	// The default tag of an untagged file is clipped like the tags, a file
+	// whose tags are all left out gets none
+	asepriteFile.addDefaultTag()
+	if selected != nil {
+		asepriteFile.keepFrames(selected)
+	}
+	if options.Trim {
+		asepriteFile.trim()
+	}
+	if options.Scale > 1 {
+		width, height := int(header.Width)*options.Scale, int(header.Height)*options.Scale
+		if width > 0xFFFF || height > 0xFFFF {
+			return ASEFile{}, fmt.Errorf("%w: scaled canvas of %dx%d", ErrLimitExceeded, width, height)
+		}
+		if err := budget.take("scaled frames", width, height, len(asepriteFile.Images)); err != nil {
+			return ASEFile{}, err
+		}
+		asepriteFile.scale(options.Scale)
+	}
+	asepriteFile.convertAlpha(options.Alpha)
+	if options.LayerFrames {
+		asepriteFile.LayerFrames = asepriteFile.compositeLayerFrames(options.workers(), options.Alpha)
+	}
 
+	logger.Debug("file parsed", "frames", len(frames), "layers", len(asepriteFile.Layers), "tags", len(states), "warnings", len(asepriteFile.Warnings))
+	if truncated != nil {
+		return asepriteFile, truncated
+	}
 	return asepriteFile, nil
 }
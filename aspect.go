@@ -0,0 +1,46 @@
+package asevre
+
+import (
+	"image"
+	"image/draw"
+)
+
+// PixelAspect returns how many screen pixels wide and high a sprite pixel is,
+// 1, 1 for square pixels. A 2:1 sprite (e.g. CGA-style art) returns 2, 1.
+func (h *Header) PixelAspect() (x, y int) {
+	if h.PixelWidth == 0 || h.PixelHeight == 0 {
+		return 1, 1
+	}
+	return int(h.PixelWidth), int(h.PixelHeight)
+}
+
+// CorrectAspect scales an image of the file (a frame, a cel or a tile) by its
+// pixel ratio, so non-square pixels show as the artist saw them in Aseprite.
+// Images of square-pixel sprites are returned as they are.
+func (f *ASEFile) CorrectAspect(img image.Image) image.Image {
+	x, y := f.Header.PixelAspect()
+	if x == 1 && y == 1 {
+		return img
+	}
+	return scaleNearest(img, x, y)
+}
+
+// scaleNearest scales an image by integer factors, repeating every pixel
+func scaleNearest(img image.Image, xScale, yScale int) *image.NRGBA {
+	b := img.Bounds()
+	src, ok := img.(*image.NRGBA)
+	if !ok {
+		src = image.NewNRGBA(b)
+		draw.Draw(src, b, img, b.Min, draw.Src)
+	}
+
+	scaled := image.NewNRGBA(image.Rect(0, 0, b.Dx()*xScale, b.Dy()*yScale))
+	for y := 0; y < scaled.Rect.Dy(); y++ {
+		srcRow := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y/yScale):]
+		row := scaled.Pix[y*scaled.Stride:]
+		for x := 0; x < scaled.Rect.Dx(); x++ {
+			copy(row[x*4:x*4+4], srcRow[x/xScale*4:])
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,60 @@
+package asevre
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+)
+
+// LoadBundle parses every .aseprite and .ase file of a zip archive, so a game
+// can ship its sprites packed in one file. The files are keyed by their path
+// in the archive ("player/idle.aseprite"). Linked files (external tilesets)
+// are read from the archive too. The tags selected by WithTags apply to the
+// sprites: files that hold a tileset without these tags are loaded whole.
+func LoadBundle(filePath string, opts ...ParseOption) (map[string]ASEFile, error) {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return parseBundle(&r.Reader, opts)
+}
+
+// ParseBundle parses every .aseprite and .ase file of a zip archive of size
+// bytes read from r, e.g. an archive embedded with go:embed, see LoadBundle.
+func ParseBundle(r io.ReaderAt, size int64, opts ...ParseOption) (map[string]ASEFile, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return parseBundle(archive, opts)
+}
+
+// parseBundle parses the sprites of an archive
+func parseBundle(archive *zip.Reader, opts []ParseOption) (map[string]ASEFile, error) {
+	files := map[string]ASEFile{}
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		if ext := path.Ext(entry.Name); ext != ".aseprite" && ext != ".ase" {
+			continue
+		}
+		file, err := ParseAsepriteFS(archive, entry.Name, opts...)
+		if errors.Is(err, ErrTagNotFound) {
+			// A tileset has no tags to select, keep all of it
+			all, allErr := ParseAsepriteFS(archive, entry.Name, append(slices.Clone(opts), withoutSelection())...)
+			if allErr == nil && all.Tileset.Tiles != nil {
+				file, err = all, nil
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		files[entry.Name] = file
+	}
+	return files, nil
+}
@@ -0,0 +1,274 @@
+package asevre
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+)
+
+// cacheVersion changes with the layout of the cache, caches of other
+// versions are treated as stale
+const cacheVersion = 4
+
+// ErrStaleCache is returned when a cache was made from other data, with
+// other options or by another version of asevre.
+var ErrStaleCache = errors.New("stale cache")
+
+func init() {
+	// Concrete types stored in interface fields (images, colors, user properties)
+	gob.Register(&image.RGBA{})
+	gob.Register(&image.NRGBA{})
+	gob.Register(&image.Paletted{})
+	gob.Register(color.NRGBA{})
+	gob.Register(color.RGBA{})
+	gob.Register(image.Point{})
+	gob.Register(image.Rectangle{})
+	gob.Register(UUID{})
+	gob.Register([]any{})
+	gob.Register(map[string]any{})
+}
+
+// cacheKey identifies the data and the options a cache was made from
+type cacheKey struct {
+	Version int
+	Hash    [sha256.Size]byte // Hash of the .aseprite data
+	Options string            // Options changing the result
+}
+
+// newCacheKey creates the key of a file parsed with options
+func newCacheKey(source []byte, opts []ParseOption) cacheKey {
+	o := newParseOptions(opts)
+	return cacheKey{
+		Version: cacheVersion,
+		Hash:    sha256.Sum256(source),
+		Options: fmt.Sprintf("%t %q %t %t %t %+v %t %v %q %d %d %t %t", o.KeepIndices, o.MetaLayer, o.Strict, o.ColorManagement, o.Trim, o.Limits, o.KeepLayer != nil, o.Frames, o.Tags, max(o.Scale, 1), o.Alpha, o.HiddenLayers, o.LayerFrames),
+	}
+}
+
+// cachedFile is the gob form of an ASEFile, with its unexported fields. The
+// images shared with the tags and the tiles are stored once.
+type cachedFile struct {
+	File         ASEFile
+	Warnings     []string
+	Cels         [][]cachedCel
+	PaletteNames []string
+	TileIndices  [][]byte
+	TileUserData map[int]*UserData // Gob can't encode the nil entries of Tileset.TileUserData
+	TileCount    int               // Length of Tileset.TileUserData
+	Unsupported  map[Feature][]string
+	ShowHidden   bool
+	Converted    bool   // Colors converted to sRGB
+	DefaultTags  []bool // Tags of State made up for files without tags
+}
+
+// cachedCel is the gob form of a frameCel
+type cachedCel struct {
+	Layer    int
+	X, Y     int
+	Opacity  BYTE
+	ZIndex   int
+	Image    image.Image
+	Indices  []byte
+	Tilemap  *ASETilemap
+	UserData *UserData
+	Bounds   *CelBounds
+}
+
+// SaveCache writes a parsed file to w, so LoadCache can return it without
+// parsing source (the .aseprite data it was parsed from) again.
+func SaveCache(w io.Writer, file ASEFile, source []byte, opts ...ParseOption) error {
+	cached := cachedFile{
+		File:         file,
+		PaletteNames: file.paletteNames,
+		TileIndices:  file.Tileset.indices,
+		TileUserData: map[int]*UserData{},
+		TileCount:    len(file.Tileset.TileUserData),
+		Unsupported:  file.unsupported,
+		ShowHidden:   file.showHidden,
+		Converted:    file.colorConverted,
+	}
+	cached.File.Tileset.TileUserData = nil
+	for i, userData := range file.Tileset.TileUserData {
+		if userData != nil {
+			cached.TileUserData[i] = userData
+		}
+	}
+
+	// Gob can't encode the values of the chunk handlers, cached files have none
+	cached.File.CustomChunks = nil
+
+	// Errors can't be encoded, only their message is kept
+	cached.File.Warnings = nil
+	for _, warning := range file.Warnings {
+		cached.Warnings = append(cached.Warnings, warning.Error())
+	}
+
+	// The frames and tilemaps of the tags and the images of the tiles are
+	// rebuilt when loading
+	cached.File.State = make([]ASETag, len(file.State))
+	for i, tag := range file.State {
+		cached.DefaultTags = append(cached.DefaultTags, tag.synthesized)
+		tag.Frames, tag.Tilemaps = nil, nil
+		cached.File.State[i] = tag
+	}
+	cached.File.Tilemaps = make([]ASETilemap, len(file.Tilemaps))
+	for i, tilemap := range file.Tilemaps {
+		cached.File.Tilemaps[i] = withoutTileImages(tilemap)
+	}
+
+	cached.Cels = make([][]cachedCel, len(file.frameCels))
+	for i, cels := range file.frameCels {
+		for _, c := range cels {
+			cel := cachedCel{Layer: c.layerIndex, X: c.x, Y: c.y, Opacity: c.opacity, ZIndex: c.zIndex, Image: c.image, Indices: c.indices, UserData: c.userData, Bounds: c.bounds}
+			if c.tilemap != nil {
+				tilemap := withoutTileImages(*c.tilemap)
+				cel.Tilemap = &tilemap
+			}
+			cached.Cels[i] = append(cached.Cels[i], cel)
+		}
+	}
+
+	encoder := gob.NewEncoder(w)
+	if err := encoder.Encode(newCacheKey(source, opts)); err != nil {
+		return err
+	}
+	return encoder.Encode(cached)
+}
+
+// LoadCache reads a file written by SaveCache. It returns ErrStaleCache if
+// the cache wasn't made from source with the same options.
+func LoadCache(r io.Reader, source []byte, opts ...ParseOption) (ASEFile, error) {
+	decoder := gob.NewDecoder(r)
+	var key cacheKey
+	if err := decoder.Decode(&key); err != nil {
+		return ASEFile{}, err
+	}
+	if key != newCacheKey(source, opts) {
+		return ASEFile{}, ErrStaleCache
+	}
+	return readCachedFile(decoder)
+}
+
+// ReadCache reads a file written by SaveCache without checking what it was
+// made from, e.g. a cache embedded in the program by asevre-gen. It only
+// fails on caches of other versions of asevre.
+func ReadCache(r io.Reader) (ASEFile, error) {
+	decoder := gob.NewDecoder(r)
+	var key cacheKey
+	if err := decoder.Decode(&key); err != nil {
+		return ASEFile{}, err
+	}
+	if key.Version != cacheVersion {
+		return ASEFile{}, ErrStaleCache
+	}
+	return readCachedFile(decoder)
+}
+
+// readCachedFile decodes the file following the key of a cache
+func readCachedFile(decoder *gob.Decoder) (ASEFile, error) {
+	var cached cachedFile
+	if err := decoder.Decode(&cached); err != nil {
+		return ASEFile{}, err
+	}
+
+	file := cached.File
+	file.paletteNames = cached.PaletteNames
+	file.Tileset.indices = cached.TileIndices
+	file.unsupported = cached.Unsupported
+	file.showHidden = cached.ShowHidden
+	file.colorConverted = cached.Converted
+	for i, synthesized := range cached.DefaultTags {
+		if i < len(file.State) {
+			file.State[i].synthesized = synthesized
+		}
+	}
+	if cached.TileCount > 0 {
+		file.Tileset.TileUserData = make([]*UserData, cached.TileCount)
+		for i, userData := range cached.TileUserData {
+			if i >= 0 && i < cached.TileCount {
+				file.Tileset.TileUserData[i] = userData
+			}
+		}
+	}
+	for _, warning := range cached.Warnings {
+		file.Warnings = append(file.Warnings, errors.New(warning))
+	}
+
+	for i := range file.Tilemaps {
+		file.Tileset.setTileImages(&file.Tilemaps[i])
+	}
+	file.frameCels = make([][]frameCel, len(cached.Cels))
+	for i, cels := range cached.Cels {
+		for _, c := range cels {
+			cel := frameCel{layerIndex: c.Layer, x: c.X, y: c.Y, opacity: c.Opacity, zIndex: c.ZIndex, image: c.Image, indices: c.Indices, tilemap: c.Tilemap, userData: c.UserData, bounds: c.Bounds}
+			if cel.tilemap != nil {
+				file.Tileset.setTileImages(cel.tilemap)
+			}
+			file.frameCels[i] = append(file.frameCels[i], cel)
+		}
+	}
+	file.refreshStates()
+	return file, nil
+}
+
+// LoadAsepriteCached parses an .aseprite or .ase file from disk, reusing the
+// cache at cachePath when it was made from the same file, and writing it
+// otherwise. Files the sprite links to (external tilesets) are not checked.
+// A cache that can't be written doesn't fail the load: the next call parses
+// the file again. Layer filters can't be compared: files parsed with
+// different filters need their own cache. Cached files have no CustomChunks.
+func LoadAsepriteCached(filePath, cachePath string, opts ...ParseOption) (ASEFile, error) {
+	source, err := os.ReadFile(filePath)
+	if err != nil {
+		return ASEFile{}, err
+	}
+
+	// Any problem with the cache means parsing the file again
+	if data, err := os.ReadFile(cachePath); err == nil {
+		if file, err := LoadCache(bytes.NewReader(data), source, opts...); err == nil {
+			return file, nil
+		}
+	}
+
+	file, err := LoadAseprite(filePath, opts...)
+	if err != nil {
+		// Truncated files keep their frames, but aren't cached
+		return file, err
+	}
+	var buf bytes.Buffer
+	if err := SaveCache(&buf, file, source, opts...); err == nil {
+		_ = os.WriteFile(cachePath, buf.Bytes(), 0o644)
+	}
+	return file, nil
+}
+
+// withoutTileImages copies a tilemap without the images of its tiles
+func withoutTileImages(tilemap ASETilemap) ASETilemap {
+	tiles := make([][]Tile, len(tilemap.Tiles))
+	for row := range tilemap.Tiles {
+		tiles[row] = make([]Tile, len(tilemap.Tiles[row]))
+		for col, tile := range tilemap.Tiles[row] {
+			tile.Image = nil
+			tiles[row][col] = tile
+		}
+	}
+	tilemap.Tiles = tiles
+	return tilemap
+}
+
+// setTileImages gives the tiles of a tilemap their tileset image
+func (t *ASETileset) setTileImages(tilemap *ASETilemap) {
+	for _, row := range tilemap.Tiles {
+		for col := range row {
+			if id := row[col].ID; id >= 0 && id < len(t.Tiles) {
+				row[col].Image = t.Tiles[id]
+			}
+		}
+	}
+}
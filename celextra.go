@@ -0,0 +1,49 @@
+package asevre
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Cel extra flag: precise bounds are set
+const CelExtraPreciseBounds DWORD = 1
+
+// Chunk0x2006 represents the cel extra chunk. It follows the cel chunk it extends.
+type Chunk0x2006 struct {
+	Flags    DWORD    // Flags (4 bytes)
+	X        FIXED    // Precise X position (4 bytes)
+	Y        FIXED    // Precise Y position (4 bytes)
+	Width    FIXED    // Width of the cel in the sprite (scaled in real-time) (4 bytes)
+	Height   FIXED    // Height of the cel in the sprite (4 bytes)
+	Reserved [16]BYTE // For future use (16 bytes)
+}
+
+// parseChunk0x2006 parses the cel extra chunk
+func parseChunk0x2006(data []byte) (*Chunk0x2006, error) {
+	chunk := &Chunk0x2006{}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// CelBounds are the precise (sub-pixel) bounds of a cel in the sprite
+type CelBounds struct {
+	X, Y          float64
+	Width, Height float64
+}
+
+// fixedToFloat converts a 16.16 fixed point value
+func fixedToFloat(v FIXED) float64 {
+	return float64(v) / 65536
+}
+
+// CelBounds returns the precise bounds of the cel of a layer in a frame. It
+// returns false if the cel has no precise bounds.
+func (f *ASEFile) CelBounds(frame, layer int) (CelBounds, bool) {
+	c, ok := findCel(f.frameCels, frame, layer)
+	if !ok || c.bounds == nil {
+		return CelBounds{}, false
+	}
+	return *c.bounds, true
+}
@@ -0,0 +1,162 @@
+package asevre
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+)
+
+// celJob is an image cel waiting to be decoded
+type celJob struct {
+	frame, index   int // Position of the cel in frameCels
+	chunk          Chunk
+	image          CompressedImage // Pixels of raw cels are not compressed
+	raw            bool
+	bitsPerPixel   int
+	transparentIdx int
+}
+
+// celLink is a linked cel sharing the pixels of a cel of an earlier frame
+type celLink struct {
+	frame, index             int // Position of the linked cel in frameCels
+	linkedFrame, linkedIndex int // Position of the cel it is linked to
+}
+
+// decodeImageCel decompresses the pixels of an image cel, unless they are
+// raw, and converts them to colors. Indexed cels also return their palette
+// indices.
+func decodeImageCel(compressedImage CompressedImage, raw bool, bitsPerPixel int, palette []color.Color, transparentIdx int) (image.Image, []byte, error) {
+	size := int(compressedImage.Width) * int(compressedImage.Height) * bitsPerPixel / 8
+	decompressedPixels := compressedImage.Pixels
+	if raw {
+		if len(decompressedPixels) < size {
+			return nil, nil, fmt.Errorf("raw image data of %d bytes out of %d", len(decompressedPixels), size)
+		}
+		decompressedPixels = decompressedPixels[:size]
+	} else {
+		// The decompressed pixels are only needed until they are converted
+		buf := getPixelBuffer(size)
+		defer putPixelBuffer(buf)
+		if err := inflate(*buf, compressedImage.Pixels); err != nil {
+			return nil, nil, fmt.Errorf("error decompressing image data: %v", err)
+		}
+		decompressedPixels = *buf
+	}
+
+	// Create a new image with the cel dimensions, the cel position
+	// is applied later when the frame is composited. The pixels are stored
+	// in rows, from top to bottom, left to right, like the image pixels.
+	img := image.NewNRGBA(image.Rect(0, 0, int(compressedImage.Width), int(compressedImage.Height)))
+
+	var indices []byte
+	switch bitsPerPixel {
+	case 32:
+		// RGBA: 4 bytes per pixel (Red, Green, Blue, Alpha), non-premultiplied
+		copy(img.Pix, decompressedPixels)
+	case 16:
+		// Grayscale: 2 bytes per pixel (Value, Alpha)
+		for i, j := 0, 0; j < len(img.Pix); i, j = i+2, j+4 {
+			value, alpha := decompressedPixels[i], decompressedPixels[i+1]
+			img.Pix[j], img.Pix[j+1], img.Pix[j+2], img.Pix[j+3] = value, value, value, alpha
+		}
+	case 8:
+		// Indexed: 1 byte per pixel, an index to the palette
+		var colors [256]color.NRGBA
+		for i := range colors {
+			if i != transparentIdx && i < len(palette) {
+				colors[i] = color.NRGBAModel.Convert(palette[i]).(color.NRGBA)
+			}
+		}
+		indices = make([]byte, size)
+		copy(indices, decompressedPixels)
+		for i, index := range indices {
+			c := colors[index]
+			img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2], img.Pix[i*4+3] = c.R, c.G, c.B, c.A
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported %d bits per pixel", bitsPerPixel)
+	}
+
+	return img, indices, nil
+}
+
+// celIndex returns the position in frameCels[frame] of the cel of a layer, -1 if there is none
+func celIndex(frameCels [][]frameCel, frame, layer int) int {
+	if frame < 0 || frame >= len(frameCels) {
+		return -1
+	}
+	for i, c := range frameCels[frame] {
+		if c.layerIndex == layer {
+			return i
+		}
+	}
+	return -1
+}
+
+// progress counts the steps of a parse for ParseOptions.Progress. It is safe
+// for concurrent use.
+type progress struct {
+	report      func(done, total int)
+	mu          sync.Mutex
+	done, total int
+}
+
+// newProgress starts reporting total steps, nil when there is nothing to report to
+func newProgress(report func(done, total int), total int) *progress {
+	if report == nil {
+		return nil
+	}
+	report(0, total)
+	return &progress{report: report, total: total}
+}
+
+// step reports n more steps done
+func (p *progress) step(n int) {
+	if p == nil || n <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done = min(p.done+n, p.total)
+	p.report(p.done, p.total)
+}
+
+// parallel calls fn for 0..n-1 on at most workers goroutines. It returns the
+// error of the lowest index that failed, so the result doesn't depend on scheduling.
+func parallel(n, workers int, fn func(i int) error) error {
+	workers = min(workers, n)
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, n)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
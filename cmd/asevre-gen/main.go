@@ -0,0 +1,166 @@
+// Command asevre-gen decodes Aseprite files at build time and generates Go
+// code embedding the decoded files, so they are not parsed at run time, with
+// a constant for every tag name.
+//
+// Usage:
+//
+//	//go:generate go run github.com/retroblast-engine/asevre/cmd/asevre-gen -pkg assets -o sprites_gen.go hero.aseprite slime.aseprite
+//
+// For every file, the cache of the decoded file is written next to the
+// output (hero.asevre) and the generated code has:
+//
+//	const HeroRun = "run"             // One constant per tag
+//	func Hero() (asevre.ASEFile, error) // The decoded file
+//
+// A tag constant whose name is already taken, by a function or another
+// constant, is numbered: HeroRun2.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/retroblast-engine/asevre"
+)
+
+// cacheExt is the extension of the caches written next to the generated code
+const cacheExt = ".asevre"
+
+func main() {
+	pkg := flag.String("pkg", "", "package of the generated code (default: $GOPACKAGE)")
+	out := flag.String("o", "asevre_gen.go", "generated Go file")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: asevre-gen [flags] files...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *pkg == "" {
+		*pkg = os.Getenv("GOPACKAGE")
+	}
+	if *pkg == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := generate(*pkg, *out, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "asevre-gen:", err)
+		os.Exit(1)
+	}
+}
+
+// generate writes the caches of the files and the Go code embedding them
+func generate(pkg, out string, paths []string) error {
+	var code bytes.Buffer
+	fmt.Fprintf(&code, "// Code generated by asevre-gen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&code, "package %s\n\n", pkg)
+	fmt.Fprintf(&code, "import (\n\t\"bytes\"\n\t_ \"embed\"\n\n\t\"github.com/retroblast-engine/asevre\"\n)\n")
+
+	// The function names are known first, so that no tag constant takes one
+	type sprite struct {
+		path, base, name string
+		source           []byte
+		file             asevre.ASEFile
+	}
+	var sprites []sprite
+	names := map[string]string{} // Identifiers already used, with the file using them
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		file, err := asevre.LoadAseprite(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+
+		base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		name := identifier(base)
+		if other, used := names[name]; used {
+			return fmt.Errorf("%s and %s both generate %s", other, path, name)
+		}
+		names[name] = path
+		sprites = append(sprites, sprite{path: path, base: base, name: name, source: source, file: file})
+	}
+
+	for _, s := range sprites {
+		// The cache goes next to the generated code, where go:embed can read it
+		cachePath := filepath.Join(filepath.Dir(out), s.base+cacheExt)
+		var cache bytes.Buffer
+		if err := asevre.SaveCache(&cache, s.file, s.source); err != nil {
+			return fmt.Errorf("%s: %v", s.path, err)
+		}
+		if err := os.WriteFile(cachePath, cache.Bytes(), 0o644); err != nil {
+			return err
+		}
+
+		variable := unexported(s.name) + "Cache"
+		fmt.Fprintf(&code, "\n//go:embed %s\nvar %s []byte\n", filepath.Base(cachePath), variable)
+
+		if len(s.file.State) > 0 {
+			fmt.Fprintf(&code, "\n// Tags of %s\nconst (\n", filepath.Base(s.path))
+			tags := map[string]bool{}
+			for _, tag := range s.file.State {
+				if tags[tag.Name] {
+					continue
+				}
+				tags[tag.Name] = true
+				// Tags that make the same identifier as a function or another
+				// constant ("run" and "Run", or an empty name) get a number
+				id := identifier(s.base + " " + tag.Name)
+				constant := id
+				for n := 2; names[constant] != ""; n++ {
+					constant = fmt.Sprintf("%s%d", id, n)
+				}
+				names[constant] = s.path
+				fmt.Fprintf(&code, "\t%s = %q\n", constant, tag.Name)
+			}
+			fmt.Fprintf(&code, ")\n")
+		}
+
+		fmt.Fprintf(&code, "\n// %s returns %s, decoded by asevre-gen.\n", s.name, filepath.Base(s.path))
+		fmt.Fprintf(&code, "func %s() (asevre.ASEFile, error) {\n\treturn asevre.ReadCache(bytes.NewReader(%s))\n}\n", s.name, variable)
+	}
+
+	formatted, err := format.Source(code.Bytes())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, formatted, 0o644)
+}
+
+// identifier turns a file or tag name into an exported Go identifier:
+// "hero-walk 2" becomes "HeroWalk2"
+func identifier(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	id := b.String()
+	if id == "" || !unicode.IsLetter([]rune(id)[0]) {
+		id = "X" + id
+	}
+	return id
+}
+
+// unexported lowers the first letter of an identifier
+func unexported(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/retroblast-engine/asevre"
+)
+
+// dumpFile is the JSON document written for a file
+type dumpFile struct {
+	Path   string        `json:"path"`
+	Header asevre.Header `json:"header"`
+	Frames []dumpFrame   `json:"frames"`
+}
+
+// dumpFrame is a frame of a dumpFile
+type dumpFrame struct {
+	Header asevre.FrameHeader `json:"header"`
+	Chunks []dumpChunk        `json:"chunks"`
+}
+
+// dumpChunk is a chunk of a dumpFrame. Data holds the decoded chunk, or the
+// raw bytes of chunks without a structure.
+type dumpChunk struct {
+	Offset int64        `json:"offset"`
+	Size   asevre.DWORD `json:"size"`
+	Type   asevre.WORD  `json:"type"`
+	Name   string       `json:"name"`
+	Data   any          `json:"data"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// runDump lists every chunk of the files, or writes them decoded as JSON.
+func runDump(args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "write the decoded chunks as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: asevre dump [flags] files...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no input files")
+	}
+
+	for i, path := range flags.Args() {
+		dump, err := dumpChunks(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(dump); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			continue
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		printChunks(os.Stdout, dump)
+	}
+	return nil
+}
+
+// dumpChunks reads and decodes every chunk of a file. A chunk that fails to
+// decode keeps its raw bytes and the error, so broken files can be dumped too.
+func dumpChunks(path string) (dumpFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return dumpFile{}, err
+	}
+	defer f.Close()
+
+	d, err := asevre.NewDecoder(f)
+	if err != nil {
+		return dumpFile{}, err
+	}
+	dump := dumpFile{Path: path, Header: d.Header()}
+	for {
+		frameHeader, err := d.NextFrame()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return dumpFile{}, err
+		}
+
+		frame := dumpFrame{Header: *frameHeader}
+		for {
+			chunk, err := d.NextChunk()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return dumpFile{}, err
+			}
+			data, err := d.ChunkData()
+			if err != nil {
+				return dumpFile{}, err
+			}
+
+			c := dumpChunk{
+				Offset: chunk.Offset,
+				Size:   chunk.ChunkSize,
+				Type:   chunk.ChunkType,
+				Name:   asevre.ChunkTypeName(chunk.ChunkType),
+				Data:   data,
+			}
+			decoded, err := asevre.DecodeChunk(dump.Header, chunk.ChunkType, data)
+			if err != nil {
+				c.Error = err.Error()
+			} else if decoded != nil {
+				c.Data = decoded
+			}
+			frame.Chunks = append(frame.Chunks, c)
+		}
+		dump.Frames = append(dump.Frames, frame)
+	}
+	return dump, nil
+}
+
+// printChunks lists the chunks of a file, one per line
+func printChunks(w io.Writer, dump dumpFile) {
+	fmt.Fprintln(w, dump.Path)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, frame := range dump.Frames {
+		fmt.Fprintf(tw, "Frame %d\t%d ms\t%d chunks\n", i, frame.Header.FrameDuration, len(frame.Chunks))
+		for _, c := range frame.Chunks {
+			fmt.Fprintf(tw, "  0x%08x\t0x%04x\t%s\t%s\t%s\n", c.Offset, c.Type, c.Name, byteSize(int64(c.Size)), c.Error)
+		}
+	}
+	tw.Flush()
+}
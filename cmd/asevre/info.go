@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/retroblast-engine/asevre"
+)
+
+// runInfo prints what the files hold: header, layers, tags, slices, tileset
+// and how much of the file every chunk type takes.
+func runInfo(args []string) error {
+	flags := flag.NewFlagSet("info", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: asevre info files...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no input files")
+	}
+
+	for i, path := range flags.Args() {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := printInfo(os.Stdout, path); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// chunkStats counts the chunks of a type
+type chunkStats struct {
+	count int
+	bytes int64
+}
+
+// printInfo prints the information of a file
+func printInfo(w io.Writer, path string) error {
+	file, err := asevre.LoadAseprite(path)
+	if err != nil {
+		return err
+	}
+	stats, err := readChunkStats(path)
+	if err != nil {
+		return err
+	}
+
+	h := file.Header
+	fmt.Fprintln(w, path)
+	fmt.Fprintf(w, "Size: %d x %d pixels, %s (%s)\n", h.Width, h.Height, byteSize(int64(h.FileSize)), h.GetPixelRatio())
+	fmt.Fprintf(w, "Color: %s, %d colors, transparent index %d\n", h.GetColorDepthDescription(), len(file.Palette), h.TransparentIdx)
+	grid := file.Grid()
+	fmt.Fprintf(w, "Grid: %d x %d at %d, %d\n", grid.Width, grid.Height, grid.X, grid.Y)
+	fmt.Fprintf(w, "Frames: %d\n", h.FrameCount)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "\nLayers: %d\n", len(file.Layers))
+	for _, layer := range file.Layers {
+		kind := "image"
+		switch layer.Type {
+		case asevre.LayerTypeGroup:
+			kind = "group"
+		case asevre.LayerTypeTilemap:
+			kind = "tilemap"
+		}
+		visibility := "visible"
+		if !layer.IsVisible() {
+			visibility = "hidden"
+		}
+		indent := strings.Repeat("  ", max(layer.ChildLevel, 0))
+		fmt.Fprintf(tw, "  %d\t%s%s\t%s\t%s\t%s\topacity %d\n", layer.Index, indent, layer.Name, kind, visibility, layer.BlendMode, layer.Opacity)
+	}
+	tw.Flush()
+
+	if len(file.State) > 0 {
+		fmt.Fprintf(w, "\nTags: %d\n", len(file.State))
+		for _, tag := range file.State {
+			repeat := "forever"
+			if tag.Repeat != asevre.Infinite {
+				repeat = fmt.Sprintf("%d times", tag.Repeat)
+			}
+			fmt.Fprintf(tw, "  %s\tframes %d-%d\t%s\t%s\n", tag.Name, tag.FromFrame, tag.ToFrame, tag.Direction, repeat)
+		}
+		tw.Flush()
+	}
+
+	if len(file.Slices) > 0 {
+		fmt.Fprintf(w, "\nSlices: %d\n", len(file.Slices))
+		for _, slice := range file.Slices {
+			var extras []string
+			if slice.IsNinePatch() {
+				extras = append(extras, "9-patch")
+			}
+			if slice.HasPivot() {
+				extras = append(extras, "pivot")
+			}
+			bounds := ""
+			if len(slice.Keys) > 0 {
+				bounds = slice.Keys[0].Bounds.String()
+			}
+			fmt.Fprintf(tw, "  %s\t%d keys\t%s\t%s\n", slice.Name, len(slice.Keys), bounds, strings.Join(extras, ", "))
+		}
+		tw.Flush()
+	}
+
+	if len(file.Tileset.Tiles) > 0 {
+		fmt.Fprintf(w, "\nTileset %d: %d tiles of %d x %d\n", file.Tileset.ID, len(file.Tileset.Tiles), file.Tileset.TileWidth, file.Tileset.TileHeight)
+	}
+
+	fmt.Fprintf(w, "\nChunks:\n")
+	var chunkTypes []asevre.WORD
+	for chunkType := range stats {
+		chunkTypes = append(chunkTypes, chunkType)
+	}
+	slices.Sort(chunkTypes)
+	for _, chunkType := range chunkTypes {
+		s := stats[chunkType]
+		fmt.Fprintf(tw, "  0x%04x\t%s\t%d\t%s\n", chunkType, asevre.ChunkTypeName(chunkType), s.count, byteSize(s.bytes))
+	}
+	tw.Flush()
+
+	for _, warning := range file.Warnings {
+		fmt.Fprintln(w, "warning:", warning)
+	}
+	return nil
+}
+
+// readChunkStats counts the chunks of every type in a file
+func readChunkStats(path string) (map[asevre.WORD]chunkStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, err := asevre.NewDecoder(f)
+	if err != nil {
+		return nil, err
+	}
+	stats := map[asevre.WORD]chunkStats{}
+	for {
+		if _, err := d.NextFrame(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		for {
+			chunk, err := d.NextChunk()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			s := stats[chunk.ChunkType]
+			s.count++
+			s.bytes += int64(chunk.ChunkSize)
+			stats[chunk.ChunkType] = s
+		}
+	}
+	return stats, nil
+}
+
+// byteSize formats a size in bytes for people
+func byteSize(n int64) string {
+	units := []string{"bytes", "KiB", "MiB", "GiB"}
+	size, unit := float64(n), 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", n, units[0])
+	}
+	return fmt.Sprintf("%.1f %s", size, units[unit])
+}
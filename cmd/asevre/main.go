@@ -0,0 +1,55 @@
+// Command asevre provides tools to inspect and rewrite Aseprite files.
+//
+// Usage:
+//
+//	asevre <command> [flags] files...
+//
+// Commands:
+//
+//	dump       list the chunks of files, or write them decoded as JSON
+//	info       print the header, layers, tags, slices, tileset and chunks of files
+//	normalize  rewrite files to a common house style
+//	verify     check the structure of files and list their problems
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// commands maps every subcommand to its implementation
+var commands = map[string]func(args []string) error{
+	"dump":      runDump,
+	"info":      runInfo,
+	"normalize": runNormalize,
+	"verify":    runVerify,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	run, exists := commands[os.Args[1]]
+	if !exists {
+		fmt.Fprintf(os.Stderr, "asevre: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "asevre:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: asevre <command> [flags] files...")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  dump       list the chunks of files, or write them decoded as JSON")
+	fmt.Fprintln(os.Stderr, "  info       print the header, layers, tags, slices, tileset and chunks of files")
+	fmt.Fprintln(os.Stderr, "  normalize  rewrite files to a common house style")
+	fmt.Fprintln(os.Stderr, "  verify     check the structure of files and list their problems")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+
+	"github.com/retroblast-engine/asevre"
+)
+
+// runNormalize rewrites the files to the house style: indexed with a shared
+// palette, without hidden layers, with durations on 60Hz ticks and sorted tags.
+// The files are written into the output directory, never over the inputs.
+func runNormalize(args []string) error {
+	flags := flag.NewFlagSet("normalize", flag.ExitOnError)
+	paletteFile := flags.String("palette", "", "take the shared palette from this .aseprite file instead of building it from the inputs")
+	rate := flags.Int("rate", 60, "round frame durations to multiples of 1/rate seconds (0 to keep them)")
+	keepHidden := flags.Bool("keep-hidden", false, "keep hidden layers")
+	keepTags := flags.Bool("keep-tag-order", false, "keep the tags in their original order")
+	rgba := flags.Bool("rgba", false, "keep the RGBA color depth instead of converting to indexed")
+	outDir := flags.String("o", "", "write the files into this directory (required)")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: asevre normalize -o dir [flags] files...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no input files")
+	}
+	// The files are never overwritten in place, a bad run can't lose the sources
+	if *outDir == "" {
+		flags.Usage()
+		return fmt.Errorf("no output directory")
+	}
+
+	files := make([]asevre.ASEFile, flags.NArg())
+	for i, path := range flags.Args() {
+		file, err := asevre.LoadAseprite(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+
+		if !*keepHidden {
+			file.RemoveLayers(func(layer asevre.ASELayer) bool {
+				return !layer.IsVisible()
+			})
+		}
+		if *rate > 0 {
+			file.QuantizeDurations(*rate)
+		}
+		if !*keepTags {
+			file.SortTags()
+		}
+
+		files[i] = file
+	}
+
+	if !*rgba {
+		palette, err := sharedPalette(*paletteFile, files)
+		if err != nil {
+			return err
+		}
+		for i := range files {
+			if err := files[i].ConvertToIndexed(palette); err != nil {
+				return fmt.Errorf("%s: %v", flags.Arg(i), err)
+			}
+		}
+	}
+
+	for i, source := range flags.Args() {
+		path := filepath.Join(*outDir, filepath.Base(source))
+		if sameFile(path, source) {
+			return fmt.Errorf("%s: output would overwrite the input", source)
+		}
+		if err := asevre.SaveAseprite(path, files[i]); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	return nil
+}
+
+// sharedPalette loads the palette of the reference file, or builds one from all the files
+func sharedPalette(paletteFile string, files []asevre.ASEFile) (color.Palette, error) {
+	if paletteFile == "" {
+		return asevre.SharedPalette(files...)
+	}
+
+	reference, err := asevre.LoadAseprite(paletteFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", paletteFile, err)
+	}
+	if len(reference.Palette) == 0 {
+		return nil, fmt.Errorf("%s: file has no palette", paletteFile)
+	}
+
+	return reference.Palette, nil
+}
+
+// sameFile checks if two paths name the same existing file
+func sameFile(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	return err == nil && os.SameFile(infoA, infoB)
+}
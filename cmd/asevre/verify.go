@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/retroblast-engine/asevre"
+)
+
+// runVerify checks the structure of the files and lists their problems. It
+// fails when any file has one, to stop CI pipelines on broken assets.
+func runVerify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	quiet := flags.Bool("q", false, "only print the files with problems")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: asevre verify [-q] files...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no input files")
+	}
+
+	broken := 0
+	for _, path := range flags.Args() {
+		report, err := asevre.Verify(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if report.OK() {
+			if !*quiet {
+				fmt.Printf("%s: ok (%d frames)\n", path, len(report.Frames))
+			}
+			continue
+		}
+		broken++
+		fmt.Printf("%s: %d problems\n", path, len(report.Problems))
+		for _, problem := range report.Problems {
+			fmt.Printf("  %v\n", problem)
+		}
+	}
+
+	if broken > 0 {
+		return fmt.Errorf("%d of %d files have problems", broken, flags.NArg())
+	}
+	return nil
+}
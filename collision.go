@@ -0,0 +1,68 @@
+package asevre
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SolidProperty is the tile property that marks a tile as solid in CollisionGrid
+const SolidProperty = "solid"
+
+// CollisionGrid returns the solid cells of the first frame, one bool per tile
+// of the canvas, indexed [row][column]. With a layer name, every non-empty
+// tile of that tilemap layer is solid. With an empty name, the tiles of any
+// tilemap layer whose "solid" property is true are solid.
+func (f *ASEFile) CollisionGrid(layerName string) [][]bool {
+	tileWidth, tileHeight := f.Tileset.TileWidth, f.Tileset.TileHeight
+	if tileWidth == 0 || tileHeight == 0 {
+		return nil
+	}
+
+	cels := f.TilemapCels(0)
+
+	// The grid covers the canvas, or every cel when the canvas size is unknown
+	width, height := int(f.Header.Width), int(f.Header.Height)
+	if width == 0 || height == 0 {
+		for _, cel := range cels {
+			width = max(width, cel.X+cel.Tilemap.TilemapColumns*tileWidth)
+			height = max(height, cel.Y+cel.Tilemap.TilemapRows*tileHeight)
+		}
+	}
+	columns, rows := (width+tileWidth-1)/tileWidth, (height+tileHeight-1)/tileHeight
+
+	grid := make([][]bool, rows)
+	for row := range grid {
+		grid[row] = make([]bool, columns)
+	}
+
+	for _, cel := range cels {
+		if layerName != "" && (cel.Layer >= len(f.Layers) || f.Layers[cel.Layer].Name != layerName) {
+			continue
+		}
+		for row, tiles := range cel.Tilemap.Tiles {
+			for col, tile := range tiles {
+				solid := !f.Tileset.isEmptyTile(tile.ID)
+				if layerName == "" {
+					solid = isTrue(tile.Properties[SolidProperty])
+				}
+				if !solid {
+					continue
+				}
+				gridRow := floorDiv(cel.Y+row*tileHeight, tileHeight)
+				gridCol := floorDiv(cel.X+col*tileWidth, tileWidth)
+				if gridRow >= 0 && gridRow < rows && gridCol >= 0 && gridCol < columns {
+					grid[gridRow][gridCol] = true
+				}
+			}
+		}
+	}
+	return grid
+}
+
+// isTrue checks if a property value means true ("true", "1", "yes")
+func isTrue(value string) bool {
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	return strings.EqualFold(value, "yes")
+}
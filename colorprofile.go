@@ -0,0 +1,266 @@
+package asevre
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ColorProfile is the color space the pixels of a file are stored in (0x2007 chunk).
+type ColorProfile struct {
+	Type  WORD    // NoColorProfile, UseSRGB or UseEmbeddedICCProfile
+	Gamma float64 // Fixed gamma of sRGB profiles (1.0 = linear sRGB), 0 if not set
+	ICC   []byte  // Embedded ICC profile
+}
+
+// newColorProfile creates the public profile from the parsed chunk
+func newColorProfile(chunk *Chunk0x2007) *ColorProfile {
+	profile := &ColorProfile{Type: chunk.Type, ICC: chunk.ICCProfileData}
+	if chunk.UsesSpecialFixedGamma() {
+		profile.Gamma = float64(chunk.FixedGamma) / 65536
+	}
+	return profile
+}
+
+// IsSRGB checks if the pixels are already sRGB, the space images are shown in
+func (p *ColorProfile) IsSRGB() bool {
+	return p == nil || p.Type == NoColorProfile || (p.Type == UseSRGB && p.Gamma == 0)
+}
+
+// String describes the profile
+func (p *ColorProfile) String() string {
+	switch {
+	case p.Type == UseEmbeddedICCProfile:
+		return "embedded ICC profile"
+	case p.Gamma != 0:
+		return fmt.Sprintf("fixed gamma %g", p.Gamma)
+	}
+	return (&Chunk0x2007{Type: p.Type}).GetTypeDescription()
+}
+
+// xyzD50ToSRGB converts D50 XYZ (the ICC connection space) to linear sRGB,
+// Bradford-adapted from D65
+var xyzD50ToSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// colorConverter converts the colors of a profile to sRGB
+type colorConverter struct {
+	linear [3][256]float64 // Linear value of every channel value
+	matrix [3][3]float64   // Linear RGB to linear sRGB
+	encode [4096]uint8     // sRGB value of linear values, by steps of 1/4095
+}
+
+// newColorConverter creates the converter of a profile to sRGB, nil when the
+// pixels are already sRGB. Only the matrix/TRC RGB ICC profiles (what
+// displays and editors embed) are supported.
+func newColorConverter(p *ColorProfile) (*colorConverter, error) {
+	if p.IsSRGB() {
+		return nil, nil
+	}
+
+	c := &colorConverter{}
+	for i := range c.encode {
+		c.encode[i] = uint8(math.Round(srgbEncode(float64(i)/4095) * 255))
+	}
+
+	switch p.Type {
+	case UseSRGB:
+		// sRGB primaries with a plain gamma curve
+		for channel := range c.linear {
+			for v := range c.linear[channel] {
+				c.linear[channel][v] = math.Pow(float64(v)/255, p.Gamma)
+			}
+		}
+		c.matrix = [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	case UseEmbeddedICCProfile:
+		if err := c.readICC(p.ICC); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown color profile type %d", p.Type)
+	}
+	return c, nil
+}
+
+// srgbEncode applies the sRGB transfer curve to a linear value
+func srgbEncode(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// errICCNotSupported reports an ICC profile that can't be converted
+var errICCNotSupported = errors.New("ICC profile not supported")
+
+// readICC reads the primaries and the tone curves of a matrix/TRC ICC profile
+func (c *colorConverter) readICC(icc []byte) error {
+	if len(icc) < 132 || string(icc[16:20]) != "RGB " {
+		return errICCNotSupported
+	}
+
+	// Tag table: signature, offset and size of every tag
+	tags := map[string][]byte{}
+	count := int(binary.BigEndian.Uint32(icc[128:]))
+	for i := 0; i < count && 132+i*12+12 <= len(icc); i++ {
+		entry := icc[132+i*12:]
+		offset, size := binary.BigEndian.Uint32(entry[4:]), binary.BigEndian.Uint32(entry[8:])
+		if uint64(offset)+uint64(size) <= uint64(len(icc)) {
+			tags[string(entry[:4])] = icc[offset : offset+size]
+		}
+	}
+
+	// The primaries convert linear RGB to D50 XYZ, one column each
+	var toXYZ [3][3]float64
+	for channel, name := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz, err := iccXYZ(tags[name])
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", errICCNotSupported, name, err)
+		}
+		for row := range xyz {
+			toXYZ[row][channel] = xyz[row]
+		}
+	}
+	for row := range c.matrix {
+		for col := range c.matrix[row] {
+			for k := 0; k < 3; k++ {
+				c.matrix[row][col] += xyzD50ToSRGB[row][k] * toXYZ[k][col]
+			}
+		}
+	}
+
+	for channel, name := range []string{"rTRC", "gTRC", "bTRC"} {
+		curve, err := iccCurve(tags[name])
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", errICCNotSupported, name, err)
+		}
+		for v := range c.linear[channel] {
+			c.linear[channel][v] = curve(float64(v) / 255)
+		}
+	}
+	return nil
+}
+
+// iccFixed reads a s15Fixed16Number
+func iccFixed(data []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(data))) / 65536
+}
+
+// iccXYZ reads an XYZ tag
+func iccXYZ(tag []byte) ([3]float64, error) {
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return [3]float64{}, errors.New("not an XYZ tag")
+	}
+	return [3]float64{iccFixed(tag[8:]), iccFixed(tag[12:]), iccFixed(tag[16:])}, nil
+}
+
+// iccCurve reads a curv or para tag as a function from encoded to linear values
+func iccCurve(tag []byte) (func(float64) float64, error) {
+	if len(tag) < 12 {
+		return nil, errors.New("tag too short")
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:]))
+		if len(tag) < 12+n*2 {
+			return nil, errors.New("tag too short")
+		}
+		switch n {
+		case 0:
+			return func(x float64) float64 { return x }, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256
+			return func(x float64) float64 { return math.Pow(x, gamma) }, nil
+		}
+		// Table interpolated between its entries
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12+i*2:])) / 65535
+		}
+		return func(x float64) float64 {
+			pos := x * float64(n-1)
+			i := min(int(pos), n-2)
+			return table[i] + (table[i+1]-table[i])*(pos-float64(i))
+		}, nil
+
+	case "para":
+		// Parameters of the function types, in the order of the specification
+		paramCount := []int{1, 3, 4, 5, 7}
+		kind := int(binary.BigEndian.Uint16(tag[8:]))
+		if kind >= len(paramCount) || len(tag) < 12+paramCount[kind]*4 {
+			return nil, fmt.Errorf("bad parametric curve type %d", kind)
+		}
+		var p [7]float64
+		for i := 0; i < paramCount[kind]; i++ {
+			p[i] = iccFixed(tag[12+i*4:])
+		}
+		g, a, b, cc, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		switch kind {
+		case 0:
+			return func(x float64) float64 { return math.Pow(x, g) }, nil
+		case 1:
+			return func(x float64) float64 {
+				if a*x+b < 0 {
+					return 0
+				}
+				return math.Pow(a*x+b, g)
+			}, nil
+		case 2:
+			return func(x float64) float64 {
+				if a*x+b < 0 {
+					return cc
+				}
+				return math.Pow(a*x+b, g) + cc
+			}, nil
+		case 3:
+			return func(x float64) float64 {
+				if x < d {
+					return cc * x
+				}
+				return math.Pow(a*x+b, g)
+			}, nil
+		default:
+			return func(x float64) float64 {
+				if x < d {
+					return cc*x + f
+				}
+				return math.Pow(a*x+b, g) + e
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown curve type %q", tag[:4])
+}
+
+// convert converts a non-premultiplied color to sRGB
+func (c *colorConverter) convert(r, g, b uint8) (uint8, uint8, uint8) {
+	linear := [3]float64{c.linear[0][r], c.linear[1][g], c.linear[2][b]}
+	var out [3]uint8
+	for i, row := range c.matrix {
+		v := row[0]*linear[0] + row[1]*linear[1] + row[2]*linear[2]
+		out[i] = c.encode[int(math.Round(min(max(v, 0), 1)*4095))]
+	}
+	return out[0], out[1], out[2]
+}
+
+// convertColor converts a palette color to sRGB
+func (c *colorConverter) convertColor(col color.Color) color.Color {
+	n := color.NRGBAModel.Convert(col).(color.NRGBA)
+	n.R, n.G, n.B = c.convert(n.R, n.G, n.B)
+	return n
+}
+
+// convertImage converts the pixels of an image to sRGB in place
+func (c *colorConverter) convertImage(img *image.NRGBA) {
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		if img.Pix[i+3] != 0 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2] = c.convert(img.Pix[i], img.Pix[i+1], img.Pix[i+2])
+		}
+	}
+}
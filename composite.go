@@ -0,0 +1,346 @@
+package asevre
+
+import (
+	"cmp"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"strings"
+)
+
+// frameCel is a decoded cel placed in a frame
+type frameCel struct {
+	layerIndex int
+	x, y       int         // Cel position in the canvas
+	opacity    BYTE        // Cel opacity (0-255)
+	zIndex     int         // Cel z-index
+	image      image.Image // Cel pixels (cel-sized) for image cels
+	indices    []byte      // Palette indices of the cel pixels for indexed image cels
+	tilemap    *ASETilemap // Tiles for tilemap cels
+	userData   *UserData   // Cel user data
+	bounds     *CelBounds  // Precise bounds from the cel extra chunk
+}
+
+// Composite renders every frame as Aseprite shows it: a canvas-sized image
+// with the cels of all the layers (images and tilemaps) drawn at their position.
+func (f *ASEFile) Composite() []image.Image {
+	images := make([]image.Image, len(f.frameCels))
+	for i := range f.frameCels {
+		images[i] = f.compositeFrame(i, true)
+	}
+	return images
+}
+
+// Frames returns the decoded image of every frame, in frame order. The
+// images are plain image.Image values, usable without any rendering library.
+func (f *ASEFile) Frames() []image.Image {
+	return f.Images
+}
+
+// FrameAt returns the decoded image of a frame, false if the frame doesn't exist.
+func (f *ASEFile) FrameAt(frame int) (image.Image, bool) {
+	if frame < 0 || frame >= len(f.Images) {
+		return nil, false
+	}
+	return f.Images[frame], true
+}
+
+// CelUserData returns the user data of the cel of a layer in a frame, nil if
+// the cel has none.
+func (f *ASEFile) CelUserData(frame, layer int) *UserData {
+	c, _ := findCel(f.frameCels, frame, layer)
+	return c.userData
+}
+
+// Cel is a cel of a frame, the building block of frames for engines that
+// composite them themselves (parallax, per-layer shaders).
+type Cel struct {
+	Layer    int         // Layer index
+	X, Y     int         // Position in the canvas, in pixels
+	Opacity  BYTE        // Cel opacity, see CelOpacity
+	ZIndex   int         // Cel z-index, already applied to the order of the cels
+	Image    image.Image // Cel pixels (cel-sized), nil for tilemap cels
+	Tilemap  *ASETilemap // Tiles of tilemap cels, nil for image cels
+	UserData *UserData   // Cel user data, nil if not set
+}
+
+// Cels returns the cels of a frame in drawing order, bottom first, as
+// Aseprite composites them: layer order moved by the z-indexes. The images
+// and tilemaps are shared with the file.
+func (f *ASEFile) Cels(frame int) []Cel {
+	if frame < 0 || frame >= len(f.frameCels) {
+		return nil
+	}
+	var cels []Cel
+	for _, c := range f.sortedCels(frame) {
+		cels = append(cels, Cel{
+			Layer:    c.layerIndex,
+			X:        c.x,
+			Y:        c.y,
+			Opacity:  c.opacity,
+			ZIndex:   c.zIndex,
+			Image:    c.image,
+			Tilemap:  c.tilemap,
+			UserData: c.userData,
+		})
+	}
+	return cels
+}
+
+// CelOpacity returns the opacity the cel is drawn with: its own opacity
+// combined with the opacity of its layer and of the groups holding it.
+func (f *ASEFile) CelOpacity(c Cel) BYTE {
+	return celOpacity(frameCel{layerIndex: c.Layer, opacity: c.Opacity}, f.layerOpacities())
+}
+
+// TilemapCel is a tilemap cel of a frame.
+type TilemapCel struct {
+	Layer   int // Layer index
+	X, Y    int // Position in the canvas, in pixels
+	Tilemap ASETilemap
+}
+
+// TilemapCels returns the tilemap cels of a frame, bottom layer first.
+func (f *ASEFile) TilemapCels(frame int) []TilemapCel {
+	if frame < 0 || frame >= len(f.frameCels) {
+		return nil
+	}
+	var cels []TilemapCel
+	for _, c := range f.sortedCels(frame) {
+		if c.tilemap != nil {
+			cels = append(cels, TilemapCel{Layer: c.layerIndex, X: c.x, Y: c.y, Tilemap: *c.tilemap})
+		}
+	}
+	return cels
+}
+
+// TilemapLayer is a tilemap layer with its cels.
+type TilemapLayer struct {
+	Layer ASELayer
+	Cels  []*TilemapCel // Cel of every frame, nil in the frames where the layer is empty
+}
+
+// TilemapLayers returns the tilemap layers, bottom layer first, so layers like
+// "background", "collision" and "foreground" can be handled apart.
+func (f *ASEFile) TilemapLayers() []TilemapLayer {
+	var layers []TilemapLayer
+	for _, layer := range f.Layers {
+		if layer.Type == LayerTypeTilemap {
+			layers = append(layers, f.tilemapLayer(layer))
+		}
+	}
+	return layers
+}
+
+// TilemapLayer returns the tilemap layer with a name (case-insensitive),
+// false if there is none.
+func (f *ASEFile) TilemapLayer(name string) (TilemapLayer, bool) {
+	for _, layer := range f.Layers {
+		if layer.Type == LayerTypeTilemap && strings.EqualFold(layer.Name, name) {
+			return f.tilemapLayer(layer), true
+		}
+	}
+	return TilemapLayer{}, false
+}
+
+// tilemapLayer collects the tilemap cels of a layer
+func (f *ASEFile) tilemapLayer(layer ASELayer) TilemapLayer {
+	tilemapLayer := TilemapLayer{Layer: layer, Cels: make([]*TilemapCel, len(f.frameCels))}
+	for frame := range f.frameCels {
+		c, ok := findCel(f.frameCels, frame, layer.Index)
+		if ok && c.tilemap != nil {
+			tilemapLayer.Cels[frame] = &TilemapCel{Layer: c.layerIndex, X: c.x, Y: c.y, Tilemap: *c.tilemap}
+		}
+	}
+	return tilemapLayer
+}
+
+// findCel returns the cel of a layer in a frame
+func findCel(frameCels [][]frameCel, frame, layer int) (frameCel, bool) {
+	i := celIndex(frameCels, frame, layer)
+	if i < 0 {
+		return frameCel{}, false
+	}
+	return frameCels[frame][i], true
+}
+
+// hasImageCels checks if any frame has an image (non-tilemap) cel
+func (f *ASEFile) hasImageCels() bool {
+	for _, cels := range f.frameCels {
+		for _, c := range cels {
+			if c.image != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortedCels returns the cels of a frame in drawing order (bottom layer first),
+// moved by their z-index as Aseprite does
+func (f *ASEFile) sortedCels(frame int) []frameCel {
+	cels := slices.Clone(f.frameCels[frame])
+	slices.SortStableFunc(cels, func(a, b frameCel) int {
+		return compareCelOrder(a.layerIndex, a.zIndex, b.layerIndex, b.zIndex)
+	})
+	return cels
+}
+
+// compareCelOrder compares the drawing order of two cels: a cel with a z-index
+// is drawn as if its layer index was moved by it, and between cels ending up
+// at the same place the one with the lowest z-index is drawn first
+func compareCelOrder(layerA, zIndexA, layerB, zIndexB int) int {
+	if c := cmp.Compare(layerA+zIndexA, layerB+zIndexB); c != 0 {
+		return c
+	}
+	return cmp.Compare(zIndexA, zIndexB)
+}
+
+// compositeFrame draws the cels of a frame into a new canvas-sized image
+func (f *ASEFile) compositeFrame(frame int, withTilemaps bool) *image.RGBA {
+	return f.compositeLayers(frame, withTilemaps, f.hiddenLayers())
+}
+
+// compositeLayers draws the cels of a frame into a new canvas-sized image,
+// leaving out the layers marked in skipped
+func (f *ASEFile) compositeLayers(frame int, withTilemaps bool, skipped []bool) *image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, int(f.Header.Width), int(f.Header.Height)))
+
+	layerOpacities := f.layerOpacities()
+	for _, c := range f.sortedCels(frame) {
+		opacity := celOpacity(c, layerOpacities)
+		if opacity == 0 || isHidden(skipped, c.layerIndex) {
+			continue
+		}
+
+		switch {
+		case c.image != nil:
+			b := c.image.Bounds()
+			drawWithOpacity(canvas, b.Sub(b.Min).Add(image.Pt(c.x, c.y)), c.image, b.Min, opacity)
+		case c.tilemap != nil && withTilemaps:
+			f.drawTilemapCel(canvas, c, opacity)
+		}
+	}
+
+	return canvas
+}
+
+// celOpacity returns the opacity of a cel combined with the opacity of its
+// layer, from layerOpacities
+func celOpacity(c frameCel, layerOpacities []int) BYTE {
+	opacity := int(c.opacity)
+	if c.layerIndex < len(layerOpacities) {
+		opacity = opacity * layerOpacities[c.layerIndex] / 255
+	}
+	return BYTE(opacity)
+}
+
+// layerOpacities returns the opacity every layer is drawn with: its own
+// opacity combined with the ones of the groups holding it. Layers are opaque
+// when the header says their opacity isn't valid.
+func (f *ASEFile) layerOpacities() []int {
+	opacities := make([]int, len(f.Layers))
+	parents := f.layerParents()
+	for i, layer := range f.Layers {
+		opacities[i] = 255
+		if f.Header.IsLayerOpacityValid() {
+			opacities[i] = int(layer.Opacity)
+		}
+		// Parents come before their children
+		if parents[i] >= 0 {
+			opacities[i] = opacities[i] * opacities[parents[i]] / 255
+		}
+	}
+	return opacities
+}
+
+// drawWithOpacity draws src over dst, using a uniform mask when it is not fully opaque
+func drawWithOpacity(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, opacity BYTE) {
+	if opacity == 255 {
+		draw.Draw(dst, r, src, sp, draw.Over)
+		return
+	}
+	mask := image.NewUniform(color.Alpha{A: opacity})
+	draw.DrawMask(dst, r, src, sp, mask, image.Point{}, draw.Over)
+}
+
+// compositeLayerFrames composites every frame of every layer alone, by layer
+// name. Layers sharing a name are drawn together, and groups with the layers
+// they hold. Hidden layers are drawn too.
+func (f *ASEFile) compositeLayerFrames(workers int, alpha AlphaMode) map[string][]image.Image {
+	parents := f.layerParents()
+	frames := map[string][]image.Image{}
+	for _, layer := range f.Layers {
+		if _, done := frames[layer.Name]; done {
+			continue
+		}
+
+		// Parents come before their children
+		skipped := make([]bool, len(f.Layers))
+		for i, other := range f.Layers {
+			inGroup := parents[i] >= 0 && !skipped[parents[i]]
+			skipped[i] = other.Name != layer.Name && !inGroup
+		}
+
+		images := make([]image.Image, len(f.frameCels))
+		_ = parallel(len(images), workers, func(i int) error {
+			images[i] = withAlpha(f.compositeLayers(i, true, skipped), alpha)
+			return nil
+		})
+		frames[layer.Name] = images
+	}
+	return frames
+}
+
+// drawTilemapCel draws the tiles of a tilemap cel using the tileset images
+func (f *ASEFile) drawTilemapCel(canvas draw.Image, c frameCel, opacity BYTE) {
+	tileWidth, tileHeight := f.Tileset.TileWidth, f.Tileset.TileHeight
+
+	for row, tiles := range c.tilemap.Tiles {
+		for col, tile := range tiles {
+			if tile.Image == nil {
+				continue
+			}
+			drawTile(canvas, image.Pt(c.x+col*tileWidth, c.y+row*tileHeight), f.Tileset.FlippedTile(tile), opacity)
+		}
+	}
+}
+
+// compositeIndices merges the palette indices of the image cels of a frame into
+// a canvas-sized plane. Pixels using the transparent index let lower layers through.
+func (f *ASEFile) compositeIndices(frame int) []byte {
+	width, height := int(f.Header.Width), int(f.Header.Height)
+	transparent := f.Header.TransparentIdx
+
+	indices := make([]byte, width*height)
+	for i := range indices {
+		indices[i] = transparent
+	}
+
+	hidden := f.hiddenLayers()
+	for _, c := range f.sortedCels(frame) {
+		if c.indices == nil || isHidden(hidden, c.layerIndex) {
+			continue
+		}
+		b := c.image.Bounds()
+		for row := 0; row < b.Dy(); row++ {
+			y := c.y + row
+			if y < 0 || y >= height {
+				continue
+			}
+			for col := 0; col < b.Dx(); col++ {
+				x := c.x + col
+				if x < 0 || x >= width {
+					continue
+				}
+				index := c.indices[row*b.Dx()+col]
+				if index != transparent {
+					indices[y*width+x] = index
+				}
+			}
+		}
+	}
+
+	return indices
+}
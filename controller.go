@@ -0,0 +1,160 @@
+package asevre
+
+import (
+	"fmt"
+	"image"
+	"time"
+)
+
+// Transition tells when a state change requested with SetState happens.
+type Transition int
+
+const (
+	TransitionImmediate  Transition = iota // Switch right away
+	TransitionFinishLoop                   // Switch once the current state plays its last frame (loops or completes)
+)
+
+// AnyState matches every state in SetTransition
+const AnyState = "*"
+
+// AnimationController plays the tags of a file as the states of a state machine.
+type AnimationController struct {
+	states      map[string]*ASETag
+	transitions map[[2]string]Transition
+	current     *ASETag
+	name        string
+	pending     string // State waiting for the current one to finish its loop
+	onTrigger   func(state string, trigger Trigger)
+}
+
+// NewAnimationController creates a controller with every tag of the file as a
+// state. No state is playing until SetState is called.
+func NewAnimationController(file ASEFile) *AnimationController {
+	c := &AnimationController{
+		states:      map[string]*ASETag{},
+		transitions: map[[2]string]Transition{},
+	}
+	for i := range file.State {
+		// Every state gets its own copy of the animation
+		tag := file.State[i]
+		if _, exists := c.states[tag.Name]; !exists {
+			name := tag.Name
+			tag.Animation.OnTrigger = func(trigger Trigger) { c.trigger(name, trigger) }
+			c.states[tag.Name] = &tag
+		}
+	}
+	return c
+}
+
+// SetTransition sets how changes from a state to another happen. AnyState
+// matches every state. Changes are immediate by default.
+func (c *AnimationController) SetTransition(from, to string, transition Transition) {
+	c.transitions[[2]string{from, to}] = transition
+}
+
+// transition returns the rule for a change, the most specific one first
+func (c *AnimationController) transition(from, to string) Transition {
+	for _, key := range [][2]string{{from, to}, {from, AnyState}, {AnyState, to}, {AnyState, AnyState}} {
+		if transition, exists := c.transitions[key]; exists {
+			return transition
+		}
+	}
+	return TransitionImmediate
+}
+
+// SetState switches to the state with the given tag name, following the
+// transition rules. Setting the current state again does nothing.
+func (c *AnimationController) SetState(name string) error {
+	if _, exists := c.states[name]; !exists {
+		return fmt.Errorf("unknown state: %s", name)
+	}
+	if name == c.name {
+		c.pending = ""
+		return nil
+	}
+
+	if c.current != nil && c.transition(c.name, name) == TransitionFinishLoop && c.current.HasAnimations && !c.current.Animation.IsFinished() {
+		c.pending = name
+		return nil
+	}
+
+	c.switchTo(name)
+	return nil
+}
+
+// switchTo starts the state from its first frame
+func (c *AnimationController) switchTo(name string) {
+	c.name = name
+	c.pending = ""
+	c.current = c.states[name]
+	// Playing from the first frame fires its triggers
+	c.current.Animation.rewind()
+	c.current.Animation.Play()
+}
+
+// OnTrigger sets the function called with the triggers of every frame the
+// current state enters, the first frame included when a state starts. The
+// controller sets the OnTrigger of the animations of its states.
+func (c *AnimationController) OnTrigger(fn func(state string, trigger Trigger)) {
+	c.onTrigger = fn
+}
+
+// trigger passes a trigger of a state to the OnTrigger function
+func (c *AnimationController) trigger(state string, trigger Trigger) {
+	if c.onTrigger != nil {
+		c.onTrigger(state, trigger)
+	}
+}
+
+// State returns the name of the current state
+func (c *AnimationController) State() string {
+	return c.name
+}
+
+// Pending returns the name of the state waiting for the current one to finish its loop
+func (c *AnimationController) Pending() string {
+	return c.pending
+}
+
+// Current returns the tag of the current state, nil before the first SetState
+func (c *AnimationController) Current() *ASETag {
+	return c.current
+}
+
+// StateTag returns the tag of a state, false if there is none. Changes to its
+// animation, like SetSpeed or SetFrameDuration, apply every time the state plays.
+func (c *AnimationController) StateTag(name string) (*ASETag, bool) {
+	tag, exists := c.states[name]
+	return tag, exists
+}
+
+// SetSpeed sets the playback speed factor of every state, see Animation.SetSpeed.
+func (c *AnimationController) SetSpeed(factor float64) {
+	for _, tag := range c.states {
+		tag.Animation.SetSpeed(factor)
+	}
+}
+
+// Update advances the current state by dt and makes the pending change once
+// the current state has played its last frame.
+func (c *AnimationController) Update(dt time.Duration) {
+	if c.current == nil {
+		return
+	}
+
+	animation := &c.current.Animation
+	passes := animation.passes
+	animation.Update(dt)
+
+	if c.pending != "" && (animation.passes != passes || animation.IsFinished()) {
+		c.switchTo(c.pending)
+	}
+}
+
+// CurrentFrame returns the image of the current frame of the current state
+func (c *AnimationController) CurrentFrame() image.Image {
+	if c.current == nil {
+		return nil
+	}
+	return c.current.CurrentFrame()
+}
@@ -0,0 +1,228 @@
+package asevre
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// Decoder reads an Aseprite file frame by frame and chunk by chunk, without
+// loading the whole file in memory. The data of a chunk is only read when
+// asked for, so big cels can be skipped cheaply:
+//
+//	d, err := NewDecoder(r)
+//	for {
+//		if _, err := d.NextFrame(); err != nil {
+//			break // io.EOF after the last frame
+//		}
+//		for {
+//			chunk, err := d.NextChunk()
+//			if err != nil {
+//				break // io.EOF after the last chunk of the frame
+//			}
+//			if chunk.ChunkType == 0x2018 {
+//				data, _ := d.ChunkData()
+//				tags, _ := DecodeTags(data)
+//			}
+//		}
+//	}
+type Decoder struct {
+	r      io.Reader
+	header Header
+	offset int64 // Offset in the file of the next byte to read
+
+	frame       int         // Index of the current frame, -1 before the first one
+	frameHeader FrameHeader // Header of the current frame
+	chunksLeft  int         // Chunks of the current frame not returned yet
+	chunk       Chunk       // Last chunk returned by NextChunk
+	dataLeft    int64       // Bytes of the data of the last chunk not read yet
+}
+
+// NewDecoder reads the header of an Aseprite file and returns a decoder
+// positioned before the first frame.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	d := &Decoder{r: r, frame: -1}
+	if err := binary.Read(r, binary.LittleEndian, &d.header); err != nil {
+		return nil, err
+	}
+	if d.header.MagicNumberHeader != MagicNumber {
+		return nil, fmt.Errorf("%w: header 0x%04x", ErrBadMagic, d.header.MagicNumberHeader)
+	}
+	d.offset = int64(binary.Size(d.header))
+	return d, nil
+}
+
+// Header returns the header of the file
+func (d *Decoder) Header() Header {
+	return d.header
+}
+
+// Frame returns the index of the current frame, -1 before the first call to NextFrame
+func (d *Decoder) Frame() int {
+	return d.frame
+}
+
+// NextFrame skips what is left of the current frame and reads the header of
+// the next one. It returns io.EOF after the last frame.
+func (d *Decoder) NextFrame() (*FrameHeader, error) {
+	for d.chunksLeft > 0 {
+		if _, err := d.NextChunk(); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.skipData(); err != nil {
+		return nil, err
+	}
+	if d.frame+1 >= int(d.header.FrameCount) {
+		return nil, io.EOF
+	}
+
+	frameHeader := FrameHeader{}
+	if err := binary.Read(d.r, binary.LittleEndian, &frameHeader); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if frameHeader.MagicNumber != MagicNumberFrame {
+		return nil, fmt.Errorf("%w: frame %d 0x%04x", ErrBadMagic, d.frame+1, frameHeader.MagicNumber)
+	}
+	d.offset += int64(binary.Size(frameHeader))
+
+	d.frame++
+	d.frameHeader = frameHeader
+	d.chunksLeft = int(frameHeader.NumberOfChunks())
+	return &d.frameHeader, nil
+}
+
+// NextChunk skips the data of the previous chunk and reads the size and type
+// of the next chunk of the current frame. ChunkData is left empty, use
+// ChunkData to read it. It returns io.EOF after the last chunk of the frame.
+func (d *Decoder) NextChunk() (*Chunk, error) {
+	if err := d.skipData(); err != nil {
+		return nil, err
+	}
+	if d.chunksLeft == 0 {
+		return nil, io.EOF
+	}
+
+	chunk := Chunk{Offset: d.offset}
+	if err := binary.Read(d.r, binary.LittleEndian, &chunk.ChunkSize); err != nil {
+		return nil, newChunkError(d.frame, chunk, err)
+	}
+	if err := binary.Read(d.r, binary.LittleEndian, &chunk.ChunkType); err != nil {
+		return nil, newChunkError(d.frame, chunk, err)
+	}
+	if !chunk.IsValid() {
+		return nil, newChunkError(d.frame, chunk, fmt.Errorf("invalid chunk detected: size %d", chunk.ChunkSize))
+	}
+	d.offset += 6
+
+	d.chunksLeft--
+	d.chunk = chunk
+	d.dataLeft = int64(chunk.ChunkSize - 6)
+	return &d.chunk, nil
+}
+
+// ChunkData reads the data of the last chunk returned by NextChunk. It can
+// only be called once per chunk.
+func (d *Decoder) ChunkData() ([]BYTE, error) {
+	if d.dataLeft == 0 && d.chunk.ChunkSize > 6 {
+		return nil, errors.New("chunk data already read")
+	}
+	// The buffer grows with the data actually read, not the declared size
+	var data bytes.Buffer
+	if _, err := io.CopyN(&data, d.r, d.dataLeft); err != nil {
+		return nil, newChunkError(d.frame, d.chunk, err)
+	}
+	d.offset += d.dataLeft
+	d.dataLeft = 0
+	d.chunk.ChunkData = data.Bytes()
+	return d.chunk.ChunkData, nil
+}
+
+// skipData discards the unread data of the last chunk, seeking when the reader allows it
+func (d *Decoder) skipData() error {
+	if d.dataLeft == 0 {
+		return nil
+	}
+	var err error
+	if seeker, ok := d.r.(io.Seeker); ok {
+		_, err = seeker.Seek(d.dataLeft, io.SeekCurrent)
+	} else {
+		_, err = io.CopyN(io.Discard, d.r, d.dataLeft)
+	}
+	if err != nil {
+		return newChunkError(d.frame, d.chunk, err)
+	}
+	d.offset += d.dataLeft
+	d.dataLeft = 0
+	return nil
+}
+
+// unexpectedEOF reports an end of file in the middle of a frame as io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// DecodeTags decodes the data of a tags chunk (0x2018). The tags only hold
+// what the chunk stores: no frames, durations or user data.
+func DecodeTags(data []BYTE) ([]ASETag, error) {
+	tagsChunk, err := parseChunk0x2018(data)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]ASETag, len(tagsChunk.Tags))
+	for i, tag := range tagsChunk.Tags {
+		tags[i] = ASETag{
+			Name:      string(tag.TagName.Chars),
+			FromFrame: int(tag.FromFrame),
+			ToFrame:   int(tag.ToFrame),
+			Direction: tag.AnimationDirection,
+			Repeat:    tag.Repeat,
+			Extra:     tag.ExtraByte,
+			Color:     color.NRGBA{R: tag.Deprecated[0], G: tag.Deprecated[1], B: tag.Deprecated[2], A: 255},
+		}
+	}
+	return tags, nil
+}
+
+// DecodeChunk decodes the data of a chunk into the structure of its type
+// (*Chunk0x2004 for a layer, *Chunk0x2005 for a cel, ...). The header of the
+// file is needed by the chunks whose layout depends on it. Chunk types out of
+// the specification, or without a structure, return nil.
+func DecodeChunk(header Header, chunkType WORD, data []BYTE) (any, error) {
+	switch chunkType {
+	case 0x0004, 0x0011: // The 6-bit palette has the same layout, with values in 0-63
+		return parseChunk0x0004(data)
+	case 0x2004:
+		return parseChunk0x2004(data, header.Flags)
+	case 0x2005:
+		return parseChunk0x2005(data)
+	case 0x2006:
+		return parseChunk0x2006(data)
+	case 0x2007:
+		return parse0x2007(data)
+	case 0x2008:
+		return parseChunk0x2008(data)
+	case 0x2016:
+		return parseChunk0x2016(data)
+	case 0x2017:
+		return parseChunk0x2017(data)
+	case 0x2018:
+		return parseChunk0x2018(data)
+	case 0x2019:
+		return parseChunk0x2019(data)
+	case 0x2020:
+		return parseChunk0x2020(data)
+	case 0x2022:
+		return parseChunk0x2022(data)
+	case 0x2023:
+		return parseChunk0x2023(data)
+	}
+	return nil, nil
+}
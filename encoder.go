@@ -0,0 +1,973 @@
+package asevre
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Bitmasks used to store tiles in compressed tilemap cels (32 bits per tile)
+const (
+	TileIDBitmask       DWORD = 0x1fffffff
+	TileXFlipBitmask    DWORD = 0x80000000
+	TileYFlipBitmask    DWORD = 0x40000000
+	TileDiagFlipBitmask DWORD = 0x20000000
+)
+
+// defaultFrameDuration is used for frames without a known duration
+const defaultFrameDuration = 100 * time.Millisecond
+
+// SaveAseprite encodes an ASEFile and writes it to an .aseprite or .ase file.
+func SaveAseprite(filePath string, file ASEFile) error {
+	ext := filepath.Ext(filePath)
+	if ext != ".aseprite" && ext != ".ase" {
+		return fmt.Errorf("unsupported file type: %s", ext)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeAseprite(&buf, file); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, buf.Bytes(), 0o644)
+}
+
+// EncodeAseprite writes an ASEFile in the .aseprite format to w.
+//
+// The sprite is written with the color depth of the header: Grayscale, or
+// Indexed when the palette fits in 256 colors, in which case the palette
+// indices of the cels are used (when present) so no colors are lost to
+// quantization; RGBA otherwise. Parsed files are written with their layers
+// and the cels of every layer (position, opacity, z-index, user data), so
+// editing a file keeps what the editor shows. Files built in code, without
+// decoded cels, get a layer with ASEFile.Images (at ASEFile.TrimOffsets) and
+// a tilemap layer with ASEFile.Tilemaps, one image and one tilemap per frame.
+// ASEFile.State is written as the tags chunk, the tileset keeps its link to
+// an external file and the raw chunks of ASEFile.FrameData are written back
+// to their frames. Frames can last up to 65535ms.
+func EncodeAseprite(w io.Writer, file ASEFile) error {
+	layers, frameCels := file.encodedCels()
+	numFrames := max(len(frameCels), len(file.Durations), 1)
+	if numFrames > math.MaxUint16 {
+		return fmt.Errorf("too many frames: %d", numFrames)
+	}
+	width, height := canvasSize(file)
+	format := file.encodedPixelFormat()
+
+	// Frames starting the loop section of a tag get a "loopstart" cel user data
+	loopStarts := map[int]bool{}
+	for _, tag := range file.State {
+		if tag.Animation.LoopStart > 0 {
+			loopStarts[tag.FromFrame+tag.Animation.LoopStart] = true
+		}
+	}
+
+	var body bytes.Buffer
+	for i := 0; i < numFrames; i++ {
+		var chunks [][]byte
+
+		// The first frame carries the sprite-wide chunks
+		if i == 0 {
+			spriteChunks, err := encodeSpriteChunks(file, layers, format)
+			if err != nil {
+				return err
+			}
+			chunks = append(chunks, spriteChunks...)
+		}
+
+		if i < len(frameCels) {
+			celChunks, err := encodeCelChunks(frameCels[i], loopStarts[i], format)
+			if err != nil {
+				return fmt.Errorf("frame %d: %v", i, err)
+			}
+			chunks = append(chunks, celChunks...)
+		}
+
+		// Chunks asevre doesn't decode are written back as they were read
+		if i < len(file.FrameData) {
+			for _, chunk := range file.FrameData[i].RawChunks {
+				chunks = append(chunks, encodeChunk(chunk.ChunkType, chunk.ChunkData))
+			}
+		}
+
+		duration := defaultFrameDuration
+		if i < len(file.Durations) {
+			duration = file.Durations[i]
+		} else if file.Header.Speed != 0 {
+			duration = time.Duration(file.Header.Speed) * time.Millisecond
+		}
+
+		frame, err := encodeFrame(chunks, duration)
+		if err != nil {
+			return fmt.Errorf("frame %d: %v", i, err)
+		}
+		body.Write(frame)
+	}
+
+	// Fill the header from the file, keeping the user fields that are still valid
+	header := file.Header
+	header.FileSize = DWORD(128 + body.Len())
+	header.MagicNumberHeader = MagicNumber
+	header.FrameCount = WORD(numFrames)
+	header.Width = WORD(width)
+	header.Height = WORD(height)
+	header.ColorDepth = format.depth
+	header.TransparentIdx = 0
+	if format.depth == ColorDepthIndexed {
+		header.TransparentIdx = BYTE(format.transparentIdx)
+	}
+	header.Flags |= 1                         // Layer opacity has valid value
+	header.Flags &^= HeaderFlagLayersHaveUUID // The layer UUIDs are not kept
+	header.NumColors = WORD(len(file.Palette))
+	if header.PixelWidth == 0 || header.PixelHeight == 0 {
+		header.PixelWidth, header.PixelHeight = 1, 1
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// encodeSpriteChunks encodes the chunks of the first frame describing the
+// whole sprite: color profile, external files, palette, tileset, layers, tags,
+// slices and masks, each followed by its user data
+func encodeSpriteChunks(file ASEFile, layers []ASELayer, format pixelFormat) ([][]byte, error) {
+	var chunks [][]byte
+
+	data, err := encodeChunk0x2007(file.encodedColorProfile())
+	if err != nil {
+		return nil, fmt.Errorf("error encoding color profile: %v", err)
+	}
+	chunks = append(chunks, encodeChunk(0x2007, data))
+
+	// The tileset refers to the external files, they come first
+	if len(file.ExternalFiles) > 0 {
+		data, err := encodeChunk0x2008(file.ExternalFiles)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding external files: %v", err)
+		}
+		chunks = append(chunks, encodeChunk(0x2008, data))
+	}
+
+	if len(file.Palette) > 0 {
+		// The old palette chunk can only hold up to 256 colors
+		if len(file.Palette) <= 256 {
+			data, err := encodeChunk0x0004(file.Palette)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding palette: %v", err)
+			}
+			chunks = append(chunks, encodeChunk(0x0004, data))
+		}
+		data, err := encodeChunk0x2019(file.ColorPalette())
+		if err != nil {
+			return nil, fmt.Errorf("error encoding palette: %v", err)
+		}
+		chunks = append(chunks, encodeChunk(0x2019, data))
+		// User data after the new palette chunk belongs to the sprite
+		if file.UserData != nil {
+			data, err := encodeChunk0x2020(file.UserData)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding sprite user data: %v", err)
+			}
+			chunks = append(chunks, encodeChunk(0x2020, data))
+		}
+	}
+
+	if slices.ContainsFunc(layers, func(layer ASELayer) bool { return layer.Type == LayerTypeTilemap }) {
+		data, err := encodeChunk0x2023(file.Tileset, format)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding tileset: %v", err)
+		}
+		chunks = append(chunks, encodeChunk(0x2023, data))
+
+		// The user data of the tileset is followed by the one of every tile,
+		// up to the last tile with user data
+		tileUserData := file.Tileset.TileUserData
+		for len(tileUserData) > 0 && tileUserData[len(tileUserData)-1] == nil {
+			tileUserData = tileUserData[:len(tileUserData)-1]
+		}
+		if file.Tileset.UserData != nil || len(tileUserData) > 0 {
+			for i, userData := range append([]*UserData{file.Tileset.UserData}, tileUserData...) {
+				data, err := encodeChunk0x2020(userData)
+				if err != nil {
+					return nil, fmt.Errorf("error encoding user data of tile %d: %v", i-1, err)
+				}
+				chunks = append(chunks, encodeChunk(0x2020, data))
+			}
+		}
+	}
+
+	for _, layer := range layers {
+		data, err := encodeChunk0x2004(layer)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding layer %d: %v", layer.Index, err)
+		}
+		chunks = append(chunks, encodeChunk(0x2004, data))
+		if layer.UserData != nil {
+			data, err := encodeChunk0x2020(layer.UserData)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding user data of layer %d: %v", layer.Index, err)
+			}
+			chunks = append(chunks, encodeChunk(0x2020, data))
+		}
+	}
+
+	// The default tag of files without tags is left out
+	tags := slices.DeleteFunc(slices.Clone(file.State), func(tag ASETag) bool { return tag.synthesized })
+	if len(tags) > 0 {
+		data, err := encodeChunk0x2018(tags)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding tags: %v", err)
+		}
+		chunks = append(chunks, encodeChunk(0x2018, data))
+
+		// Every tag gets a user data chunk, empty for the tags without
+		if slices.ContainsFunc(tags, func(tag ASETag) bool { return tag.UserData != nil }) {
+			for _, tag := range tags {
+				data, err := encodeChunk0x2020(tag.UserData)
+				if err != nil {
+					return nil, fmt.Errorf("error encoding user data of tag %s: %v", tag.Name, err)
+				}
+				chunks = append(chunks, encodeChunk(0x2020, data))
+			}
+		}
+	}
+
+	for _, slice := range file.Slices {
+		data, err := encodeChunk0x2022(slice)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding slice %s: %v", slice.Name, err)
+		}
+		chunks = append(chunks, encodeChunk(0x2022, data))
+		if slice.UserData != nil {
+			data, err := encodeChunk0x2020(slice.UserData)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding user data of slice %s: %v", slice.Name, err)
+			}
+			chunks = append(chunks, encodeChunk(0x2020, data))
+		}
+	}
+
+	for _, mask := range file.Masks {
+		data, err := encodeChunk0x2016(mask)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding mask %s: %v", mask.Name, err)
+		}
+		chunks = append(chunks, encodeChunk(0x2016, data))
+	}
+
+	return chunks, nil
+}
+
+// encodeCelChunks encodes the cels of a frame with their extra and user data
+// chunks. The first cel gets the loop start marker when loopStart is set.
+func encodeCelChunks(cels []frameCel, loopStart bool, format pixelFormat) ([][]byte, error) {
+	// The loop start marker goes to the first cel without user data,
+	// unless a cel already has it
+	marker := -1
+	if loopStart && !slices.ContainsFunc(cels, func(c frameCel) bool { return isLoopStart(c.userData) }) {
+		marker = slices.IndexFunc(cels, func(c frameCel) bool { return c.userData == nil && (c.image != nil || c.tilemap != nil) })
+	}
+
+	var chunks [][]byte
+	for j, c := range cels {
+		var data []byte
+		var err error
+		switch {
+		case c.image != nil:
+			data, err = encodeImageCel(c, format)
+		case c.tilemap != nil:
+			data, err = encodeTilemapCel(c)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error encoding cel of layer %d: %v", c.layerIndex, err)
+		}
+		chunks = append(chunks, encodeChunk(0x2005, data))
+
+		if c.bounds != nil {
+			data, err := encodeChunk0x2006(*c.bounds)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding bounds of layer %d: %v", c.layerIndex, err)
+			}
+			chunks = append(chunks, encodeChunk(0x2006, data))
+		}
+
+		userData := c.userData
+		if j == marker {
+			userData = &UserData{Text: LoopStartMarker}
+		}
+		if userData != nil {
+			data, err := encodeChunk0x2020(userData)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding user data of layer %d: %v", c.layerIndex, err)
+			}
+			chunks = append(chunks, encodeChunk(0x2020, data))
+		}
+	}
+	return chunks, nil
+}
+
+// encodedCels returns the layers and the cels of every frame to write: the
+// decoded ones of parsed files, or made up from the frames and the tilemaps
+// of files built in code
+func (f *ASEFile) encodedCels() ([]ASELayer, [][]frameCel) {
+	if f.frameCels != nil {
+		return f.Layers, f.frameCels
+	}
+
+	var layers []ASELayer
+	frameCels := make([][]frameCel, max(len(f.Images), len(f.Tilemaps)))
+	if len(f.Images) > 0 {
+		layer := ASELayer{Index: len(layers), Name: "Layer", Type: LayerTypeNormal, Flags: LayerFlagVisible | LayerFlagEditable, Opacity: 255}
+		layers = append(layers, layer)
+		for i, img := range f.Images {
+			if img == nil {
+				continue
+			}
+			c := frameCel{layerIndex: layer.Index, x: img.Bounds().Min.X, y: img.Bounds().Min.Y, opacity: 255, image: img}
+			if i < len(f.Indices) {
+				c.indices = f.Indices[i]
+			}
+			// Trimmed frames go back to their place in the canvas
+			if i < len(f.TrimOffsets) {
+				c.x += f.TrimOffsets[i].X
+				c.y += f.TrimOffsets[i].Y
+			}
+			frameCels[i] = append(frameCels[i], c)
+		}
+	}
+	if len(f.Tilemaps) > 0 {
+		layer := ASELayer{Index: len(layers), Name: "Tilemap", Type: LayerTypeTilemap, Flags: LayerFlagVisible | LayerFlagEditable, Opacity: 255, TilesetIndex: f.Tileset.ID}
+		layers = append(layers, layer)
+		for i := range f.Tilemaps {
+			frameCels[i] = append(frameCels[i], frameCel{layerIndex: layer.Index, opacity: 255, tilemap: &f.Tilemaps[i]})
+		}
+	}
+	return layers, frameCels
+}
+
+// pixelFormat is how the pixels of the cels and the tiles are stored
+type pixelFormat struct {
+	depth          WORD          // ColorDepthRGBA, ColorDepthGrayscale or ColorDepthIndexed
+	palette        color.Palette // Colors of the indices (Indexed only)
+	transparentIdx int           // Index of the fully transparent pixels (Indexed only)
+}
+
+// encodedPixelFormat returns the pixel format of the header, RGBA when the
+// palette can't hold the colors of an indexed sprite
+func (f *ASEFile) encodedPixelFormat() pixelFormat {
+	switch {
+	case f.Header.ColorDepth == ColorDepthGrayscale:
+		return pixelFormat{depth: ColorDepthGrayscale}
+	case f.Header.ColorDepth == ColorDepthIndexed && len(f.Palette) > 0 && len(f.Palette) <= 256:
+		return pixelFormat{depth: ColorDepthIndexed, palette: f.Palette, transparentIdx: int(f.Header.TransparentIdx)}
+	}
+	return pixelFormat{depth: ColorDepthRGBA}
+}
+
+// encodedColorProfile returns the color profile of the pixels: the one of the
+// file, unless its colors were converted to sRGB while parsing
+func (f *ASEFile) encodedColorProfile() *ColorProfile {
+	if f.ColorProfile == nil || f.colorConverted {
+		return &ColorProfile{Type: UseSRGB}
+	}
+	return f.ColorProfile
+}
+
+// canvasSize returns the sprite size in pixels
+func canvasSize(file ASEFile) (int, int) {
+	if file.Header.Width != 0 && file.Header.Height != 0 {
+		return int(file.Header.Width), int(file.Header.Height)
+	}
+
+	var width, height int
+	for i, img := range file.Images {
+		if img == nil {
+			continue
+		}
+		bottomRight := img.Bounds().Max
+		if i < len(file.TrimOffsets) {
+			bottomRight = bottomRight.Add(file.TrimOffsets[i])
+		}
+		width = max(width, bottomRight.X)
+		height = max(height, bottomRight.Y)
+	}
+	for _, tilemap := range file.Tilemaps {
+		width = max(width, tilemap.TilemapColumns*file.Tileset.TileWidth)
+		height = max(height, tilemap.TilemapRows*file.Tileset.TileHeight)
+	}
+
+	return max(width, 1), max(height, 1)
+}
+
+// encodeBuffer collects the bytes of a chunk, keeping the first error
+type encodeBuffer struct {
+	bytes.Buffer
+	err error
+}
+
+// write appends the values in little-endian order
+func (b *encodeBuffer) write(values ...any) {
+	for _, v := range values {
+		if b.err != nil {
+			return
+		}
+		b.err = binary.Write(&b.Buffer, binary.LittleEndian, v)
+	}
+}
+
+// writeString appends a STRING (length + UTF-8 characters)
+func (b *encodeBuffer) writeString(s string) {
+	if len(s) > math.MaxUint16 {
+		b.fail(fmt.Errorf("string of %d bytes is too long", len(s)))
+		return
+	}
+	b.write(WORD(len(s)))
+	b.WriteString(s)
+}
+
+// fail records err unless an error was recorded already
+func (b *encodeBuffer) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// result returns the bytes written, or the first error
+func (b *encodeBuffer) result() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.Bytes(), nil
+}
+
+// encodeFrame prefixes the chunks of a frame with the 16 bytes frame header
+func encodeFrame(chunks [][]byte, duration time.Duration) ([]byte, error) {
+	if duration < 0 || duration > math.MaxUint16*time.Millisecond {
+		return nil, fmt.Errorf("duration %v out of range (0 to 65535ms)", duration)
+	}
+
+	var data bytes.Buffer
+	for _, chunk := range chunks {
+		data.Write(chunk)
+	}
+
+	oldChunkCount := WORD(0xFFFF)
+	if len(chunks) < 0xFFFF {
+		oldChunkCount = WORD(len(chunks))
+	}
+
+	frameHeader := FrameHeader{
+		BytesInFrame:  DWORD(16 + data.Len()),
+		MagicNumber:   MagicNumberFrame,
+		OldChunkCount: oldChunkCount,
+		FrameDuration: WORD(duration / time.Millisecond),
+		NewChunkCount: DWORD(len(chunks)),
+	}
+
+	var buf encodeBuffer
+	buf.write(&frameHeader)
+	buf.Write(data.Bytes())
+	return buf.result()
+}
+
+// encodeChunk prefixes the chunk data with its size (4 bytes) and type (2 bytes)
+func encodeChunk(chunkType WORD, data []byte) []byte {
+	chunk := make([]byte, 0, 6+len(data))
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(6+len(data)))
+	chunk = binary.LittleEndian.AppendUint16(chunk, uint16(chunkType))
+	return append(chunk, data...)
+}
+
+// encodeChunk0x2007 encodes a color profile chunk
+func encodeChunk0x2007(profile *ColorProfile) ([]byte, error) {
+	var buf encodeBuffer
+	chunk := Chunk0x2007{Type: profile.Type}
+	if profile.Gamma != 0 {
+		chunk.Flags = 1 // Use special fixed gamma
+		chunk.FixedGamma = floatToFixed(profile.Gamma)
+	}
+	buf.write(chunk.Type, chunk.Flags, chunk.FixedGamma, chunk.Reserved)
+	if chunk.Type == UseEmbeddedICCProfile {
+		buf.write(DWORD(len(profile.ICC)))
+		buf.Write(profile.ICC)
+	}
+	return buf.result()
+}
+
+// encodeChunk0x2008 encodes the external files chunk
+func encodeChunk0x2008(files []ExternalFile) ([]byte, error) {
+	var buf encodeBuffer
+	buf.write(DWORD(len(files)), [8]BYTE{})
+	for _, file := range files {
+		buf.write(DWORD(file.ID), file.Type, [7]BYTE{})
+		buf.writeString(file.Name)
+	}
+	return buf.result()
+}
+
+// encodeChunk0x0004 encodes the palette as an old palette chunk (a single packet)
+func encodeChunk0x0004(palette color.Palette) ([]byte, error) {
+	var buf encodeBuffer
+	buf.write(WORD(1), BYTE(0), BYTE(len(palette))) // 256 colors are stored as 0
+	for _, c := range palette {
+		nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+		buf.write(Color{Red: nrgba.R, Green: nrgba.G, Blue: nrgba.B})
+	}
+	return buf.result()
+}
+
+// encodeChunk0x2019 encodes the palette as a new palette chunk, with the names of the colors
+func encodeChunk0x2019(palette Palette) ([]byte, error) {
+	var buf encodeBuffer
+	chunk := Chucnk0x2019{
+		NewPaletteSize: DWORD(len(palette.Palette)),
+		FirstColor:     0,
+		LastColor:      DWORD(len(palette.Palette) - 1),
+	}
+	buf.write(chunk.NewPaletteSize, chunk.FirstColor, chunk.LastColor, chunk.Reserved)
+	for i, c := range palette.Palette {
+		nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+		buf.write(palette.Flags(i), [4]BYTE{nrgba.R, nrgba.G, nrgba.B, nrgba.A})
+		if name := palette.Name(i); name != "" {
+			buf.writeString(name)
+		}
+	}
+	return buf.result()
+}
+
+// encodeChunk0x2004 encodes a layer chunk
+func encodeChunk0x2004(layer ASELayer) ([]byte, error) {
+	var buf encodeBuffer
+	buf.write(layer.Flags, layer.Type, WORD(layer.ChildLevel))
+	buf.write(WORD(0), WORD(0)) // Default layer width and height (ignored)
+	buf.write(layer.BlendMode, layer.Opacity, [3]BYTE{})
+	buf.writeString(layer.Name)
+	if layer.Type == LayerTypeTilemap {
+		buf.write(DWORD(layer.TilesetIndex))
+	}
+	return buf.result()
+}
+
+// encodeChunk0x2018 encodes the tags chunk
+func encodeChunk0x2018(tags []ASETag) ([]byte, error) {
+	var buf encodeBuffer
+	buf.write(WORD(len(tags)), [8]BYTE{})
+	for _, tag := range tags {
+		buf.write(WORD(tag.FromFrame), WORD(tag.ToFrame), tag.Direction, tag.Repeat, [6]BYTE{})
+		// Deprecated tag color, still read when there is no tag user data
+		var rgb [3]BYTE
+		if tag.Color != nil {
+			c := color.NRGBAModel.Convert(tag.Color).(color.NRGBA)
+			rgb = [3]BYTE{c.R, c.G, c.B}
+		}
+		buf.write(rgb, tag.Extra)
+		buf.writeString(tag.Name)
+	}
+	return buf.result()
+}
+
+// encodeChunk0x2022 encodes a slice chunk
+func encodeChunk0x2022(slice ASESlice) ([]byte, error) {
+	var buf encodeBuffer
+	buf.write(DWORD(len(slice.Keys)), slice.Flags, DWORD(0))
+	buf.writeString(slice.Name)
+	for _, key := range slice.Keys {
+		buf.write(DWORD(key.Frame), LONG(key.Bounds.Min.X), LONG(key.Bounds.Min.Y), DWORD(key.Bounds.Dx()), DWORD(key.Bounds.Dy()))
+		if slice.IsNinePatch() {
+			buf.write(LONG(key.Center.Min.X), LONG(key.Center.Min.Y), DWORD(key.Center.Dx()), DWORD(key.Center.Dy()))
+		}
+		if slice.HasPivot() {
+			buf.write(LONG(key.Pivot.X), LONG(key.Pivot.Y))
+		}
+	}
+	return buf.result()
+}
+
+// encodeChunk0x2016 encodes a mask chunk
+func encodeChunk0x2016(mask Mask) ([]byte, error) {
+	var buf encodeBuffer
+	buf.write(SHORT(mask.Bounds.Min.X), SHORT(mask.Bounds.Min.Y), WORD(mask.Bounds.Dx()), WORD(mask.Bounds.Dy()), [8]BYTE{})
+	buf.writeString(mask.Name)
+
+	// Missing rows are left empty
+	bitmap := make([]byte, mask.Bounds.Dy()*maskStride(mask.Bounds.Dx()))
+	copy(bitmap, mask.Bitmap)
+	buf.Write(bitmap)
+	return buf.result()
+}
+
+// encodePixels returns the pixels of img row by row in the given format. Indexed
+// pixels are taken from indices when it matches the image size, fully
+// transparent pixels get the transparent index otherwise.
+func encodePixels(img image.Image, indices []byte, format pixelFormat) []byte {
+	b := img.Bounds()
+
+	switch format.depth {
+	case ColorDepthIndexed:
+		if len(indices) == b.Dx()*b.Dy() {
+			return indices
+		}
+		pixels := make([]byte, b.Dx()*b.Dy())
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				c := img.At(b.Min.X+x, b.Min.Y+y)
+				index := format.transparentIdx
+				if _, _, _, a := c.RGBA(); a != 0 {
+					index = format.palette.Index(c)
+				}
+				pixels[y*b.Dx()+x] = BYTE(index)
+			}
+		}
+		return pixels
+
+	case ColorDepthGrayscale:
+		// Value and alpha, the value is the luminance of the color
+		pixels := make([]byte, 2*b.Dx()*b.Dy())
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+				gray := color.GrayModel.Convert(color.NRGBA{R: c.R, G: c.G, B: c.B, A: 255}).(color.Gray)
+				i := 2 * (y*b.Dx() + x)
+				pixels[i], pixels[i+1] = gray.Y, c.A
+			}
+		}
+		return pixels
+	}
+
+	// Aseprite stores non-premultiplied RGBA pixels
+	nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			nrgba.Set(x, y, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return nrgba.Pix
+}
+
+// encodeChunk0x2006 encodes a cel extra chunk with the precise bounds of a cel
+func encodeChunk0x2006(bounds CelBounds) ([]byte, error) {
+	var buf encodeBuffer
+	chunk := Chunk0x2006{
+		Flags:  CelExtraPreciseBounds,
+		X:      floatToFixed(bounds.X),
+		Y:      floatToFixed(bounds.Y),
+		Width:  floatToFixed(bounds.Width),
+		Height: floatToFixed(bounds.Height),
+	}
+	buf.write(&chunk)
+	return buf.result()
+}
+
+// floatToFixed converts a value to 16.16 fixed point
+func floatToFixed(v float64) FIXED {
+	return FIXED(math.Round(v * 65536))
+}
+
+// encodeChunk0x2020 encodes a user data chunk, without any field for nil
+func encodeChunk0x2020(userData *UserData) ([]byte, error) {
+	if userData == nil {
+		userData = &UserData{}
+	}
+
+	var flags DWORD
+	if userData.Text != "" {
+		flags |= UserDataHasText
+	}
+	if userData.Color != nil {
+		flags |= UserDataHasColor
+	}
+	if len(userData.Properties) > 0 || len(userData.Extensions) > 0 {
+		flags |= UserDataHasProperties
+	}
+
+	var buf encodeBuffer
+	buf.write(flags)
+	if flags&UserDataHasText != 0 {
+		buf.writeString(userData.Text)
+	}
+	if flags&UserDataHasColor != 0 {
+		c := color.NRGBAModel.Convert(userData.Color).(color.NRGBA)
+		buf.write([4]BYTE{c.R, c.G, c.B, c.A})
+	}
+	if flags&UserDataHasProperties != 0 {
+		// The maps of the extensions follow the user properties (key 0)
+		propertyMaps := map[uint32]map[string]any{}
+		var keys []uint32
+		for key, properties := range userData.Extensions {
+			propertyMaps[key] = properties
+			keys = append(keys, key)
+		}
+		if len(userData.Properties) > 0 {
+			propertyMaps[0] = userData.Properties
+			keys = append(keys, 0)
+		}
+		slices.Sort(keys)
+
+		var properties encodeBuffer
+		for _, key := range keys {
+			properties.write(DWORD(key))
+			if err := writeProperties(&properties, propertyMaps[key]); err != nil {
+				return nil, err
+			}
+		}
+		// The size counts itself and the number of maps
+		buf.write(DWORD(8+properties.Len()), DWORD(len(propertyMaps)))
+		buf.Write(properties.Bytes())
+	}
+	return buf.result()
+}
+
+// writeProperties writes the number of properties followed by every name,
+// type and value, in name order
+func writeProperties(buf *encodeBuffer, properties map[string]any) error {
+	buf.write(DWORD(len(properties)))
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		buf.writeString(name)
+		if err := writePropertyValue(buf, properties[name], true); err != nil {
+			return fmt.Errorf("property %q: %v", name, err)
+		}
+	}
+	return buf.err
+}
+
+// writePropertyValue writes a property value, preceded by its type when
+// withType is set. Values use the types readPropertyValue returns.
+func writePropertyValue(buf *encodeBuffer, value any, withType bool) error {
+	var valueType WORD
+	var data any
+	switch v := value.(type) {
+	case bool:
+		valueType, data = PropertyBool, BYTE(0)
+		if v {
+			data = BYTE(1)
+		}
+	case int8:
+		valueType, data = PropertyInt8, v
+	case uint8:
+		valueType, data = PropertyUint8, v
+	case int16:
+		valueType, data = PropertyInt16, v
+	case uint16:
+		valueType, data = PropertyUint16, v
+	case int32:
+		valueType, data = PropertyInt32, v
+	case uint32:
+		valueType, data = PropertyUint32, v
+	case int64:
+		valueType, data = PropertyInt64, v
+	case uint64:
+		valueType, data = PropertyUint64, v
+	case float32:
+		valueType, data = PropertyFloat, math.Float32bits(v)
+	case float64:
+		valueType, data = PropertyDouble, math.Float64bits(v)
+	case string:
+		if withType {
+			buf.write(PropertyString)
+		}
+		buf.writeString(v)
+		return buf.err
+	case image.Point:
+		valueType, data = PropertyPoint, [2]int32{int32(v.X), int32(v.Y)}
+	case image.Rectangle:
+		valueType, data = PropertyRect, [4]int32{int32(v.Min.X), int32(v.Min.Y), int32(v.Dx()), int32(v.Dy())}
+	case UUID:
+		valueType, data = PropertyUUID, v
+	case []any:
+		if withType {
+			buf.write(PropertyVector)
+		}
+		// Element type 0: every element has its own type
+		buf.write(DWORD(len(v)), WORD(0))
+		for i, element := range v {
+			if err := writePropertyValue(buf, element, true); err != nil {
+				return fmt.Errorf("element %d: %v", i, err)
+			}
+		}
+		return buf.err
+	case map[string]any:
+		if withType {
+			buf.write(PropertyProperties)
+		}
+		return writeProperties(buf, v)
+	default:
+		return fmt.Errorf("unsupported property type %T", value)
+	}
+
+	if withType {
+		buf.write(valueType)
+	}
+	buf.write(data)
+	return buf.err
+}
+
+// encodeTilesetFlags returns the flags of the tileset chunk
+func encodeTilesetFlags(flags TilesetFlags) DWORD {
+	var dword DWORD
+	for flag, set := range map[DWORD]bool{
+		FlagIncludeLinkToExternalFile: flags.IncludeLinkToExternalFile,
+		FlagIncludeTilesInsideFile:    flags.IncludeTilesInsideFile,
+		FlagTileIDZeroAsEmptyTile:     flags.TileIDZeroAsEmptyTile,
+		FlagXFlipAutoMatch:            flags.XFlipAutoMatch,
+		FlagYFlipAutoMatch:            flags.YFlipAutoMatch,
+		FlagDiagonalFlipAutoMatch:     flags.DiagonalFlipAutoMatch,
+	} {
+		if set {
+			dword |= flag
+		}
+	}
+	return dword
+}
+
+// encodeChunk0x2023 encodes the tileset chunk, with the link to its external
+// file and the tiles as its flags say. Tilesets built in code, without flags,
+// store their tiles inside the file with tile 0 as the empty tile.
+func encodeChunk0x2023(tileset ASETileset, format pixelFormat) ([]byte, error) {
+	flags := tileset.Flags
+	if flags == (TilesetFlags{}) {
+		flags = TilesetFlags{IncludeTilesInsideFile: true, TileIDZeroAsEmptyTile: true}
+	}
+	// The tiles are stored somewhere
+	if !flags.IncludeLinkToExternalFile {
+		flags.IncludeTilesInsideFile = true
+	}
+
+	tileWidth, tileHeight := tileset.TileWidth, tileset.TileHeight
+	if tileWidth == 0 || tileHeight == 0 {
+		return nil, fmt.Errorf("invalid tile size: %dx%d", tileWidth, tileHeight)
+	}
+
+	var buf encodeBuffer
+	buf.write(DWORD(tileset.ID), encodeTilesetFlags(flags), DWORD(len(tileset.Tiles)))
+	buf.write(WORD(tileWidth), WORD(tileHeight), SHORT(tileset.BaseIndex), [14]BYTE{})
+	buf.writeString(tileset.Name)
+	if flags.IncludeLinkToExternalFile {
+		buf.write(DWORD(tileset.ExternalFileID), DWORD(tileset.ExternalTilesetID))
+	}
+	if !flags.IncludeTilesInsideFile {
+		return buf.result()
+	}
+
+	// The tileset image is a vertical strip of tiles: (Tile Width) x (Tile Height x Number of Tiles)
+	strip := image.NewNRGBA(image.Rect(0, 0, tileWidth, tileHeight*len(tileset.Tiles)))
+	for i, tile := range tileset.Tiles {
+		if tile == nil {
+			continue
+		}
+		b := tile.Bounds()
+		for y := 0; y < tileHeight && b.Min.Y+y < b.Max.Y; y++ {
+			for x := 0; x < tileWidth && b.Min.X+x < b.Max.X; x++ {
+				strip.Set(x, i*tileHeight+y, tile.At(b.Min.X+x, b.Min.Y+y))
+			}
+		}
+	}
+
+	compressed, err := compress(encodePixels(strip, nil, format))
+	if err != nil {
+		return nil, fmt.Errorf("error compressing Tileset Image data: %v", err)
+	}
+	buf.write(DWORD(len(compressed)))
+	buf.Write(compressed)
+	return buf.result()
+}
+
+// encodeCelHeader encodes the first 16 bytes shared by every cel chunk
+func encodeCelHeader(buf *encodeBuffer, c frameCel, celType CelDataType) {
+	chunk := Chunk0x2005{
+		LayerIndex:   WORD(c.layerIndex),
+		XPosition:    SHORT(c.x),
+		YPosition:    SHORT(c.y),
+		OpacityLevel: c.opacity,
+		CelType:      celType,
+		ZIndex:       SHORT(c.zIndex),
+	}
+	buf.write(chunk.LayerIndex, chunk.XPosition, chunk.YPosition, chunk.OpacityLevel, chunk.CelType, chunk.ZIndex, chunk.Reserved)
+}
+
+// encodeImageCel encodes the image of a cel as a compressed image cel
+func encodeImageCel(c frameCel, format pixelFormat) ([]byte, error) {
+	b := c.image.Bounds()
+	if b.Empty() {
+		return nil, fmt.Errorf("image is empty")
+	}
+
+	compressed, err := compress(encodePixels(c.image, c.indices, format))
+	if err != nil {
+		return nil, fmt.Errorf("error compressing image data: %v", err)
+	}
+
+	var buf encodeBuffer
+	encodeCelHeader(&buf, c, CompressedImageData)
+	buf.write(WORD(b.Dx()), WORD(b.Dy()))
+	buf.Write(compressed)
+	return buf.result()
+}
+
+// encodeTilemapCel encodes the tilemap of a cel as a compressed tilemap cel (32 bits per tile)
+func encodeTilemapCel(c frameCel) ([]byte, error) {
+	tilemap := *c.tilemap
+	rows, cols := tilemap.TilemapRows, tilemap.TilemapColumns
+	if rows == 0 || cols == 0 {
+		return nil, fmt.Errorf("tilemap is empty")
+	}
+
+	tiles := make([]byte, 0, 4*rows*cols)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			var value DWORD
+			if row < len(tilemap.Tiles) && col < len(tilemap.Tiles[row]) {
+				tile := tilemap.Tiles[row][col]
+				value = DWORD(tile.ID) & TileIDBitmask
+				if tile.XFlip {
+					value |= TileXFlipBitmask
+				}
+				if tile.YFlip {
+					value |= TileYFlipBitmask
+				}
+				if tile.DiagonalFlip {
+					value |= TileDiagFlipBitmask
+				}
+			}
+			tiles = binary.LittleEndian.AppendUint32(tiles, uint32(value))
+		}
+	}
+
+	compressed, err := compress(tiles)
+	if err != nil {
+		return nil, fmt.Errorf("error compressing tile data: %v", err)
+	}
+
+	var buf encodeBuffer
+	encodeCelHeader(&buf, c, CompressedTilemapData)
+	tilemapHeader := CompressedTilemap{
+		Width:               WORD(cols),
+		Height:              WORD(rows),
+		BitsPerTile:         32,
+		TileIDBitmask:       TileIDBitmask,
+		XFlipBitmask:        TileXFlipBitmask,
+		YFlipBitmask:        TileYFlipBitmask,
+		DiagonalFlipBitmask: TileDiagFlipBitmask,
+	}
+	buf.write(tilemapHeader.Width, tilemapHeader.Height, tilemapHeader.BitsPerTile, tilemapHeader.TileIDBitmask,
+		tilemapHeader.XFlipBitmask, tilemapHeader.YFlipBitmask, tilemapHeader.DiagonalFlipBitmask, tilemapHeader.Reserved)
+	buf.Write(compressed)
+	return buf.result()
+}
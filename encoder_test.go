@@ -0,0 +1,313 @@
+package asevre
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// roundTrip encodes a file and parses the result
+func roundTrip(t *testing.T, file ASEFile, opts ...ParseOption) ASEFile {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := EncodeAseprite(&buf, file); err != nil {
+		t.Fatalf("EncodeAseprite: %v", err)
+	}
+	parsed, err := ParseAsepriteBytes(buf.Bytes(), append(opts, WithStrict())...)
+	if err != nil {
+		t.Fatalf("ParseAsepriteBytes: %v", err)
+	}
+	return parsed
+}
+
+// samePixels checks that two images have the same non-premultiplied colors
+func samePixels(t *testing.T, got, want image.Image) {
+	t.Helper()
+	if got.Bounds().Size() != want.Bounds().Size() {
+		t.Fatalf("image size %v, want %v", got.Bounds().Size(), want.Bounds().Size())
+	}
+	gb, wb := got.Bounds(), want.Bounds()
+	for y := 0; y < wb.Dy(); y++ {
+		for x := 0; x < wb.Dx(); x++ {
+			g := color.NRGBAModel.Convert(got.At(gb.Min.X+x, gb.Min.Y+y))
+			w := color.NRGBAModel.Convert(want.At(wb.Min.X+x, wb.Min.Y+y))
+			if g != w {
+				t.Fatalf("pixel (%d,%d) is %v, want %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+func TestEncodeRoundTripRGBA(t *testing.T) {
+	frames := make([]image.Image, 3)
+	for i := range frames {
+		img := image.NewNRGBA(image.Rect(0, 0, 8, 6))
+		img.Set(i, 1, color.NRGBA{R: 255, G: uint8(i * 40), B: 10, A: 255})
+		img.Set(7, 5, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+		frames[i] = img
+	}
+	file := ASEFile{
+		Header:    Header{Width: 8, Height: 6, ColorDepth: ColorDepthRGBA},
+		Images:    frames,
+		Durations: []time.Duration{100 * time.Millisecond, 50 * time.Millisecond, 200 * time.Millisecond},
+		State:     []ASETag{{Name: "walk", FromFrame: 1, ToFrame: 2, Direction: Forward}},
+	}
+
+	parsed := roundTrip(t, file)
+	if len(parsed.Images) != len(frames) {
+		t.Fatalf("%d frames, want %d", len(parsed.Images), len(frames))
+	}
+	for i := range frames {
+		samePixels(t, parsed.Images[i], frames[i])
+	}
+	if !reflect.DeepEqual(parsed.Durations, file.Durations) {
+		t.Errorf("durations %v, want %v", parsed.Durations, file.Durations)
+	}
+	tag, ok := parsed.Tag("walk")
+	if !ok || tag.FromFrame != 1 || tag.ToFrame != 2 {
+		t.Errorf("tag walk = %+v, %t", tag, ok)
+	}
+}
+
+func TestEncodeRoundTripIndexed(t *testing.T) {
+	palette := color.Palette{
+		color.NRGBA{A: 255},
+		color.NRGBA{}, // Transparent
+		color.NRGBA{R: 255, A: 255},
+		color.NRGBA{G: 255, A: 255},
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, palette[2])
+	img.Set(2, 3, palette[3])
+	img.Set(3, 0, palette[0])
+	file := ASEFile{
+		Header:  Header{Width: 4, Height: 4, ColorDepth: ColorDepthIndexed, TransparentIdx: 1},
+		Palette: palette,
+		Images:  []image.Image{img},
+	}
+
+	parsed := roundTrip(t, file, WithPaletteIndices())
+	if parsed.Header.ColorDepth != ColorDepthIndexed || parsed.Header.TransparentIdx != 1 {
+		t.Fatalf("color depth %d, transparent index %d", parsed.Header.ColorDepth, parsed.Header.TransparentIdx)
+	}
+	samePixels(t, parsed.Images[0], img)
+
+	want := []byte{
+		1, 1, 1, 0,
+		1, 2, 1, 1,
+		1, 1, 1, 1,
+		1, 1, 3, 1,
+	}
+	if len(parsed.Indices) != 1 || !bytes.Equal(parsed.Indices[0], want) {
+		t.Errorf("indices %v, want %v", parsed.Indices, want)
+	}
+}
+
+func TestEncodeRoundTripTilemap(t *testing.T) {
+	tile := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	tile.Set(0, 0, color.NRGBA{R: 200, A: 255})
+	tile.Set(3, 2, color.NRGBA{B: 200, A: 255})
+	tilemaps := []ASETilemap{
+		{TilemapColumns: 3, TilemapRows: 2, Tiles: [][]Tile{
+			{{ID: 1}, {ID: 0}, {ID: 1, XFlip: true}},
+			{{ID: 1, YFlip: true}, {ID: 1, DiagonalFlip: true}, {ID: 0}},
+		}},
+		{TilemapColumns: 3, TilemapRows: 2, Tiles: [][]Tile{
+			{{ID: 0}, {ID: 1}, {ID: 0}},
+			{{ID: 0}, {ID: 0}, {ID: 1}},
+		}},
+	}
+	file := ASEFile{
+		Header:   Header{Width: 12, Height: 8, ColorDepth: ColorDepthRGBA},
+		Tileset:  ASETileset{TileWidth: 4, TileHeight: 4, Tiles: []image.Image{image.NewNRGBA(tile.Rect), tile}},
+		Tilemaps: tilemaps,
+	}
+
+	parsed := roundTrip(t, file)
+	if len(parsed.Tilemaps) != len(tilemaps) {
+		t.Fatalf("%d tilemaps, want %d", len(parsed.Tilemaps), len(tilemaps))
+	}
+	if len(parsed.Tileset.Tiles) != 2 {
+		t.Fatalf("%d tiles, want 2", len(parsed.Tileset.Tiles))
+	}
+	samePixels(t, parsed.Tileset.Tiles[1], tile)
+	for i, tilemap := range tilemaps {
+		for row := range tilemap.Tiles {
+			for col, want := range tilemap.Tiles[row] {
+				got := parsed.Tilemaps[i].Tiles[row][col]
+				if got.ID != want.ID || got.XFlip != want.XFlip || got.YFlip != want.YFlip || got.DiagonalFlip != want.DiagonalFlip {
+					t.Errorf("tilemap %d tile (%d,%d) = %+v, want %+v", i, col, row, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeRoundTripLayers(t *testing.T) {
+	top := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	top.Set(1, 1, color.NRGBA{R: 1, G: 2, B: 3, A: 255})
+	bottom := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	bottom.Set(0, 0, color.NRGBA{R: 9, G: 9, B: 9, A: 128})
+
+	file := ASEFile{
+		Header: Header{Width: 8, Height: 8, ColorDepth: ColorDepthRGBA},
+		Layers: []ASELayer{
+			{Index: 0, Name: "bottom", Flags: LayerFlagVisible, Opacity: 200, BlendMode: BlendMultiply,
+				UserData: &UserData{Text: "ground", Properties: map[string]any{"solid": true, "size": image.Pt(2, 3)}}},
+			{Index: 1, Name: "group", Type: LayerTypeGroup, Flags: LayerFlagVisible, Opacity: 255},
+			{Index: 2, Name: "hidden", ChildLevel: 1, Opacity: 255},
+		},
+		Durations: []time.Duration{100 * time.Millisecond, 100 * time.Millisecond},
+	}
+	file.frameCels = [][]frameCel{
+		{
+			{layerIndex: 0, x: 2, y: 3, opacity: 100, zIndex: 1, image: bottom, userData: &UserData{Text: "hit:1"}},
+			{layerIndex: 2, x: -1, y: 0, opacity: 255, image: top},
+		},
+		{
+			{layerIndex: 0, opacity: 255, image: bottom},
+		},
+	}
+
+	parsed := roundTrip(t, file)
+	for i, want := range file.Layers {
+		got := parsed.Layers[i]
+		if got.Name != want.Name || got.Type != want.Type || got.Flags != want.Flags || got.ChildLevel != want.ChildLevel ||
+			got.Opacity != want.Opacity || got.BlendMode != want.BlendMode || !reflect.DeepEqual(got.UserData, want.UserData) {
+			t.Errorf("layer %d = %+v, want %+v", i, got, want)
+		}
+	}
+	for frame := range file.frameCels {
+		got := parsed.Cels(frame)
+		want := file.Cels(frame)
+		if len(got) != len(want) {
+			t.Fatalf("frame %d: %d cels, want %d", frame, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].Layer != want[i].Layer || got[i].X != want[i].X || got[i].Y != want[i].Y ||
+				got[i].Opacity != want[i].Opacity || got[i].ZIndex != want[i].ZIndex || !reflect.DeepEqual(got[i].UserData, want[i].UserData) {
+				t.Errorf("frame %d cel %d = %+v, want %+v", frame, i, got[i], want[i])
+			}
+			samePixels(t, got[i].Image, want[i].Image)
+		}
+	}
+
+	// Writing the parsed file again gives the same file
+	var first, second bytes.Buffer
+	if err := EncodeAseprite(&first, file); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncodeAseprite(&second, parsed); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("parsed file encodes differently")
+	}
+}
+
+func TestEncodeRoundTripUserData(t *testing.T) {
+	tile := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	tile.Set(1, 1, color.NRGBA{G: 255, A: 255})
+	file := ASEFile{
+		Header: Header{Width: 4, Height: 2, ColorDepth: ColorDepthRGBA},
+		Tileset: ASETileset{
+			Name: "ground", BaseIndex: 5, TileWidth: 2, TileHeight: 2,
+			Tiles:        []image.Image{image.NewNRGBA(tile.Rect), tile, tile},
+			UserData:     &UserData{Text: "tiles"},
+			TileUserData: []*UserData{nil, {Properties: map[string]any{"solid": true}}, nil},
+		},
+		Tilemaps: []ASETilemap{{TilemapColumns: 2, TilemapRows: 1, Tiles: [][]Tile{{{ID: 1}, {ID: 2}}}}},
+		State: []ASETag{
+			{Name: "idle", UserData: &UserData{Text: "loop", Properties: map[string]any{"speed": int32(2)}}},
+			{Name: "hit"},
+		},
+		Slices: []ASESlice{
+			{Name: "hitbox", Keys: []SliceKey{{Bounds: image.Rect(0, 0, 2, 2)}}, UserData: &UserData{Text: "damage"}},
+			{Name: "plain", Keys: []SliceKey{{Bounds: image.Rect(1, 0, 3, 1)}}},
+		},
+	}
+
+	parsed := roundTrip(t, file)
+	for _, want := range file.State {
+		got, ok := parsed.Tag(want.Name)
+		if !ok || !reflect.DeepEqual(got.UserData, want.UserData) {
+			t.Errorf("tag %s user data = %+v, want %+v", want.Name, got.UserData, want.UserData)
+		}
+	}
+	for i, want := range file.Slices {
+		if got := parsed.Slices[i]; got.Name != want.Name || !reflect.DeepEqual(got.UserData, want.UserData) {
+			t.Errorf("slice %d = %+v, want %+v", i, got, want)
+		}
+	}
+	tileset := parsed.Tileset
+	if tileset.Name != "ground" || tileset.BaseIndex != 5 {
+		t.Errorf("tileset name %q, base index %d", tileset.Name, tileset.BaseIndex)
+	}
+	if !reflect.DeepEqual(tileset.UserData, file.Tileset.UserData) {
+		t.Errorf("tileset user data = %+v, want %+v", tileset.UserData, file.Tileset.UserData)
+	}
+	if len(tileset.TileUserData) != 3 || tileset.TileUserData[0] != nil || !reflect.DeepEqual(tileset.TileUserData[1], file.Tileset.TileUserData[1]) {
+		t.Errorf("tile user data = %v, want %v", tileset.TileUserData, file.Tileset.TileUserData)
+	}
+}
+
+func TestEncodeRoundTripGrayscale(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.NRGBA{R: 80, G: 80, B: 80, A: 255})
+	img.Set(2, 1, color.NRGBA{R: 200, G: 200, B: 200, A: 100})
+	file := ASEFile{
+		Header: Header{Width: 3, Height: 2, ColorDepth: ColorDepthGrayscale},
+		Images: []image.Image{img},
+	}
+
+	parsed := roundTrip(t, file)
+	if parsed.Header.ColorDepth != ColorDepthGrayscale {
+		t.Fatalf("color depth %d, want %d", parsed.Header.ColorDepth, ColorDepthGrayscale)
+	}
+	samePixels(t, parsed.Cels(0)[0].Image, img)
+}
+
+func TestEncodeLinkedTileset(t *testing.T) {
+	tile := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	tile.Set(0, 1, color.NRGBA{B: 255, A: 255})
+	external := ASEFile{Tileset: ASETileset{ID: 3, TileWidth: 2, TileHeight: 2, Tiles: []image.Image{image.NewNRGBA(tile.Rect), tile}}}
+
+	file := ASEFile{
+		Header:        Header{Width: 2, Height: 2, ColorDepth: ColorDepthRGBA},
+		ExternalFiles: []ExternalFile{{ID: 7, Type: ExternalTileset, Name: "tiles.aseprite"}},
+		Tileset: ASETileset{
+			Name: "linked", TileWidth: 2, TileHeight: 2, Tiles: external.Tileset.Tiles,
+			Flags:          TilesetFlags{IncludeLinkToExternalFile: true, TileIDZeroAsEmptyTile: true},
+			ExternalFileID: 7, ExternalTilesetID: 3,
+		},
+		Tilemaps: []ASETilemap{{TilemapColumns: 1, TilemapRows: 1, Tiles: [][]Tile{{{ID: 1}}}}},
+	}
+
+	var resolved []ExternalFile
+	parsed := roundTrip(t, file, WithExternalResolver(func(file ExternalFile) (ASEFile, error) {
+		resolved = append(resolved, file)
+		return external, nil
+	}))
+	if !reflect.DeepEqual(resolved, file.ExternalFiles) {
+		t.Fatalf("resolved %v, want %v", resolved, file.ExternalFiles)
+	}
+	tileset := parsed.Tileset
+	if !tileset.Flags.IncludeLinkToExternalFile || tileset.Flags.IncludeTilesInsideFile || tileset.ExternalFileID != 7 || tileset.ExternalTilesetID != 3 {
+		t.Errorf("tileset flags %+v, external file %d, tileset %d", tileset.Flags, tileset.ExternalFileID, tileset.ExternalTilesetID)
+	}
+	samePixels(t, tileset.Tiles[1], tile)
+}
+
+func TestEncodeDurationRange(t *testing.T) {
+	file := ASEFile{
+		Header:    Header{Width: 1, Height: 1, ColorDepth: ColorDepthRGBA},
+		Images:    []image.Image{image.NewNRGBA(image.Rect(0, 0, 1, 1))},
+		Durations: []time.Duration{70 * time.Second},
+	}
+	if err := EncodeAseprite(&bytes.Buffer{}, file); err == nil {
+		t.Error("duration of 70s encoded without error")
+	}
+}
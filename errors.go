@@ -0,0 +1,69 @@
+package asevre
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrBadMagic is returned when the file or a frame doesn't start with the expected magic number.
+	ErrBadMagic = errors.New("bad magic number")
+	// ErrTruncatedChunk is returned when the data ends before a chunk is complete.
+	ErrTruncatedChunk = errors.New("truncated chunk")
+	// ErrLimitExceeded is returned when a file declares more data than the parse limits allow.
+	ErrLimitExceeded = errors.New("limit exceeded")
+	// ErrTagRange is returned when the frames of a tag aren't frames of the file.
+	ErrTagRange = errors.New("tag frames out of range")
+	// ErrTagNotFound is returned when WithTags selects a tag the file doesn't have.
+	ErrTagNotFound = errors.New("tag not found")
+)
+
+// ChunkError reports a chunk that could not be read or decoded.
+type ChunkError struct {
+	Frame  int   // Frame index
+	Type   WORD  // Chunk type
+	Offset int64 // Offset of the chunk in the file
+	Err    error // Underlying error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("frame %d: chunk 0x%04x at offset %d: %v", e.Frame, e.Type, e.Offset, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// newChunkError wraps an error of a chunk with its position. Short reads are
+// reported as ErrTruncatedChunk.
+func newChunkError(frame int, chunk Chunk, err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = fmt.Errorf("%w: %v", ErrTruncatedChunk, err)
+	}
+	return &ChunkError{Frame: frame, Type: chunk.ChunkType, Offset: chunk.Offset, Err: err}
+}
+
+// TruncatedError reports a file whose data ends before its last frame. The
+// frames before Frame were read completely: the file holds them, returned
+// along with the error.
+type TruncatedError struct {
+	Frame       int   // Frame cut by the end of the data
+	Frames      int   // Frames declared by the header
+	FrameOffset int64 // Offset of the frame cut in the file
+	Size        int64 // Size of the data, where it ends
+	Err         error // Read error
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("file truncated at offset %d: frame %d of %d starting at offset %d is incomplete: %v", e.Size, e.Frame, e.Frames, e.FrameOffset, e.Err)
+}
+
+func (e *TruncatedError) Unwrap() error {
+	return e.Err
+}
+
+// isShortRead checks if an error comes from data ending too soon
+func isShortRead(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrTruncatedChunk)
+}
@@ -0,0 +1,156 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+
+	"github.com/retroblast-engine/asevre"
+)
+
+// pngSignature starts every PNG file
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// EncodeAPNG writes every frame of the file as an animated PNG.
+func EncodeAPNG(w io.Writer, file asevre.ASEFile) error {
+	return EncodeTagAPNG(w, file, wholeFile(file))
+}
+
+// EncodeTagAPNG writes the frames of a tag as an animated PNG (full RGBA
+// colors), following the tag direction and repeat count.
+func EncodeTagAPNG(w io.Writer, file asevre.ASEFile, tag asevre.ASETag) error {
+	images := frameImages(file)
+	frames, forever := playback(tag)
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to encode")
+	}
+	if err := tag.Validate(len(images)); err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		if frame < 0 || frame >= len(images) {
+			return fmt.Errorf("frame %d out of range", frame)
+		}
+	}
+
+	bounds := images[frames[0]].Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+
+	// Width, height, bit depth 8, RGBA, deflate, adaptive filters, no interlace
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(height))
+	ihdr[8], ihdr[9] = 8, 6
+	if err := writePNGChunk(w, "IHDR", ihdr); err != nil {
+		return err
+	}
+
+	// Number of frames and plays (0 = forever)
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(frames)))
+	if !forever {
+		binary.BigEndian.PutUint32(actl[4:], 1)
+	}
+	if err := writePNGChunk(w, "acTL", actl); err != nil {
+		return err
+	}
+
+	sequence := uint32(0)
+	for i, frame := range frames {
+		// Frame control: sequence, size, offset, delay (ms / 1000), dispose none, blend source
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], sequence)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(width))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(height))
+		binary.BigEndian.PutUint16(fctl[20:], uint16(min(frameDuration(file, frame).Milliseconds(), 0xFFFF)))
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		if err := writePNGChunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+		sequence++
+
+		data, err := pngImageData(images[frame], width, height)
+		if err != nil {
+			return err
+		}
+
+		// The first frame is the default image, the others are frame data chunks
+		if i == 0 {
+			err = writePNGChunk(w, "IDAT", data)
+		} else {
+			fdat := make([]byte, 4, 4+len(data))
+			binary.BigEndian.PutUint32(fdat, sequence)
+			err = writePNGChunk(w, "fdAT", append(fdat, data...))
+			sequence++
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return writePNGChunk(w, "IEND", nil)
+}
+
+// SaveAPNG writes every frame of the file to an animated PNG file.
+func SaveAPNG(filePath string, file asevre.ASEFile) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err := EncodeAPNG(f, file); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// pngImageData returns the compressed rows of non-premultiplied RGBA pixels,
+// every row starting with filter type 0 (none)
+func pngImageData(img image.Image, width, height int) ([]byte, error) {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	for y := 0; y < height; y++ {
+		if _, err := zw.Write([]byte{0}); err != nil {
+			return nil, err
+		}
+		if _, err := zw.Write(nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+width*4]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writePNGChunk writes the length, type, data and CRC of a PNG chunk
+func writePNGChunk(w io.Writer, chunkType string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], chunkType)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, crc.Sum32())
+}
@@ -0,0 +1,149 @@
+package export
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+
+	"github.com/retroblast-engine/asevre"
+)
+
+// EncodeGIF writes every frame of the file as an animated GIF.
+func EncodeGIF(w io.Writer, file asevre.ASEFile) error {
+	return EncodeTagGIF(w, file, wholeFile(file))
+}
+
+// EncodeTagGIF writes the frames of a tag as an animated GIF, following the
+// tag direction and repeat count.
+func EncodeTagGIF(w io.Writer, file asevre.ASEFile, tag asevre.ASETag) error {
+	images := frameImages(file)
+	frames, forever := playback(tag)
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to encode")
+	}
+	if err := tag.Validate(len(images)); err != nil {
+		return err
+	}
+
+	pal := gifPalette(file, images, frames)
+	anim := &gif.GIF{LoopCount: -1}
+	if forever {
+		anim.LoopCount = 0
+	}
+
+	for _, frame := range frames {
+		if frame < 0 || frame >= len(images) {
+			return fmt.Errorf("frame %d out of range", frame)
+		}
+		img := images[frame]
+		paletted := image.NewPaletted(img.Bounds(), pal)
+		exact := hasColors(pal, img)
+		if !exact {
+			draw.FloydSteinberg.Draw(paletted, img.Bounds(), img, img.Bounds().Min)
+		}
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := opaque(img.At(x, y))
+				// Fully transparent pixels use the transparent entry
+				if c.A == 0 {
+					paletted.SetColorIndex(x, y, 0)
+				} else if exact {
+					paletted.SetColorIndex(x, y, uint8(pal.Index(c)))
+				}
+			}
+		}
+
+		anim.Image = append(anim.Image, paletted)
+		// GIF delays are in hundredths of a second
+		anim.Delay = append(anim.Delay, max(int((frameDuration(file, frame).Milliseconds()+5)/10), 1))
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+
+	return gif.EncodeAll(w, anim)
+}
+
+// SaveGIF writes every frame of the file to an animated GIF file.
+func SaveGIF(filePath string, file asevre.ASEFile) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err := EncodeGIF(f, file); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// gifPalette returns a palette with the transparent color first: the colors
+// used by the frames when they fit, the sprite palette or a web-safe one otherwise
+func gifPalette(file asevre.ASEFile, images []image.Image, frames []int) color.Palette {
+	pal := color.Palette{color.Transparent}
+	seen := map[color.RGBA]bool{}
+	for _, frame := range frames {
+		if frame < 0 || frame >= len(images) {
+			continue
+		}
+		img := images[frame]
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := opaque(img.At(x, y))
+				if c.A == 0 || seen[c] {
+					continue
+				}
+				seen[c] = true
+				pal = append(pal, c)
+				if len(pal) > 256 {
+					return fallbackPalette(file)
+				}
+			}
+		}
+	}
+	return pal
+}
+
+// fallbackPalette returns the sprite palette, or a web-safe palette for
+// sprites with too many colors, with the transparent color first
+func fallbackPalette(file asevre.ASEFile) color.Palette {
+	pal := color.Palette{color.Transparent}
+	if len(file.Palette) > 0 && len(file.Palette) < 256 {
+		for _, c := range file.Palette {
+			pal = append(pal, opaque(c))
+		}
+		return pal
+	}
+	return append(pal, palette.WebSafe...)
+}
+
+// opaque drops the alpha of visible colors, GIF only has a transparent index
+func opaque(c color.Color) color.RGBA {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 0 {
+		return color.RGBA{}
+	}
+	return color.RGBA{R: n.R, G: n.G, B: n.B, A: 255}
+}
+
+// hasColors checks if every visible color of the image is in the palette
+func hasColors(pal color.Palette, img image.Image) bool {
+	exact := map[color.RGBA]bool{}
+	for _, c := range pal {
+		exact[opaque(c)] = true
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if c := opaque(img.At(x, y)); c.A != 0 && !exact[c] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,300 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/retroblast-engine/asevre"
+)
+
+// ldtkVersion is the LDtk JSON version written
+const ldtkVersion = "1.5.3"
+
+// LDtk tile flip bits
+const (
+	ldtkFlipX = 1
+	ldtkFlipY = 2
+)
+
+// LDtkOptions configures the LDtk project.
+type LDtkOptions struct {
+	Name        string // Tileset identifier, "Tileset" by default
+	ImageSource string // Tileset image referenced by the project, "tileset.png" by default
+	Columns     int    // Columns of the tileset image, 0 for a square-ish image
+	Level       string // Level identifier, "Level_0" by default
+	Frame       int    // Frame whose tilemap cels become the level layers
+}
+
+// withDefaults fills the unset options
+func (o LDtkOptions) withDefaults(file asevre.ASEFile) LDtkOptions {
+	if o.Name == "" {
+		o.Name = "Tileset"
+	}
+	if o.ImageSource == "" {
+		o.ImageSource = "tileset.png"
+	}
+	if o.Columns <= 0 {
+		o.Columns = max(int(math.Ceil(math.Sqrt(float64(len(file.Tileset.Tiles))))), 1)
+	}
+	if o.Level == "" {
+		o.Level = "Level_0"
+	}
+	return o
+}
+
+type ldtkProject struct {
+	Header          ldtkHeader  `json:"__header__"`
+	IID             string      `json:"iid"`
+	JSONVersion     string      `json:"jsonVersion"`
+	DefaultGridSize int         `json:"defaultGridSize"`
+	WorldLayout     string      `json:"worldLayout"`
+	Defs            ldtkDefs    `json:"defs"`
+	Levels          []ldtkLevel `json:"levels"`
+}
+
+type ldtkHeader struct {
+	FileType   string `json:"fileType"`
+	App        string `json:"app"`
+	Doc        string `json:"doc"`
+	Schema     string `json:"schema"`
+	AppVersion string `json:"appVersion"`
+	URL        string `json:"url"`
+}
+
+type ldtkDefs struct {
+	Layers        []ldtkLayerDef   `json:"layers"`
+	Entities      []any            `json:"entities"`
+	Tilesets      []ldtkTilesetDef `json:"tilesets"`
+	Enums         []any            `json:"enums"`
+	ExternalEnums []any            `json:"externalEnums"`
+	LevelFields   []any            `json:"levelFields"`
+}
+
+type ldtkLayerDef struct {
+	Type          string `json:"__type"`
+	Identifier    string `json:"identifier"`
+	LayerType     string `json:"type"`
+	UID           int    `json:"uid"`
+	GridSize      int    `json:"gridSize"`
+	TilesetDefUID int    `json:"tilesetDefUid"`
+}
+
+type ldtkTilesetDef struct {
+	CWid         int    `json:"__cWid"`
+	CHei         int    `json:"__cHei"`
+	Identifier   string `json:"identifier"`
+	UID          int    `json:"uid"`
+	RelPath      string `json:"relPath"`
+	PxWid        int    `json:"pxWid"`
+	PxHei        int    `json:"pxHei"`
+	TileGridSize int    `json:"tileGridSize"`
+	Spacing      int    `json:"spacing"`
+	Padding      int    `json:"padding"`
+}
+
+type ldtkLevel struct {
+	Identifier     string              `json:"identifier"`
+	IID            string              `json:"iid"`
+	UID            int                 `json:"uid"`
+	WorldX         int                 `json:"worldX"`
+	WorldY         int                 `json:"worldY"`
+	PxWid          int                 `json:"pxWid"`
+	PxHei          int                 `json:"pxHei"`
+	LayerInstances []ldtkLayerInstance `json:"layerInstances"`
+}
+
+type ldtkLayerInstance struct {
+	Identifier      string     `json:"__identifier"`
+	Type            string     `json:"__type"`
+	CWid            int        `json:"__cWid"`
+	CHei            int        `json:"__cHei"`
+	GridSize        int        `json:"__gridSize"`
+	Opacity         float64    `json:"__opacity"`
+	PxTotalOffsetX  int        `json:"__pxTotalOffsetX"`
+	PxTotalOffsetY  int        `json:"__pxTotalOffsetY"`
+	TilesetDefUID   int        `json:"__tilesetDefUid"`
+	TilesetRelPath  string     `json:"__tilesetRelPath"`
+	IID             string     `json:"iid"`
+	LevelID         int        `json:"levelId"`
+	LayerDefUID     int        `json:"layerDefUid"`
+	PxOffsetX       int        `json:"pxOffsetX"`
+	PxOffsetY       int        `json:"pxOffsetY"`
+	Visible         bool       `json:"visible"`
+	IntGridCsv      []int      `json:"intGridCsv"`
+	AutoLayerTiles  []ldtkTile `json:"autoLayerTiles"`
+	GridTiles       []ldtkTile `json:"gridTiles"`
+	EntityInstances []any      `json:"entityInstances"`
+}
+
+type ldtkTile struct {
+	Px  [2]int `json:"px"`  // Position in the layer, in pixels
+	Src [2]int `json:"src"` // Position in the tileset image, in pixels
+	F   int    `json:"f"`   // Flip bits
+	T   int    `json:"t"`   // Tile ID
+	A   int    `json:"a"`   // Alpha
+}
+
+// EncodeLDtk writes the tilemap cels of a frame as the layers of a level of
+// an LDtk project, with the tileset image written by SaveLDtk. Tile IDs map to
+// the same tiles; diagonal flips can't be represented in LDtk and are dropped.
+func EncodeLDtk(w io.Writer, file asevre.ASEFile, options LDtkOptions) error {
+	options = options.withDefaults(file)
+	tileWidth, tileHeight := file.Tileset.TileWidth, file.Tileset.TileHeight
+	if tileWidth == 0 || tileWidth != tileHeight {
+		return fmt.Errorf("LDtk needs square tiles: %dx%d", tileWidth, tileHeight)
+	}
+	gridSize := tileWidth
+
+	cels := file.TilemapCels(options.Frame)
+
+	// The level covers the canvas, or every cel when the canvas size is unknown
+	width, height := int(file.Header.Width), int(file.Header.Height)
+	if width == 0 || height == 0 {
+		for _, cel := range cels {
+			width = max(width, cel.X+cel.Tilemap.TilemapColumns*gridSize)
+			height = max(height, cel.Y+cel.Tilemap.TilemapRows*gridSize)
+		}
+	}
+	columns, rows := (width+gridSize-1)/gridSize, (height+gridSize-1)/gridSize
+
+	// Unique IDs: the tileset, the layers, then the level
+	const tilesetUID = 1
+	levelUID := len(cels) + 2
+	iid := 0
+	nextIID := func() string {
+		iid++
+		return fmt.Sprintf("00000000-0000-0000-0000-%012d", iid)
+	}
+
+	tilesetRows := (len(file.Tileset.Tiles) + options.Columns - 1) / options.Columns
+	project := ldtkProject{
+		Header: ldtkHeader{
+			FileType:   "LDtk Project JSON",
+			App:        "LDtk",
+			Doc:        "https://ldtk.io/json",
+			Schema:     "https://ldtk.io/files/JSON_SCHEMA.json",
+			AppVersion: ldtkVersion,
+			URL:        "https://ldtk.io",
+		},
+		IID:             nextIID(),
+		JSONVersion:     ldtkVersion,
+		DefaultGridSize: gridSize,
+		WorldLayout:     "Free",
+		Defs: ldtkDefs{
+			Entities:      []any{},
+			Enums:         []any{},
+			ExternalEnums: []any{},
+			LevelFields:   []any{},
+			Tilesets: []ldtkTilesetDef{{
+				CWid:         options.Columns,
+				CHei:         tilesetRows,
+				Identifier:   options.Name,
+				UID:          tilesetUID,
+				RelPath:      options.ImageSource,
+				PxWid:        options.Columns * gridSize,
+				PxHei:        tilesetRows * gridSize,
+				TileGridSize: gridSize,
+			}},
+		},
+	}
+	level := ldtkLevel{
+		Identifier: options.Level,
+		IID:        nextIID(),
+		UID:        levelUID,
+		PxWid:      width,
+		PxHei:      height,
+	}
+
+	// LDtk lists the layers top-most first
+	for i := len(cels) - 1; i >= 0; i-- {
+		cel := cels[i]
+		layerUID := i + 2
+
+		name := fmt.Sprintf("Tilemap_%d", cel.Layer)
+		if cel.Layer < len(file.Layers) {
+			name = file.Layers[cel.Layer].Name
+		}
+
+		project.Defs.Layers = append(project.Defs.Layers, ldtkLayerDef{
+			Type:          "Tiles",
+			Identifier:    name,
+			LayerType:     "Tiles",
+			UID:           layerUID,
+			GridSize:      gridSize,
+			TilesetDefUID: tilesetUID,
+		})
+
+		instance := ldtkLayerInstance{
+			Identifier:      name,
+			Type:            "Tiles",
+			CWid:            columns,
+			CHei:            rows,
+			GridSize:        gridSize,
+			Opacity:         1,
+			TilesetDefUID:   tilesetUID,
+			TilesetRelPath:  options.ImageSource,
+			IID:             nextIID(),
+			LevelID:         levelUID,
+			LayerDefUID:     layerUID,
+			Visible:         true,
+			IntGridCsv:      []int{},
+			AutoLayerTiles:  []ldtkTile{},
+			GridTiles:       []ldtkTile{},
+			EntityInstances: []any{},
+		}
+		for y, tiles := range cel.Tilemap.Tiles {
+			for x, tile := range tiles {
+				if tile.ID == 0 {
+					continue
+				}
+				flips := 0
+				if tile.XFlip {
+					flips |= ldtkFlipX
+				}
+				if tile.YFlip {
+					flips |= ldtkFlipY
+				}
+				instance.GridTiles = append(instance.GridTiles, ldtkTile{
+					Px:  [2]int{cel.X + x*gridSize, cel.Y + y*gridSize},
+					Src: [2]int{(tile.ID % options.Columns) * gridSize, (tile.ID / options.Columns) * gridSize},
+					F:   flips,
+					T:   tile.ID,
+					A:   1,
+				})
+			}
+		}
+		level.LayerInstances = append(level.LayerInstances, instance)
+	}
+	project.Levels = []ldtkLevel{level}
+
+	data, err := json.MarshalIndent(project, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// SaveLDtk writes the project to ldtkPath, with the tileset image next to it.
+func SaveLDtk(ldtkPath string, file asevre.ASEFile, options LDtkOptions) error {
+	options = options.withDefaults(file)
+
+	sheet := NewSheet(file.Tileset.Tiles, SheetOptions{Layout: LayoutGrid, Columns: options.Columns})
+	if err := sheet.SavePNG(filepath.Join(filepath.Dir(ldtkPath), options.ImageSource)); err != nil {
+		return err
+	}
+
+	f, err := os.Create(ldtkPath)
+	if err != nil {
+		return err
+	}
+	if err := EncodeLDtk(f, file, options); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
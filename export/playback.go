@@ -0,0 +1,64 @@
+package export
+
+import (
+	"image"
+	"time"
+
+	"github.com/retroblast-engine/asevre"
+)
+
+// defaultDuration is used for frames without a duration
+const defaultDuration = 100 * time.Millisecond
+
+// frameImages returns the composited frames of the file, tilemaps included
+// when the file was parsed
+func frameImages(file asevre.ASEFile) []image.Image {
+	if images := file.Composite(); len(images) > 0 {
+		return images
+	}
+	return file.Images
+}
+
+// wholeFile returns a forward tag with every frame of the file
+func wholeFile(file asevre.ASEFile) asevre.ASETag {
+	return asevre.ASETag{FromFrame: 0, ToFrame: len(frameImages(file)) - 1}
+}
+
+// playback returns the frames (file frame indices) played by the tag in order,
+// following its direction and repeat count, and whether they loop forever.
+func playback(tag asevre.ASETag) (frames []int, forever bool) {
+	total := tag.ToFrame - tag.FromFrame + 1
+	if total <= 0 {
+		return nil, false
+	}
+
+	animation := asevre.Animation{TotalFrames: total, Direction: tag.Direction, Repeat: tag.Repeat}
+	animation.Reset()
+
+	// Infinite animations are written as a single cycle that loops
+	steps := total
+	if tag.Direction == asevre.PingPong || tag.Direction == asevre.PingPongReverse {
+		steps = max(2*total-2, 1)
+	}
+	forever = tag.Repeat == asevre.Infinite
+
+	for {
+		frames = append(frames, tag.FromFrame+animation.CurrentFrame())
+		if forever && len(frames) == steps {
+			break
+		}
+		animation.Advance()
+		if animation.IsFinished() {
+			break
+		}
+	}
+	return frames, forever
+}
+
+// frameDuration returns the duration of a frame of the file
+func frameDuration(file asevre.ASEFile, frame int) time.Duration {
+	if frame < len(file.Durations) && file.Durations[frame] > 0 {
+		return file.Durations[frame]
+	}
+	return defaultDuration
+}
@@ -0,0 +1,178 @@
+// Package export writes the contents of parsed Aseprite files to other
+// formats: sprite sheets, animated images and level editor documents.
+package export
+
+import (
+	"cmp"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"slices"
+
+	"github.com/retroblast-engine/asevre"
+)
+
+// Layout is the way images are placed in a sheet.
+type Layout int
+
+const (
+	LayoutHorizontal Layout = iota // Every image in a single row
+	LayoutGrid                     // Rows of Columns cells as big as the biggest image
+	LayoutPacked                   // Rows of images sorted by height, as narrow as possible
+)
+
+// SheetOptions configures how images are packed into a sheet.
+type SheetOptions struct {
+	Layout   Layout
+	Padding  int // Transparent pixels between the images and around the sheet
+	Columns  int // Columns of LayoutGrid, 0 for a square-ish grid
+	MaxWidth int // Width limit of LayoutPacked, 0 for a square-ish sheet
+}
+
+// Sheet is a single image holding many images.
+type Sheet struct {
+	Image *image.NRGBA
+	Rects []image.Rectangle // Place of every image in the sheet, in the original order
+}
+
+// FrameSheet packs the frames of the file.
+func FrameSheet(file asevre.ASEFile, options SheetOptions) Sheet {
+	return NewSheet(file.Images, options)
+}
+
+// TileSheet packs the tiles of the tileset of the file.
+func TileSheet(file asevre.ASEFile, options SheetOptions) Sheet {
+	return NewSheet(file.Tileset.Tiles, options)
+}
+
+// NewSheet packs the images into a sheet. Nil images get an empty rectangle.
+func NewSheet(images []image.Image, options SheetOptions) Sheet {
+	var rects []image.Rectangle
+	switch options.Layout {
+	case LayoutGrid:
+		rects = gridLayout(images, options)
+	case LayoutPacked:
+		rects = packedLayout(images, options)
+	default:
+		rects = horizontalLayout(images, options)
+	}
+
+	var bounds image.Rectangle
+	for _, r := range rects {
+		bounds = bounds.Union(r)
+	}
+	sheet := Sheet{
+		Image: image.NewNRGBA(image.Rect(0, 0, bounds.Max.X+options.Padding, bounds.Max.Y+options.Padding)),
+		Rects: rects,
+	}
+
+	for i, img := range images {
+		if img != nil {
+			draw.Draw(sheet.Image, rects[i], img, img.Bounds().Min, draw.Src)
+		}
+	}
+	return sheet
+}
+
+// EncodePNG writes the sheet image as PNG.
+func (s Sheet) EncodePNG(w io.Writer) error {
+	return png.Encode(w, s.Image)
+}
+
+// SavePNG writes the sheet image to a PNG file.
+func (s Sheet) SavePNG(filePath string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err := s.EncodePNG(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// size returns the size of an image, zero for nil images
+func size(img image.Image) image.Point {
+	if img == nil {
+		return image.Point{}
+	}
+	return img.Bounds().Size()
+}
+
+// horizontalLayout places the images side by side
+func horizontalLayout(images []image.Image, options SheetOptions) []image.Rectangle {
+	rects := make([]image.Rectangle, len(images))
+	x := options.Padding
+	for i, img := range images {
+		s := size(img)
+		rects[i] = image.Rectangle{Min: image.Pt(x, options.Padding), Max: image.Pt(x+s.X, options.Padding+s.Y)}
+		x += s.X + options.Padding
+	}
+	return rects
+}
+
+// gridLayout places the images in cells as big as the biggest image
+func gridLayout(images []image.Image, options SheetOptions) []image.Rectangle {
+	var cell image.Point
+	for _, img := range images {
+		s := size(img)
+		cell.X, cell.Y = max(cell.X, s.X), max(cell.Y, s.Y)
+	}
+
+	columns := options.Columns
+	if columns <= 0 {
+		columns = max(int(math.Ceil(math.Sqrt(float64(len(images))))), 1)
+	}
+
+	rects := make([]image.Rectangle, len(images))
+	for i, img := range images {
+		at := image.Pt(
+			options.Padding+(i%columns)*(cell.X+options.Padding),
+			options.Padding+(i/columns)*(cell.Y+options.Padding),
+		)
+		rects[i] = image.Rectangle{Min: at, Max: at.Add(size(img))}
+	}
+	return rects
+}
+
+// packedLayout places the images in rows (shelves), tallest first
+func packedLayout(images []image.Image, options SheetOptions) []image.Rectangle {
+	maxWidth := options.MaxWidth
+	if maxWidth <= 0 {
+		area, widest := 0, 0
+		for _, img := range images {
+			s := size(img)
+			area += (s.X + options.Padding) * (s.Y + options.Padding)
+			widest = max(widest, s.X)
+		}
+		maxWidth = max(int(math.Ceil(math.Sqrt(float64(area)))), widest) + 2*options.Padding
+	}
+
+	order := make([]int, len(images))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(size(images[b]).Y, size(images[a]).Y)
+	})
+
+	rects := make([]image.Rectangle, len(images))
+	x, y, rowHeight := options.Padding, options.Padding, 0
+	for _, i := range order {
+		s := size(images[i])
+		// Start a new row when the image doesn't fit
+		if x > options.Padding && x+s.X+options.Padding > maxWidth {
+			x = options.Padding
+			y += rowHeight + options.Padding
+			rowHeight = 0
+		}
+		rects[i] = image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x+s.X, y+s.Y)}
+		x += s.X + options.Padding
+		rowHeight = max(rowHeight, s.Y)
+	}
+	return rects
+}
@@ -0,0 +1,313 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/retroblast-engine/asevre"
+)
+
+// Tiled GID flip flags, the same bits Aseprite uses in tilemaps
+const (
+	tiledFlipX    = 0x80000000
+	tiledFlipY    = 0x40000000
+	tiledFlipDiag = 0x20000000
+)
+
+// TiledOptions configures the Tiled documents.
+type TiledOptions struct {
+	Name          string // Tileset name, "tileset" by default
+	ImageSource   string // Tileset image referenced by the TSX, Name + ".png" by default
+	TilesetSource string // TSX referenced by the TMX, Name + ".tsx" by default
+	Columns       int    // Columns of the tileset image, 0 for a square-ish image
+	Frame         int    // Frame whose tilemap cels become the map layers
+}
+
+// withDefaults fills the unset options
+func (o TiledOptions) withDefaults(file asevre.ASEFile) TiledOptions {
+	if o.Name == "" {
+		o.Name = "tileset"
+	}
+	if o.ImageSource == "" {
+		o.ImageSource = o.Name + ".png"
+	}
+	if o.TilesetSource == "" {
+		o.TilesetSource = o.Name + ".tsx"
+	}
+	if o.Columns <= 0 {
+		o.Columns = max(int(math.Ceil(math.Sqrt(float64(len(file.Tileset.Tiles))))), 1)
+	}
+	return o
+}
+
+type tsxTileset struct {
+	XMLName    xml.Name  `xml:"tileset"`
+	Version    string    `xml:"version,attr"`
+	Name       string    `xml:"name,attr"`
+	TileWidth  int       `xml:"tilewidth,attr"`
+	TileHeight int       `xml:"tileheight,attr"`
+	TileCount  int       `xml:"tilecount,attr"`
+	Columns    int       `xml:"columns,attr"`
+	Image      tsxImage  `xml:"image"`
+	Tiles      []tsxTile `xml:"tile"`
+}
+
+type tsxImage struct {
+	Source string `xml:"source,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+}
+
+type tsxTile struct {
+	ID         int           `xml:"id,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+}
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type tmxMap struct {
+	XMLName      xml.Name      `xml:"map"`
+	Version      string        `xml:"version,attr"`
+	Orientation  string        `xml:"orientation,attr"`
+	RenderOrder  string        `xml:"renderorder,attr"`
+	Width        int           `xml:"width,attr"`
+	Height       int           `xml:"height,attr"`
+	TileWidth    int           `xml:"tilewidth,attr"`
+	TileHeight   int           `xml:"tileheight,attr"`
+	Infinite     int           `xml:"infinite,attr"`
+	NextLayerID  int           `xml:"nextlayerid,attr"`
+	NextObjectID int           `xml:"nextobjectid,attr"`
+	Tileset      tmxTilesetRef `xml:"tileset"`
+	Layers       []tmxLayer    `xml:"layer"`
+}
+
+type tmxTilesetRef struct {
+	FirstGID int    `xml:"firstgid,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+type tmxLayer struct {
+	ID      int     `xml:"id,attr"`
+	Name    string  `xml:"name,attr"`
+	Width   int     `xml:"width,attr"`
+	Height  int     `xml:"height,attr"`
+	OffsetX int     `xml:"offsetx,attr,omitempty"`
+	OffsetY int     `xml:"offsety,attr,omitempty"`
+	Data    tmxData `xml:"data"`
+}
+
+type tmxData struct {
+	Encoding string `xml:"encoding,attr"`
+	CSV      string `xml:",innerxml"` // Digits and commas only, written as is to keep the line breaks
+}
+
+// EncodeTSX writes the tileset of the file as a Tiled tileset. The tile
+// properties come from the tile user data.
+func EncodeTSX(w io.Writer, file asevre.ASEFile, options TiledOptions) error {
+	options = options.withDefaults(file)
+	tileset := file.Tileset
+	if tileset.TileWidth == 0 || tileset.TileHeight == 0 {
+		return fmt.Errorf("invalid tile size: %dx%d", tileset.TileWidth, tileset.TileHeight)
+	}
+
+	rows := (len(tileset.Tiles) + options.Columns - 1) / options.Columns
+	tsx := tsxTileset{
+		Version:    "1.10",
+		Name:       options.Name,
+		TileWidth:  tileset.TileWidth,
+		TileHeight: tileset.TileHeight,
+		TileCount:  len(tileset.Tiles),
+		Columns:    options.Columns,
+		Image: tsxImage{
+			Source: options.ImageSource,
+			Width:  options.Columns * tileset.TileWidth,
+			Height: rows * tileset.TileHeight,
+		},
+	}
+
+	for id, userData := range tileset.TileUserData {
+		properties := userData.TileProperties()
+		if len(properties) == 0 {
+			continue
+		}
+		tile := tsxTile{ID: id}
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			tile.Properties = append(tile.Properties, tmxProperty{Name: name, Value: properties[name]})
+		}
+		tsx.Tiles = append(tsx.Tiles, tile)
+	}
+
+	return writeXML(w, tsx)
+}
+
+// EncodeTMX writes the tilemap cels of a frame as the layers of a Tiled map
+// using the tileset written by EncodeTSX.
+func EncodeTMX(w io.Writer, file asevre.ASEFile, options TiledOptions) error {
+	options = options.withDefaults(file)
+	tileWidth, tileHeight := file.Tileset.TileWidth, file.Tileset.TileHeight
+	if tileWidth == 0 || tileHeight == 0 {
+		return fmt.Errorf("invalid tile size: %dx%d", tileWidth, tileHeight)
+	}
+
+	cels := file.TilemapCels(options.Frame)
+
+	// The map covers the canvas, or every cel when the canvas size is unknown
+	width := (int(file.Header.Width) + tileWidth - 1) / tileWidth
+	height := (int(file.Header.Height) + tileHeight - 1) / tileHeight
+	if width == 0 || height == 0 {
+		for _, cel := range cels {
+			width = max(width, floorDiv(cel.X, tileWidth)+cel.Tilemap.TilemapColumns)
+			height = max(height, floorDiv(cel.Y, tileHeight)+cel.Tilemap.TilemapRows)
+		}
+	}
+
+	tmx := tmxMap{
+		Version:      "1.10",
+		Orientation:  "orthogonal",
+		RenderOrder:  "right-down",
+		Width:        width,
+		Height:       height,
+		TileWidth:    tileWidth,
+		TileHeight:   tileHeight,
+		NextLayerID:  len(cels) + 1,
+		NextObjectID: 1,
+		Tileset:      tmxTilesetRef{FirstGID: 1, Source: options.TilesetSource},
+	}
+
+	for i, cel := range cels {
+		// Cels not aligned to the grid keep the rest as a pixel offset
+		column, row := floorDiv(cel.X, tileWidth), floorDiv(cel.Y, tileHeight)
+		gids := make([]uint32, width*height)
+		for y, tiles := range cel.Tilemap.Tiles {
+			for x, tile := range tiles {
+				mx, my := column+x, row+y
+				if mx < 0 || mx >= width || my < 0 || my >= height {
+					continue
+				}
+				gids[my*width+mx] = tiledGID(tile)
+			}
+		}
+
+		name := fmt.Sprintf("Tilemap %d", cel.Layer)
+		if cel.Layer < len(file.Layers) {
+			name = file.Layers[cel.Layer].Name
+		}
+		tmx.Layers = append(tmx.Layers, tmxLayer{
+			ID:      i + 1,
+			Name:    name,
+			Width:   width,
+			Height:  height,
+			OffsetX: cel.X - column*tileWidth,
+			OffsetY: cel.Y - row*tileHeight,
+			Data:    tmxData{Encoding: "csv", CSV: csvRows(gids, width)},
+		})
+	}
+
+	return writeXML(w, tmx)
+}
+
+// SaveTiled writes the map to tmxPath, with the tileset (TSX) and its image
+// (PNG) next to it.
+func SaveTiled(tmxPath string, file asevre.ASEFile, options TiledOptions) error {
+	options = options.withDefaults(file)
+	dir := filepath.Dir(tmxPath)
+
+	sheet := NewSheet(file.Tileset.Tiles, SheetOptions{Layout: LayoutGrid, Columns: options.Columns})
+	if err := sheet.SavePNG(filepath.Join(dir, options.ImageSource)); err != nil {
+		return err
+	}
+
+	for _, doc := range []struct {
+		path   string
+		encode func(io.Writer, asevre.ASEFile, TiledOptions) error
+	}{
+		{filepath.Join(dir, options.TilesetSource), EncodeTSX},
+		{tmxPath, EncodeTMX},
+	} {
+		f, err := os.Create(doc.path)
+		if err != nil {
+			return err
+		}
+		if err := doc.encode(f, file, options); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tiledGID converts a tile to a Tiled GID: tile 0 (empty) stays 0, other
+// tiles are shifted by the first GID of the tileset
+func tiledGID(tile asevre.Tile) uint32 {
+	if tile.ID == 0 {
+		return 0
+	}
+	gid := uint32(tile.ID) + 1
+	if tile.XFlip {
+		gid |= tiledFlipX
+	}
+	if tile.YFlip {
+		gid |= tiledFlipY
+	}
+	if tile.DiagonalFlip {
+		gid |= tiledFlipDiag
+	}
+	return gid
+}
+
+// csvRows writes the GIDs as comma separated rows
+func csvRows(gids []uint32, width int) string {
+	var sb strings.Builder
+	sb.WriteString("\n")
+	for i, gid := range gids {
+		sb.WriteString(strconv.FormatUint(uint64(gid), 10))
+		if i < len(gids)-1 {
+			sb.WriteString(",")
+		}
+		if (i+1)%width == 0 {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// writeXML writes an indented XML document
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", " ")
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
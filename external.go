@@ -0,0 +1,123 @@
+package asevre
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+// External file types (0x2008 chunk)
+const (
+	ExternalPalette                 BYTE = 0 // External palette
+	ExternalTileset                 BYTE = 1 // External tileset
+	ExternalExtensionProperties     BYTE = 2 // Extension name for properties
+	ExternalExtensionTileManagement BYTE = 3 // Extension name for tile management (can exist one per file)
+)
+
+// ExternalFile is an entry of the external files chunk: a file (palette,
+// tileset) or an extension the sprite refers to by ID.
+type ExternalFile struct {
+	ID   uint32 // Entry ID, used by tilesets and user data properties to refer to the entry
+	Type BYTE   // ExternalPalette, ExternalTileset, ...
+	Name string // External file name, or extension ID for extensions
+}
+
+// Chunk0x2008 represents the external files chunk
+type Chunk0x2008 struct {
+	NumberOfEntries DWORD          // Number of entries (4 bytes)
+	Reserved        [8]BYTE        // Reserved (set to zero) (8 bytes)
+	Entries         []ExternalFile // Entries
+}
+
+// parseChunk0x2008 parses the external files chunk
+func parseChunk0x2008(data []byte) (*Chunk0x2008, error) {
+	r := bytes.NewReader(data)
+
+	chunk := &Chunk0x2008{}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.NumberOfEntries); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Reserved); err != nil {
+		return nil, err
+	}
+
+	for i := DWORD(0); i < chunk.NumberOfEntries; i++ {
+		var id DWORD
+		var entryType BYTE
+		var reserved [7]BYTE
+		if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &entryType); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &reserved); err != nil {
+			return nil, err
+		}
+		name, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		chunk.Entries = append(chunk.Entries, ExternalFile{ID: id, Type: entryType, Name: string(name.Chars)})
+	}
+
+	return chunk, nil
+}
+
+// ExternalResolver loads an external file referenced by a sprite.
+type ExternalResolver func(file ExternalFile) (ASEFile, error)
+
+// externalFile returns the external files chunk entry with the given ID
+func (f *ASEFile) externalFile(id uint32) (ExternalFile, bool) {
+	for _, file := range f.ExternalFiles {
+		if file.ID == id {
+			return file, true
+		}
+	}
+	return ExternalFile{}, false
+}
+
+// defaultResolver parses external files from the same file system, relative to
+// the directory of the sprite. Files already being parsed are rejected to stop
+// circular references.
+//
+// The sprite's frame and layer selection, trimming and scaling aren't applied
+// to the external files: the tiles are taken whole and the sprite scales them
+// with the rest. Only the options bounding and reporting the parsing are kept.
+func defaultResolver(assets fs.FS, f string, options ParseOptions) ExternalResolver {
+	return func(file ExternalFile) (ASEFile, error) {
+		if assets == nil {
+			return ASEFile{}, fmt.Errorf("no file system to read %s from", file.Name)
+		}
+		name := path.Join(path.Dir(f), filepath.ToSlash(file.Name))
+		parents := append(slices.Clone(options.parents), f)
+		if slices.Contains(parents, name) {
+			return ASEFile{}, fmt.Errorf("circular reference to %s", name)
+		}
+		// Only the sprite itself reports its progress
+		opts := []ParseOption{WithLimits(options.Limits), WithLogger(options.Logger), WithWorkers(options.Workers), withParents(parents)}
+		if options.Strict {
+			opts = append(opts, WithStrict())
+		}
+		if options.ColorManagement {
+			opts = append(opts, WithColorManagement())
+		}
+		return parseAseprite(assets, name, opts...)
+	}
+}
+
+// resolveTileset loads a tileset stored in an external file
+func resolveTileset(resolve ExternalResolver, file ExternalFile, tilesetID int) (ASETileset, error) {
+	external, err := resolve(file)
+	if err != nil {
+		return ASETileset{}, err
+	}
+	if external.Tileset.ID != tilesetID || external.Tileset.Tiles == nil {
+		return ASETileset{}, fmt.Errorf("tileset %d not found in %s", tilesetID, file.Name)
+	}
+	return external.Tileset, nil
+}
@@ -0,0 +1,56 @@
+package asevre
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Feature is a feature of the .aseprite format that asevre may not be able to fully honor.
+type Feature string
+
+const (
+	FeatureBlendMode       Feature = "blend mode"       // Layers with a blend mode other than normal
+	FeatureHiddenLayer     Feature = "hidden layer"     // Hidden layers composited with WithHiddenLayers
+	FeatureExternalTileset Feature = "external tileset" // Tilesets stored in another file that could not be loaded
+	FeatureColorProfile    Feature = "color profile"    // ICC profile or fixed gamma, not converted without WithColorManagement
+	FeaturePath            Feature = "path"             // Path chunks, whose layout was never specified
+)
+
+// UnsupportedFeatures lists the features used by the file that asevre cannot
+// fully honor, with a description of every place where they are used.
+// An empty map means the file is rendered as Aseprite shows it.
+func (f *ASEFile) UnsupportedFeatures() map[Feature][]string {
+	features := map[Feature][]string{}
+	for feature, details := range f.unsupported {
+		features[feature] = slices.Clone(details)
+	}
+
+	for _, layer := range f.Layers {
+		if layer.BlendMode != BlendNormal {
+			features[FeatureBlendMode] = append(features[FeatureBlendMode], fmt.Sprintf("layer %q (%s)", layer.Name, layer.BlendMode))
+		}
+		if !layer.IsVisible() && f.showHidden {
+			features[FeatureHiddenLayer] = append(features[FeatureHiddenLayer], fmt.Sprintf("layer %q", layer.Name))
+		}
+	}
+
+	return features
+}
+
+// UnsupportedFeatureList returns the unsupported features in a stable order
+func (f *ASEFile) UnsupportedFeatureList() []Feature {
+	var features []Feature
+	for feature := range f.UnsupportedFeatures() {
+		features = append(features, feature)
+	}
+	slices.Sort(features)
+	return features
+}
+
+// noteUnsupported records an unsupported feature found while parsing
+func (f *ASEFile) noteUnsupported(feature Feature, detail string) {
+	if f.unsupported == nil {
+		f.unsupported = map[Feature][]string{}
+	}
+	f.unsupported[feature] = append(f.unsupported[feature], detail)
+}
@@ -0,0 +1,105 @@
+package asevre
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+)
+
+// IsFlipped checks if the tile is drawn flipped
+func (t Tile) IsFlipped() bool {
+	return t.XFlip || t.YFlip || t.DiagonalFlip
+}
+
+// FlippedImage returns the image of the tile as it appears in the tilemap,
+// with its flips applied. Unflipped tiles return Image itself.
+func (t Tile) FlippedImage() image.Image {
+	if t.Image == nil || !t.IsFlipped() {
+		return t.Image
+	}
+	return FlipImage(t.Image, t.XFlip, t.YFlip, t.DiagonalFlip)
+}
+
+// FlipImage returns a flipped copy of img, with its origin at (0, 0). As in
+// Aseprite (and Tiled), the diagonal flip swaps the x and y axes and is
+// applied first, then the horizontal and the vertical flips.
+func FlipImage(img image.Image, xFlip, yFlip, diagonalFlip bool) image.Image {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if diagonalFlip {
+		width, height = height, width
+	}
+
+	flipped := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Undo the flips to find the source pixel
+			sx, sy := x, y
+			if xFlip {
+				sx = width - 1 - sx
+			}
+			if yFlip {
+				sy = height - 1 - sy
+			}
+			if diagonalFlip {
+				sx, sy = sy, sx
+			}
+			flipped.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return flipped
+}
+
+// flipCache holds the flipped versions of the tile images of a tileset
+type flipCache struct {
+	mu     sync.Mutex
+	images map[flipKey]image.Image
+}
+
+// flipKey is a tile image with a combination of flips
+type flipKey struct {
+	image                      image.Image
+	xFlip, yFlip, diagonalFlip bool
+}
+
+// flipCachesMu guards the creation of the flip caches of the tilesets
+var flipCachesMu sync.Mutex
+
+// FlippedTile returns the image of a tile of the tileset as it appears in the
+// tilemap, like Tile.FlippedImage. Every flipped version of a tile image is
+// made once, on first use, and shared by all the tiles using it.
+func (t *ASETileset) FlippedTile(tile Tile) image.Image {
+	if tile.Image == nil || !tile.IsFlipped() {
+		return tile.Image
+	}
+
+	flipCachesMu.Lock()
+	if t.flips == nil {
+		t.flips = &flipCache{images: map[flipKey]image.Image{}}
+	}
+	cache := t.flips
+	flipCachesMu.Unlock()
+
+	key := flipKey{image: tile.Image, xFlip: tile.XFlip, yFlip: tile.YFlip, diagonalFlip: tile.DiagonalFlip}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	img, exists := cache.images[key]
+	if !exists {
+		img = FlipImage(tile.Image, tile.XFlip, tile.YFlip, tile.DiagonalFlip)
+		cache.images[key] = img
+	}
+	return img
+}
+
+// resetFlips drops the flipped tile images, after the tile images change
+func (t *ASETileset) resetFlips() {
+	flipCachesMu.Lock()
+	t.flips = nil
+	flipCachesMu.Unlock()
+}
+
+// drawTile draws the image of a tile of a tilemap, flipped as the tile
+func drawTile(dst draw.Image, at image.Point, img image.Image, opacity BYTE) {
+	b := img.Bounds()
+	drawWithOpacity(dst, b.Sub(b.Min).Add(at), img, b.Min, opacity)
+}
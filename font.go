@@ -0,0 +1,174 @@
+package asevre
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"unicode/utf8"
+)
+
+// Glyph is a character of a SpriteFont.
+type Glyph struct {
+	Image   image.Image // Pixels of the glyph, bounds at the origin; nil for blank glyphs (space)
+	Offset  image.Point // Top-left corner of the image relative to the pen
+	Advance int         // Pixels the pen moves right after the glyph
+}
+
+// SpriteFont is a bitmap font drawn in Aseprite, see NewSliceFont and
+// NewGridFont. The pen starts at the top-left corner of the first line.
+type SpriteFont struct {
+	Glyphs     map[rune]Glyph
+	LineHeight int // Pixels the pen moves down on a new line
+	Spacing    int // Pixels between glyphs, added to their advance
+}
+
+// PlacedGlyph is a glyph of a text laid out by SpriteFont.Layout.
+type PlacedGlyph struct {
+	Rune  rune
+	Glyph Glyph
+	At    image.Point // Top-left corner of the glyph image, relative to the text origin
+}
+
+// NewSliceFont creates a font from the slices of a frame named after a single
+// character ("A", "é", "?"): the pixels of the slice are the glyph. Slices
+// with a pivot have it placed at the pen, which lines up glyphs going under
+// the baseline; the others have their top-left corner at the pen. The line
+// height is the height of the tallest glyph. Files without a space glyph get
+// one half as wide as the line height.
+func NewSliceFont(file ASEFile, frame int) (*SpriteFont, error) {
+	img, ok := file.FrameAt(frame)
+	if !ok || img == nil {
+		return nil, fmt.Errorf("frame %d not found", frame)
+	}
+
+	font := &SpriteFont{Glyphs: map[rune]Glyph{}, Spacing: 1}
+	for _, slice := range file.Slices {
+		r, size := utf8.DecodeRuneInString(slice.Name)
+		if r == utf8.RuneError || size != len(slice.Name) {
+			continue
+		}
+		key, ok := slice.KeyAt(frame)
+		if !ok || key.Bounds.Empty() {
+			continue
+		}
+		glyph := Glyph{Image: cropGlyph(img, key.Bounds.Sub(file.trimOffset(frame))), Advance: key.Bounds.Dx()}
+		if slice.HasPivot() {
+			glyph.Offset = key.Pivot.Mul(-1)
+		}
+		font.Glyphs[r] = glyph
+		font.LineHeight = max(font.LineHeight, key.Bounds.Dy())
+	}
+	if len(font.Glyphs) == 0 {
+		return nil, fmt.Errorf("no glyph slices in frame %d", frame)
+	}
+	if _, exists := font.Glyphs[' ']; !exists {
+		font.Glyphs[' '] = Glyph{Advance: max(font.LineHeight/2, 1)}
+	}
+	return font, nil
+}
+
+// NewGridFont creates a fixed-width font from the cells of a grid of a frame,
+// like the grid of the file (ASEFile.Grid): the characters of chars are the
+// cells in reading order, left to right and top to bottom. Spaces in chars
+// skip a cell and make the space glyph.
+func NewGridFont(file ASEFile, frame int, grid Grid, chars string) (*SpriteFont, error) {
+	img, ok := file.FrameAt(frame)
+	if !ok || img == nil {
+		return nil, fmt.Errorf("frame %d not found", frame)
+	}
+	if grid.Width <= 0 || grid.Height <= 0 {
+		return nil, fmt.Errorf("invalid grid cells of %dx%d", grid.Width, grid.Height)
+	}
+	// The grid is in canvas coordinates, trimmed frames start at their offset
+	offset := file.trimOffset(frame)
+	grid.X -= offset.X
+	grid.Y -= offset.Y
+	bounds := img.Bounds()
+	first := grid.WorldToGrid(bounds.Min)
+	if grid.CellBounds(first).Min.X < bounds.Min.X {
+		first.X++
+	}
+	if grid.CellBounds(first).Min.Y < bounds.Min.Y {
+		first.Y++
+	}
+	columns := (bounds.Max.X - grid.CellBounds(first).Min.X) / grid.Width
+	if columns <= 0 {
+		return nil, fmt.Errorf("no grid cells in frame %d", frame)
+	}
+
+	font := &SpriteFont{Glyphs: map[rune]Glyph{}, LineHeight: grid.Height}
+	i := 0
+	for _, r := range chars {
+		cell := grid.CellBounds(first.Add(image.Pt(i%columns, i/columns)))
+		i++
+		if !cell.In(bounds) {
+			return nil, fmt.Errorf("character %q out of frame %d", r, frame)
+		}
+		glyph := Glyph{Advance: grid.Width}
+		if r != ' ' {
+			glyph.Image = cropGlyph(img, cell)
+		}
+		font.Glyphs[r] = glyph
+	}
+	return font, nil
+}
+
+// cropGlyph copies the pixels of a glyph
+func cropGlyph(img image.Image, bounds image.Rectangle) *image.NRGBA {
+	glyph := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(glyph, glyph.Rect, img, bounds.Min, draw.Src)
+	return glyph
+}
+
+// Layout places the glyphs of a text, starting new lines at '\n'. Characters
+// without a glyph are left out.
+func (f *SpriteFont) Layout(text string) []PlacedGlyph {
+	var glyphs []PlacedGlyph
+	var pen image.Point
+	for _, r := range text {
+		if r == '\n' {
+			pen = image.Pt(0, pen.Y+f.LineHeight+f.Spacing)
+			continue
+		}
+		glyph, exists := f.Glyphs[r]
+		if !exists {
+			continue
+		}
+		if glyph.Image != nil {
+			glyphs = append(glyphs, PlacedGlyph{Rune: r, Glyph: glyph, At: pen.Add(glyph.Offset)})
+		}
+		pen.X += glyph.Advance + f.Spacing
+	}
+	return glyphs
+}
+
+// Measure returns the size of a text: the width of its longest line and the
+// height of its lines.
+func (f *SpriteFont) Measure(text string) image.Point {
+	var size image.Point
+	width, lines := 0, 1
+	for _, r := range text {
+		if r == '\n' {
+			width = 0
+			lines++
+			continue
+		}
+		if glyph, exists := f.Glyphs[r]; exists {
+			if width > 0 {
+				width += f.Spacing
+			}
+			width += glyph.Advance
+			size.X = max(size.X, width)
+		}
+	}
+	size.Y = lines*f.LineHeight + (lines-1)*f.Spacing
+	return size
+}
+
+// Draw draws a text on dst with the top-left corner of its first line at at.
+func (f *SpriteFont) Draw(dst draw.Image, text string, at image.Point) {
+	for _, placed := range f.Layout(text) {
+		b := placed.Glyph.Image.Bounds()
+		draw.Draw(dst, b.Sub(b.Min).Add(at.Add(placed.At)), placed.Glyph.Image, b.Min, draw.Over)
+	}
+}
@@ -0,0 +1,185 @@
+package asevre
+
+import (
+	"fmt"
+	"image"
+	"slices"
+	"time"
+)
+
+// FrameRange is a range of frames, both ends included.
+type FrameRange struct {
+	From, To int
+}
+
+// selectFrames marks the frames chosen by the Frames and Tags options, nil
+// when every frame is parsed
+func selectFrames(frames []Frame, options ParseOptions) ([]bool, error) {
+	if options.Frames == nil && options.Tags == nil {
+		return nil, nil
+	}
+
+	ranges := slices.Clone(options.Frames)
+	if len(options.Tags) > 0 {
+		var tags []ASETag
+		for _, frame := range frames {
+			for _, chunk := range frame.Chunks {
+				if chunk.ChunkType != 0x2018 {
+					continue
+				}
+				decoded, err := DecodeTags(chunk.ChunkData)
+				if err != nil {
+					return nil, err
+				}
+				tags = append(tags, decoded...)
+			}
+		}
+		for _, name := range options.Tags {
+			i := slices.IndexFunc(tags, func(tag ASETag) bool { return tag.Name == name })
+			if i < 0 {
+				return nil, fmt.Errorf("%w: %s", ErrTagNotFound, name)
+			}
+			if err := tags[i].Validate(len(frames)); err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, FrameRange{From: tags[i].FromFrame, To: tags[i].ToFrame})
+		}
+	}
+
+	selected := make([]bool, len(frames))
+	for _, r := range ranges {
+		if r.From < 0 || r.To >= len(frames) || r.From > r.To {
+			return nil, fmt.Errorf("frames %d to %d out of %d", r.From, r.To, len(frames))
+		}
+		for i := r.From; i <= r.To; i++ {
+			selected[i] = true
+		}
+	}
+	return selected, nil
+}
+
+// neededCelJobs keeps the image cels of the selected frames and the cels
+// they are linked to
+func neededCelJobs(jobs []celJob, links []celLink, selected []bool) []celJob {
+	if selected == nil {
+		return jobs
+	}
+
+	linked := map[[2]int]bool{}
+	for _, link := range links {
+		if selected[link.frame] {
+			linked[[2]int{link.linkedFrame, link.linkedIndex}] = true
+		}
+	}
+	return slices.DeleteFunc(jobs, func(job celJob) bool {
+		return !selected[job.frame] && !linked[[2]int{job.frame, job.index}]
+	})
+}
+
+// keepFrames drops the frames that are not selected. Tags are clipped to their
+// selected frames, tags without any are dropped, and slice keys move to the
+// frames left.
+func (f *ASEFile) keepFrames(selected []bool) {
+	// New index of every frame, the one of the next kept frame for dropped frames
+	newIndex := make([]int, len(selected))
+	kept := 0
+	for i, keep := range selected {
+		newIndex[i] = kept
+		if keep {
+			kept++
+		}
+	}
+
+	var images []image.Image
+	var indices [][]byte
+	var durations []time.Duration
+	var frameCels [][]frameCel
+	var tilemaps []ASETilemap
+	var frameData []ASEFrame
+	for i := range selected {
+		if !selected[i] {
+			continue
+		}
+		if i < len(f.Images) {
+			images = append(images, f.Images[i])
+		}
+		if i < len(f.Indices) {
+			indices = append(indices, f.Indices[i])
+		}
+		if i < len(f.Durations) {
+			durations = append(durations, f.Durations[i])
+		}
+		if i < len(f.FrameData) {
+			frameData = append(frameData, f.FrameData[i])
+		}
+		if i < len(f.frameCels) {
+			frameCels = append(frameCels, f.frameCels[i])
+			for _, c := range f.frameCels[i] {
+				if c.tilemap != nil {
+					tilemaps = append(tilemaps, *c.tilemap)
+				}
+			}
+		}
+	}
+	f.Images, f.Indices, f.Durations, f.frameCels, f.Tilemaps = images, indices, durations, frameCels, tilemaps
+	f.FrameData = frameData
+	f.Header.FrameCount = WORD(kept)
+
+	var tags []ASETag
+	for _, tag := range f.State {
+		first, last := -1, -1
+		for i := tag.FromFrame; i <= tag.ToFrame; i++ {
+			if selected[i] {
+				last = i
+				if first < 0 {
+					first = i
+				}
+			}
+		}
+		if first < 0 {
+			continue
+		}
+		clipped := first != tag.FromFrame || last != tag.ToFrame
+		loopStart := tag.FromFrame + tag.Animation.LoopStart
+		tag.FromFrame, tag.ToFrame = newIndex[first], newIndex[last]
+		if clipped {
+			tag.clipAnimation(newIndex[loopStart])
+		}
+		tags = append(tags, tag)
+	}
+	f.State = tags
+
+	for i := range f.Slices {
+		var keys []SliceKey
+		for _, key := range f.Slices[i].Keys {
+			if key.Frame >= 0 && key.Frame < len(selected) {
+				key.Frame = newIndex[key.Frame]
+			}
+			// A later key starting on the same frame replaces the earlier one
+			if n := len(keys); n > 0 && keys[n-1].Frame == key.Frame {
+				keys[n-1] = key
+				continue
+			}
+			if key.Frame < kept {
+				keys = append(keys, key)
+			}
+		}
+		f.Slices[i].Keys = keys
+	}
+
+	f.refreshStates()
+}
+
+// clipAnimation fits the animation of a tag to its frames once some of them
+// are dropped, loopStart being the new index of its loop start frame
+func (tag *ASETag) clipAnimation(loopStart int) {
+	frames := tag.ToFrame - tag.FromFrame + 1
+	if frames <= 1 {
+		tag.HasAnimations = false
+		tag.Animation = Animation{}
+		return
+	}
+	tag.Animation.TotalFrames = frames
+	tag.Animation.LoopStart = min(max(loopStart-tag.FromFrame, 0), frames-1)
+	tag.Animation.Reset()
+}
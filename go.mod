@@ -2,7 +2,10 @@ module github.com/retroblast-engine/asevre
 
 go 1.22.4
 
-require github.com/hajimehoshi/ebiten/v2 v2.7.8
+require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/hajimehoshi/ebiten/v2 v2.7.8
+)
 
 require (
 	github.com/ebitengine/gomobile v0.0.0-20240518074828-e86332849895 // indirect
@@ -0,0 +1,38 @@
+package asevre
+
+import "image"
+
+// Grid is the grid the artist drew on in Aseprite: cells of Width x Height
+// pixels, with a cell corner at X, Y.
+type Grid struct {
+	X, Y          int
+	Width, Height int
+}
+
+// Grid returns the grid of the sprite, Aseprite's default 16x16 grid when the
+// file has none.
+func (f *ASEFile) Grid() Grid {
+	width, height := f.Header.GetGridSize()
+	return Grid{X: int(f.Header.GridX), Y: int(f.Header.GridY), Width: int(width), Height: int(height)}
+}
+
+// WorldToGrid returns the cell holding a point
+func (g Grid) WorldToGrid(p image.Point) image.Point {
+	return image.Pt(floorDiv(p.X-g.X, g.Width), floorDiv(p.Y-g.Y, g.Height))
+}
+
+// GridToWorld returns the top-left corner of a cell
+func (g Grid) GridToWorld(cell image.Point) image.Point {
+	return image.Pt(g.X+cell.X*g.Width, g.Y+cell.Y*g.Height)
+}
+
+// CellBounds returns the pixels covered by a cell
+func (g Grid) CellBounds(cell image.Point) image.Rectangle {
+	corner := g.GridToWorld(cell)
+	return image.Rectangle{Min: corner, Max: corner.Add(image.Pt(g.Width, g.Height))}
+}
+
+// SnapToGrid moves a point to the top-left corner of its cell
+func (g Grid) SnapToGrid(p image.Point) image.Point {
+	return g.GridToWorld(g.WorldToGrid(p))
+}
@@ -0,0 +1,83 @@
+package asevre
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+// LoadAsepriteHTTP fetches an .aseprite or .ase file with an HTTP GET and
+// parses it, for the builds that can't open files, like WASM games getting
+// their assets from the server. Linked files (external tilesets) are fetched
+// relative to the URL. ctx cancels the requests.
+func LoadAsepriteHTTP(ctx context.Context, rawURL string, opts ...ParseOption) (ASEFile, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ASEFile{}, err
+	}
+	name := path.Base(u.Path)
+	return parseAseprite(httpFS{ctx: ctx, root: u, name: name}, name, opts...)
+}
+
+// httpFS is the file system of the files of a server, relative to a file
+type httpFS struct {
+	ctx  context.Context
+	root *url.URL // URL of the file parsed first, query included
+	name string   // Name of the file parsed first
+}
+
+// Open fetches a file. The body of the response is read as the file.
+func (h httpFS) Open(name string) (fs.File, error) {
+	u := h.root
+	if name != h.name {
+		ref, err := url.Parse(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		u = h.root.ResolveReference(ref)
+	}
+
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err := fmt.Errorf("GET %s: %s", u.Redacted(), resp.Status)
+		if resp.StatusCode == http.StatusNotFound {
+			err = fmt.Errorf("%w: %v", fs.ErrNotExist, err)
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &httpFile{body: resp.Body, name: path.Base(name), size: resp.ContentLength}, nil
+}
+
+// httpFile is a file fetched by httpFS
+type httpFile struct {
+	body io.ReadCloser
+	name string
+	size int64 // Content length, -1 if unknown
+}
+
+func (f *httpFile) Read(p []byte) (int, error) { return f.body.Read(p) }
+func (f *httpFile) Close() error               { return f.body.Close() }
+func (f *httpFile) Stat() (fs.FileInfo, error) { return httpFileInfo{f}, nil }
+
+// httpFileInfo describes a file fetched by httpFS
+type httpFileInfo struct{ file *httpFile }
+
+func (i httpFileInfo) Name() string       { return i.file.name }
+func (i httpFileInfo) Size() int64        { return max(i.file.size, 0) }
+func (i httpFileInfo) Mode() fs.FileMode  { return 0o444 }
+func (i httpFileInfo) ModTime() time.Time { return time.Time{} }
+func (i httpFileInfo) IsDir() bool        { return false }
+func (i httpFileInfo) Sys() any           { return nil }
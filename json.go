@@ -0,0 +1,519 @@
+package asevre
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"slices"
+	"time"
+)
+
+// JSONImages tells how EncodeJSON writes the images of a file.
+type JSONImages int
+
+const (
+	JSONImagesBase64 JSONImages = iota // PNG images, base64 encoded, in the "images" list
+	JSONImagesNone                     // Image IDs only, for diffs and tools reading the pixels elsewhere
+)
+
+// jsonFile is the JSON form of an ASEFile. The frames, the tiles and the tags
+// refer to the images by their index in Images, every image is stored once.
+type jsonFile struct {
+	Header        Header         `json:"header"`
+	Palette       []string       `json:"palette,omitempty"`
+	PaletteNames  []string       `json:"palette_names,omitempty"`
+	Layers        []jsonLayer    `json:"layers,omitempty"`
+	Frames        []jsonFrame    `json:"frames"`
+	Tags          []ASETag       `json:"tags,omitempty"`
+	Slices        []ASESlice     `json:"slices,omitempty"`
+	ExternalFiles []ExternalFile `json:"external_files,omitempty"`
+	Tileset       *jsonTileset   `json:"tileset,omitempty"`
+	Tilemaps      []ASETilemap   `json:"tilemaps,omitempty"`
+	UserData      *UserData      `json:"user_data,omitempty"`
+	Images        []string       `json:"images,omitempty"`
+}
+
+type jsonLayer struct {
+	Name         string    `json:"name"`
+	Type         WORD      `json:"type"`
+	Flags        WORD      `json:"flags"`
+	ChildLevel   int       `json:"child_level"`
+	BlendMode    BlendMode `json:"blend_mode"`
+	Opacity      BYTE      `json:"opacity"`
+	TilesetIndex int       `json:"tileset_index"`
+	UserData     *UserData `json:"user_data,omitempty"`
+}
+
+type jsonFrame struct {
+	Image      *int    `json:"image,omitempty"` // Index in jsonFile.Images
+	Duration   int64   `json:"duration_ms"`
+	TrimOffset *[2]int `json:"trim_offset,omitempty"` // Position of the trimmed frame in the canvas
+	Indices    []byte  `json:"indices,omitempty"`     // Palette indices of the pixels of indexed sprites
+}
+
+type jsonTileset struct {
+	ID                int          `json:"id"`
+	Name              string       `json:"name,omitempty"`
+	BaseIndex         int          `json:"base_index,omitempty"`
+	TileWidth         int          `json:"tile_width"`
+	TileHeight        int          `json:"tile_height"`
+	Flags             TilesetFlags `json:"flags"`
+	ExternalFileID    uint32       `json:"external_file_id,omitempty"`
+	ExternalTilesetID int          `json:"external_tileset_id,omitempty"`
+	Tiles             []*int       `json:"tiles"`             // Indices in jsonFile.Images
+	TileIndices       [][]byte     `json:"indices,omitempty"` // Palette indices of the pixels of every tile
+	UserData          *UserData    `json:"user_data,omitempty"`
+	TileUserData      []*UserData  `json:"tile_user_data,omitempty"`
+}
+
+type jsonTag struct {
+	Name      string                 `json:"name"`
+	From      int                    `json:"from"`
+	To        int                    `json:"to"`
+	Direction LoopAnimationDirection `json:"direction"`
+	Repeat    RepeatTimes            `json:"repeat"`
+	LoopStart int                    `json:"loop_start,omitempty"`
+	Color     string                 `json:"color,omitempty"`
+	Extra     BYTE                   `json:"extra,omitempty"`
+	Durations []int64                `json:"durations_ms,omitempty"`
+	UserData  *UserData              `json:"user_data,omitempty"`
+}
+
+type jsonTilemap struct {
+	Rows    int          `json:"rows"`
+	Columns int          `json:"columns"`
+	Tiles   [][]jsonTile `json:"tiles"`
+}
+
+type jsonTile struct {
+	ID           int  `json:"id"`
+	XFlip        bool `json:"x_flip,omitempty"`
+	YFlip        bool `json:"y_flip,omitempty"`
+	DiagonalFlip bool `json:"diagonal_flip,omitempty"`
+}
+
+type jsonSlice struct {
+	Name     string         `json:"name"`
+	Flags    DWORD          `json:"flags"`
+	Keys     []jsonSliceKey `json:"keys"`
+	UserData *UserData      `json:"user_data,omitempty"`
+}
+
+type jsonSliceKey struct {
+	Frame  int       `json:"frame"`
+	Bounds jsonRect  `json:"bounds"`
+	Center *jsonRect `json:"center,omitempty"`
+	Pivot  *[2]int   `json:"pivot,omitempty"`
+}
+
+// jsonRect is a rectangle as x, y, width and height
+type jsonRect [4]int
+
+type jsonUserData struct {
+	Text       string                    `json:"text,omitempty"`
+	Color      string                    `json:"color,omitempty"`
+	Properties map[string]any            `json:"properties,omitempty"`
+	Extensions map[uint32]map[string]any `json:"extensions,omitempty"`
+}
+
+// EncodeJSON writes the parsed model of a file as JSON: the header, the
+// palette with the color names, the layers, the frames with their durations,
+// trim offsets and palette indices, the tags, the slices, the external files,
+// the tileset, the tilemaps and the user data, images written as images says.
+// Cels, masks and raw chunks are left out; SaveCache keeps everything.
+func EncodeJSON(w io.Writer, file ASEFile, images JSONImages) error {
+	data, err := newJSONFile(file, images)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(data)
+}
+
+// DecodeJSON reads a file written by EncodeJSON (or json.Marshal). Images
+// written as IDs only are nil. User properties come back as JSON values
+// (float64, string, []any, map[string]any).
+func DecodeJSON(r io.Reader) (ASEFile, error) {
+	var file ASEFile
+	err := json.NewDecoder(r).Decode(&file)
+	return file, err
+}
+
+// MarshalJSON writes the file as EncodeJSON does with JSONImagesBase64.
+func (f ASEFile) MarshalJSON() ([]byte, error) {
+	data, err := newJSONFile(f, JSONImagesBase64)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON reads a file written by MarshalJSON or EncodeJSON, see DecodeJSON.
+func (f *ASEFile) UnmarshalJSON(data []byte) error {
+	var decoded jsonFile
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	images := make([]image.Image, len(decoded.Images))
+	for i, encoded := range decoded.Images {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("image %d: %w", i, err)
+		}
+		if images[i], err = png.Decode(bytes.NewReader(raw)); err != nil {
+			return fmt.Errorf("image %d: %w", i, err)
+		}
+	}
+	imageAt := func(id *int) image.Image {
+		if id == nil || *id < 0 || *id >= len(images) {
+			return nil
+		}
+		return images[*id]
+	}
+
+	file := ASEFile{Header: decoded.Header, Slices: decoded.Slices, ExternalFiles: decoded.ExternalFiles, UserData: decoded.UserData}
+	file.paletteNames = decoded.PaletteNames
+	for _, hex := range decoded.Palette {
+		c, err := parseHexColor(hex)
+		if err != nil {
+			return err
+		}
+		file.Palette = append(file.Palette, c)
+	}
+	for i, layer := range decoded.Layers {
+		file.Layers = append(file.Layers, ASELayer{
+			Index:        i,
+			Name:         layer.Name,
+			Type:         layer.Type,
+			Flags:        layer.Flags,
+			ChildLevel:   layer.ChildLevel,
+			BlendMode:    layer.BlendMode,
+			Opacity:      layer.Opacity,
+			TilesetIndex: layer.TilesetIndex,
+			UserData:     layer.UserData,
+		})
+	}
+	for i, frame := range decoded.Frames {
+		file.Images = append(file.Images, imageAt(frame.Image))
+		file.Durations = append(file.Durations, time.Duration(frame.Duration)*time.Millisecond)
+		// Trimmed and indexed files have an entry for every frame
+		if frame.TrimOffset != nil {
+			if file.TrimOffsets == nil {
+				file.TrimOffsets = make([]image.Point, len(decoded.Frames))
+			}
+			file.TrimOffsets[i] = image.Pt(frame.TrimOffset[0], frame.TrimOffset[1])
+		}
+		if frame.Indices != nil {
+			if file.Indices == nil {
+				file.Indices = make([][]byte, len(decoded.Frames))
+			}
+			file.Indices[i] = frame.Indices
+		}
+	}
+	if decoded.Tileset != nil {
+		file.Tileset = ASETileset{
+			ID:                decoded.Tileset.ID,
+			Name:              decoded.Tileset.Name,
+			BaseIndex:         decoded.Tileset.BaseIndex,
+			TileWidth:         decoded.Tileset.TileWidth,
+			TileHeight:        decoded.Tileset.TileHeight,
+			Flags:             decoded.Tileset.Flags,
+			ExternalFileID:    decoded.Tileset.ExternalFileID,
+			ExternalTilesetID: decoded.Tileset.ExternalTilesetID,
+			UserData:          decoded.Tileset.UserData,
+			TileUserData:      decoded.Tileset.TileUserData,
+			indices:           decoded.Tileset.TileIndices,
+		}
+		for _, id := range decoded.Tileset.Tiles {
+			file.Tileset.Tiles = append(file.Tileset.Tiles, imageAt(id))
+		}
+	}
+	for _, tilemap := range decoded.Tilemaps {
+		file.Tileset.setTileImages(&tilemap)
+		for _, row := range tilemap.Tiles {
+			for col := range row {
+				row[col].Width, row[col].Height = file.Tileset.TileWidth, file.Tileset.TileHeight
+				row[col].TilesetID = file.Tileset.ID
+				row[col].Properties = file.Tileset.tileProperties(row[col].ID)
+			}
+		}
+		file.Tilemaps = append(file.Tilemaps, tilemap)
+	}
+	file.State = decoded.Tags
+	file.refreshStates()
+	file.addDefaultTag()
+
+	*f = file
+	return nil
+}
+
+// newJSONFile converts a file to its JSON form
+func newJSONFile(f ASEFile, mode JSONImages) (*jsonFile, error) {
+	data := &jsonFile{Header: f.Header, Slices: f.Slices, ExternalFiles: f.ExternalFiles, Tilemaps: f.Tilemaps, UserData: f.UserData}
+	// The default tag is made up again when reading
+	for _, tag := range f.State {
+		if !tag.synthesized {
+			data.Tags = append(data.Tags, tag)
+		}
+	}
+
+	// Every image gets an ID the first time it is seen
+	ids := map[image.Image]int{}
+	var imageErr error
+	imageID := func(img image.Image) *int {
+		if img == nil {
+			return nil
+		}
+		if id, exists := ids[img]; exists {
+			return &id
+		}
+		id := len(ids)
+		ids[img] = id
+		if mode == JSONImagesBase64 {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil && imageErr == nil {
+				imageErr = fmt.Errorf("image %d: %w", id, err)
+			}
+			data.Images = append(data.Images, base64.StdEncoding.EncodeToString(buf.Bytes()))
+		}
+		return &id
+	}
+
+	for _, c := range f.Palette {
+		data.Palette = append(data.Palette, hexColor(c))
+	}
+	if slices.ContainsFunc(f.paletteNames, func(name string) bool { return name != "" }) {
+		data.PaletteNames = f.paletteNames
+	}
+	for _, layer := range f.Layers {
+		data.Layers = append(data.Layers, jsonLayer{
+			Name:         layer.Name,
+			Type:         layer.Type,
+			Flags:        layer.Flags,
+			ChildLevel:   layer.ChildLevel,
+			BlendMode:    layer.BlendMode,
+			Opacity:      layer.Opacity,
+			TilesetIndex: layer.TilesetIndex,
+			UserData:     layer.UserData,
+		})
+	}
+	data.Frames = make([]jsonFrame, f.frameCount())
+	for i := range data.Frames {
+		if i < len(f.Images) {
+			data.Frames[i].Image = imageID(f.Images[i])
+		}
+		if i < len(f.Durations) {
+			data.Frames[i].Duration = f.Durations[i].Milliseconds()
+		}
+		if i < len(f.TrimOffsets) {
+			data.Frames[i].TrimOffset = &[2]int{f.TrimOffsets[i].X, f.TrimOffsets[i].Y}
+		}
+		if i < len(f.Indices) {
+			data.Frames[i].Indices = f.Indices[i]
+		}
+	}
+	if len(f.Tileset.Tiles) > 0 {
+		data.Tileset = &jsonTileset{
+			ID:                f.Tileset.ID,
+			Name:              f.Tileset.Name,
+			BaseIndex:         f.Tileset.BaseIndex,
+			TileWidth:         f.Tileset.TileWidth,
+			TileHeight:        f.Tileset.TileHeight,
+			Flags:             f.Tileset.Flags,
+			ExternalFileID:    f.Tileset.ExternalFileID,
+			ExternalTilesetID: f.Tileset.ExternalTilesetID,
+			TileIndices:       f.Tileset.indices,
+			UserData:          f.Tileset.UserData,
+			TileUserData:      f.Tileset.TileUserData,
+		}
+		for _, tile := range f.Tileset.Tiles {
+			data.Tileset.Tiles = append(data.Tileset.Tiles, imageID(tile))
+		}
+	}
+	return data, imageErr
+}
+
+// MarshalJSON writes the tag without its frames and tilemaps, which belong
+// to the file.
+func (t ASETag) MarshalJSON() ([]byte, error) {
+	tag := jsonTag{
+		Name:      t.Name,
+		From:      t.FromFrame,
+		To:        t.ToFrame,
+		Direction: t.Direction,
+		Repeat:    t.Repeat,
+		LoopStart: t.Animation.LoopStart,
+		Extra:     t.Extra,
+		UserData:  t.UserData,
+	}
+	if t.Color != nil {
+		tag.Color = hexColor(t.Color)
+	}
+	for _, d := range t.Durations {
+		tag.Durations = append(tag.Durations, d.Milliseconds())
+	}
+	return json.Marshal(tag)
+}
+
+// UnmarshalJSON reads a tag written by MarshalJSON, with its animation ready
+// to play. Its frames are set when it is read with its file.
+func (t *ASETag) UnmarshalJSON(data []byte) error {
+	var tag jsonTag
+	if err := json.Unmarshal(data, &tag); err != nil {
+		return err
+	}
+	*t = ASETag{
+		Name:      tag.Name,
+		FromFrame: tag.From,
+		ToFrame:   tag.To,
+		Direction: tag.Direction,
+		Repeat:    tag.Repeat,
+		Extra:     tag.Extra,
+		UserData:  tag.UserData,
+	}
+	if tag.Color != "" {
+		c, err := parseHexColor(tag.Color)
+		if err != nil {
+			return err
+		}
+		t.Color = c
+	}
+	for _, ms := range tag.Durations {
+		t.Durations = append(t.Durations, time.Duration(ms)*time.Millisecond)
+	}
+	if tag.To > tag.From {
+		t.HasAnimations = true
+		t.Animation = Animation{
+			TotalFrames: tag.To - tag.From + 1,
+			Duration:    t.Durations,
+			LoopStart:   tag.LoopStart,
+			Direction:   tag.Direction,
+			Repeat:      tag.Repeat,
+		}
+		t.Animation.Reset()
+	}
+	return nil
+}
+
+// MarshalJSON writes the tile IDs and flips of the tilemap.
+func (t ASETilemap) MarshalJSON() ([]byte, error) {
+	tilemap := jsonTilemap{Rows: t.TilemapRows, Columns: t.TilemapColumns, Tiles: make([][]jsonTile, len(t.Tiles))}
+	for row, tiles := range t.Tiles {
+		tilemap.Tiles[row] = make([]jsonTile, len(tiles))
+		for col, tile := range tiles {
+			tilemap.Tiles[row][col] = jsonTile{ID: tile.ID, XFlip: tile.XFlip, YFlip: tile.YFlip, DiagonalFlip: tile.DiagonalFlip}
+		}
+	}
+	return json.Marshal(tilemap)
+}
+
+// UnmarshalJSON reads a tilemap written by MarshalJSON. The tiles get their
+// images and sizes when the tilemap is read with its file.
+func (t *ASETilemap) UnmarshalJSON(data []byte) error {
+	var tilemap jsonTilemap
+	if err := json.Unmarshal(data, &tilemap); err != nil {
+		return err
+	}
+	*t = ASETilemap{TilemapRows: tilemap.Rows, TilemapColumns: tilemap.Columns, Tiles: make([][]Tile, len(tilemap.Tiles))}
+	for row, tiles := range tilemap.Tiles {
+		t.Tiles[row] = make([]Tile, len(tiles))
+		for col, tile := range tiles {
+			t.Tiles[row][col] = Tile{ID: tile.ID, XFlip: tile.XFlip, YFlip: tile.YFlip, DiagonalFlip: tile.DiagonalFlip}
+		}
+		t.NumberOfTiles += len(tiles)
+	}
+	return nil
+}
+
+// MarshalJSON writes the slice with its rectangles as [x, y, width, height].
+func (s ASESlice) MarshalJSON() ([]byte, error) {
+	slice := jsonSlice{Name: s.Name, Flags: s.Flags, Keys: make([]jsonSliceKey, len(s.Keys)), UserData: s.UserData}
+	for i, key := range s.Keys {
+		slice.Keys[i] = jsonSliceKey{Frame: key.Frame, Bounds: newJSONRect(key.Bounds)}
+		if s.IsNinePatch() {
+			center := newJSONRect(key.Center)
+			slice.Keys[i].Center = &center
+		}
+		if s.HasPivot() {
+			slice.Keys[i].Pivot = &[2]int{key.Pivot.X, key.Pivot.Y}
+		}
+	}
+	return json.Marshal(slice)
+}
+
+// UnmarshalJSON reads a slice written by MarshalJSON.
+func (s *ASESlice) UnmarshalJSON(data []byte) error {
+	var slice jsonSlice
+	if err := json.Unmarshal(data, &slice); err != nil {
+		return err
+	}
+	*s = ASESlice{Name: slice.Name, Flags: slice.Flags, UserData: slice.UserData}
+	for _, k := range slice.Keys {
+		key := SliceKey{Frame: k.Frame, Bounds: k.Bounds.rect()}
+		if k.Center != nil {
+			key.Center = k.Center.rect()
+		}
+		if k.Pivot != nil {
+			key.Pivot = image.Pt(k.Pivot[0], k.Pivot[1])
+		}
+		s.Keys = append(s.Keys, key)
+	}
+	return nil
+}
+
+// newJSONRect converts a rectangle
+func newJSONRect(r image.Rectangle) jsonRect {
+	return jsonRect{r.Min.X, r.Min.Y, r.Dx(), r.Dy()}
+}
+
+// rect converts the rectangle back
+func (r jsonRect) rect() image.Rectangle {
+	return image.Rect(r[0], r[1], r[0]+r[2], r[1]+r[3])
+}
+
+// MarshalJSON writes the user data with its color as "#rrggbbaa".
+func (u UserData) MarshalJSON() ([]byte, error) {
+	userData := jsonUserData{Text: u.Text, Properties: u.Properties, Extensions: u.Extensions}
+	if u.Color != nil {
+		userData.Color = hexColor(u.Color)
+	}
+	return json.Marshal(userData)
+}
+
+// UnmarshalJSON reads user data written by MarshalJSON. The properties come
+// back as JSON values, their Aseprite types are lost.
+func (u *UserData) UnmarshalJSON(data []byte) error {
+	var userData jsonUserData
+	if err := json.Unmarshal(data, &userData); err != nil {
+		return err
+	}
+	*u = UserData{Text: userData.Text, Properties: userData.Properties, Extensions: userData.Extensions}
+	if userData.Color != "" {
+		c, err := parseHexColor(userData.Color)
+		if err != nil {
+			return err
+		}
+		u.Color = c
+	}
+	return nil
+}
+
+// hexColor writes a color as "#rrggbbaa"
+func hexColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+// parseHexColor reads a color written by hexColor
+func parseHexColor(s string) (color.NRGBA, error) {
+	var c color.NRGBA
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A); err != nil || len(s) != 9 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	return c, nil
+}
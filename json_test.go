@@ -0,0 +1,49 @@
+package asevre
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	palette := color.Palette{color.NRGBA{}, color.NRGBA{R: 255, A: 255}, color.NRGBA{B: 255, A: 255}}
+	frames := make([]image.Image, 2)
+	for i := range frames {
+		img := image.NewNRGBA(image.Rect(0, 0, 6, 5))
+		img.Set(2+i, 1, palette[1])
+		img.Set(3+i, 3, palette[2])
+		frames[i] = img
+	}
+	file := ASEFile{
+		Header:       Header{Width: 6, Height: 5, ColorDepth: ColorDepthIndexed},
+		Palette:      palette,
+		Images:       frames,
+		paletteNames: []string{"", "red", "blue"},
+	}
+	parsed := roundTrip(t, file, WithTrim(), WithPaletteIndices())
+
+	data, err := json.Marshal(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ASEFile
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded.TrimOffsets, parsed.TrimOffsets) || len(decoded.TrimOffsets) != len(frames) {
+		t.Errorf("trim offsets %v, want %v", decoded.TrimOffsets, parsed.TrimOffsets)
+	}
+	if !reflect.DeepEqual(decoded.Indices, parsed.Indices) || len(decoded.Indices) != len(frames) {
+		t.Errorf("indices %v, want %v", decoded.Indices, parsed.Indices)
+	}
+	if got := decoded.ColorPalette().Names; !reflect.DeepEqual(got, file.paletteNames) {
+		t.Errorf("palette names %q, want %q", got, file.paletteNames)
+	}
+	for i := range frames {
+		samePixels(t, decoded.Images[i], parsed.Images[i])
+	}
+}
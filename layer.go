@@ -0,0 +1,332 @@
+package asevre
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Layer types (0x2004 chunk)
+const (
+	LayerTypeNormal  WORD = 0 // Normal (image) layer
+	LayerTypeGroup   WORD = 1 // Group layer
+	LayerTypeTilemap WORD = 2 // Tilemap layer
+)
+
+// Layer flags (0x2004 chunk)
+const (
+	LayerFlagVisible          WORD = 1  // Visible
+	LayerFlagEditable         WORD = 2  // Editable
+	LayerFlagLockMovement     WORD = 4  // Lock movement
+	LayerFlagBackground       WORD = 8  // Background
+	LayerFlagPreferLinkedCels WORD = 16 // Prefer linked cels
+	LayerFlagCollapsed        WORD = 32 // The layer group should be displayed collapsed
+	LayerFlagReference        WORD = 64 // The layer is a reference layer
+)
+
+// Header flag: layers have a UUID
+const HeaderFlagLayersHaveUUID DWORD = 4
+
+// BlendMode represents the blend mode of a layer.
+type BlendMode WORD
+
+const (
+	BlendNormal BlendMode = iota
+	BlendMultiply
+	BlendScreen
+	BlendOverlay
+	BlendDarken
+	BlendLighten
+	BlendColorDodge
+	BlendColorBurn
+	BlendHardLight
+	BlendSoftLight
+	BlendDifference
+	BlendExclusion
+	BlendHue
+	BlendSaturation
+	BlendColor
+	BlendLuminosity
+	BlendAddition
+	BlendSubtract
+	BlendDivide
+)
+
+var blendModeNames = map[BlendMode]string{
+	BlendNormal:     "Normal",
+	BlendMultiply:   "Multiply",
+	BlendScreen:     "Screen",
+	BlendOverlay:    "Overlay",
+	BlendDarken:     "Darken",
+	BlendLighten:    "Lighten",
+	BlendColorDodge: "Color Dodge",
+	BlendColorBurn:  "Color Burn",
+	BlendHardLight:  "Hard Light",
+	BlendSoftLight:  "Soft Light",
+	BlendDifference: "Difference",
+	BlendExclusion:  "Exclusion",
+	BlendHue:        "Hue",
+	BlendSaturation: "Saturation",
+	BlendColor:      "Color",
+	BlendLuminosity: "Luminosity",
+	BlendAddition:   "Addition",
+	BlendSubtract:   "Subtract",
+	BlendDivide:     "Divide",
+}
+
+// String returns the name of the blend mode
+func (b BlendMode) String() string {
+	if name, exists := blendModeNames[b]; exists {
+		return name
+	}
+	return "Unknown blend mode"
+}
+
+// Chunk0x2004 represents a layer chunk. Layers are numbered in the order the chunks appear.
+type Chunk0x2004 struct {
+	Flags         WORD      // Layer flags (2 bytes)
+	LayerType     WORD      // Layer type (2 bytes) // 4 bytes so far
+	ChildLevel    WORD      // Layer child level (2 bytes) // 6 bytes so far
+	DefaultWidth  WORD      // Default layer width in pixels (ignored) (2 bytes) // 8 bytes so far
+	DefaultHeight WORD      // Default layer height in pixels (ignored) (2 bytes) // 10 bytes so far
+	BlendMode     BlendMode // Blend mode (2 bytes) // 12 bytes so far
+	Opacity       BYTE      // Opacity, only valid if the header flag is set (1 byte) // 13 bytes so far
+	Reserved      [3]BYTE   // For future (set to zero) (3 bytes) // 16 bytes so far
+	LayerName     STRING    // Layer name (variable length)
+	TilesetIndex  DWORD     // Tileset index, only for tilemap layers (4 bytes)
+	UUID          UUID      // Layer UUID, only if the header flag is set (16 bytes)
+}
+
+// parseChunk0x2004 parses the layer chunk
+func parseChunk0x2004(data []byte, headerFlags DWORD) (*Chunk0x2004, error) {
+	r := bytes.NewReader(data)
+
+	chunk := &Chunk0x2004{}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Flags); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.LayerType); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.ChildLevel); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.DefaultWidth); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.DefaultHeight); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.BlendMode); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Opacity); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Reserved); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.LayerName.Length); err != nil {
+		return nil, err
+	}
+	chunk.LayerName.Chars = make([]BYTE, chunk.LayerName.Length)
+	if err := binary.Read(r, binary.LittleEndian, &chunk.LayerName.Chars); err != nil {
+		return nil, err
+	}
+	if chunk.LayerType == LayerTypeTilemap {
+		if err := binary.Read(r, binary.LittleEndian, &chunk.TilesetIndex); err != nil {
+			return nil, err
+		}
+	}
+	if headerFlags&HeaderFlagLayersHaveUUID != 0 {
+		if err := binary.Read(r, binary.LittleEndian, &chunk.UUID); err != nil {
+			return nil, err
+		}
+	}
+
+	return chunk, nil
+}
+
+// ASELayer represents a layer of the sprite.
+type ASELayer struct {
+	Index        int       // Layer index (order of the layer chunks)
+	Name         string    // Layer name
+	Type         WORD      // LayerTypeNormal, LayerTypeGroup or LayerTypeTilemap
+	Flags        WORD      // Layer flags
+	ChildLevel   int       // Depth in the layer tree, 0 for top-level layers (see LayerTree)
+	BlendMode    BlendMode // Blend mode
+	Opacity      BYTE      // Opacity (0-255), 255 when the header says it is not valid
+	TilesetIndex int       // Tileset used by tilemap layers
+	UserData     *UserData // Layer user data, nil if not set
+}
+
+// IsVisible checks if the layer is visible
+func (l ASELayer) IsVisible() bool {
+	return l.Flags&LayerFlagVisible != 0
+}
+
+// IsBackground checks if the layer is the background layer
+func (l ASELayer) IsBackground() bool {
+	return l.Flags&LayerFlagBackground != 0
+}
+
+// newASELayer creates the public layer from the parsed layer chunk
+func newASELayer(index int, chunk *Chunk0x2004, header *Header) ASELayer {
+	opacity := chunk.Opacity
+	if !header.IsLayerOpacityValid() {
+		opacity = 255
+	}
+
+	return ASELayer{
+		Index:        index,
+		Name:         string(chunk.LayerName.Chars),
+		Type:         chunk.LayerType,
+		Flags:        chunk.Flags,
+		ChildLevel:   int(chunk.ChildLevel),
+		BlendMode:    chunk.BlendMode,
+		Opacity:      opacity,
+		TilesetIndex: int(chunk.TilesetIndex),
+	}
+}
+
+// IsGroup checks if the layer is a group of layers
+func (l ASELayer) IsGroup() bool {
+	return l.Type == LayerTypeGroup
+}
+
+// LayerNode is a layer in the layer tree.
+type LayerNode struct {
+	Layer    ASELayer
+	Parent   *LayerNode   // Group holding the layer, nil for top-level layers
+	Children []*LayerNode // Layers of a group, bottom layer first
+}
+
+// LayerTree returns the top-level layers, bottom layer first, with the layers
+// of the groups as their children. Aseprite stores the layers flat, each one
+// with its depth (ChildLevel) below the last group read.
+func (f *ASEFile) LayerTree() []*LayerNode {
+	nodes := make([]*LayerNode, len(f.Layers))
+	for i, layer := range f.Layers {
+		nodes[i] = &LayerNode{Layer: layer}
+	}
+
+	var roots []*LayerNode
+	for i, parent := range f.layerParents() {
+		if parent < 0 {
+			roots = append(roots, nodes[i])
+			continue
+		}
+		nodes[i].Parent = nodes[parent]
+		nodes[parent].Children = append(nodes[parent].Children, nodes[i])
+	}
+	return roots
+}
+
+// LayerParent returns the group holding a layer, false for top-level layers.
+func (f *ASEFile) LayerParent(index int) (ASELayer, bool) {
+	parent := f.layerParents()
+	if index < 0 || index >= len(parent) || parent[index] < 0 {
+		return ASELayer{}, false
+	}
+	return f.Layers[parent[index]], true
+}
+
+// IsLayerVisible checks if a layer and all the groups holding it are visible,
+// as hiding a group hides its layers in Aseprite.
+func (f *ASEFile) IsLayerVisible(index int) bool {
+	parent := f.layerParents()
+	for index >= 0 && index < len(f.Layers) {
+		if !f.Layers[index].IsVisible() {
+			return false
+		}
+		index = parent[index]
+	}
+	return true
+}
+
+// hiddenLayers marks the layers left out of the composited frames: the hidden
+// layers and the layers of hidden groups. It returns nil when every layer is
+// drawn.
+func (f *ASEFile) hiddenLayers() []bool {
+	if f.showHidden {
+		return nil
+	}
+	var hidden []bool
+	for i, parent := range f.layerParents() {
+		if f.Layers[i].IsVisible() && (parent < 0 || hidden == nil || !hidden[parent]) {
+			continue
+		}
+		if hidden == nil {
+			hidden = make([]bool, len(f.Layers))
+		}
+		hidden[i] = true
+	}
+	return hidden
+}
+
+// isHidden checks if the cels of a layer are left out, see hiddenLayers
+func isHidden(hidden []bool, layer int) bool {
+	return layer >= 0 && layer < len(hidden) && hidden[layer]
+}
+
+// layerParents returns the index of the parent group of every layer, -1 for
+// top-level layers
+func (f *ASEFile) layerParents() []int {
+	parents := make([]int, len(f.Layers))
+	var groups []int // Open groups, by child level
+	for i, layer := range f.Layers {
+		level := min(max(layer.ChildLevel, 0), len(groups))
+		groups = groups[:level]
+		parents[i] = -1
+		if level > 0 {
+			parents[i] = groups[level-1]
+		}
+		if layer.IsGroup() {
+			groups = append(groups, i)
+		}
+	}
+	return parents
+}
+
+// excludedLayers marks the layers left out by keep, with the children of the
+// groups left out. It returns nil when every layer is kept.
+func (f *ASEFile) excludedLayers(keep func(ASELayer) bool) []bool {
+	if keep == nil {
+		return nil
+	}
+	parents := f.layerParents()
+	excluded := make([]bool, len(f.Layers))
+	dropped := false
+	for i, layer := range f.Layers {
+		// Parents come before their children
+		excluded[i] = !keep(layer) || (parents[i] >= 0 && excluded[parents[i]])
+		dropped = dropped || excluded[i]
+	}
+	if !dropped {
+		return nil
+	}
+	return excluded
+}
+
+// dropLayers removes the excluded layers and gives the cels of the others
+// their new layer index. The cels of the excluded layers must be gone already.
+func (f *ASEFile) dropLayers(excluded []bool) {
+	newIndex := make([]int, len(f.Layers))
+	var layers []ASELayer
+	for i, layer := range f.Layers {
+		if i < len(excluded) && excluded[i] {
+			continue
+		}
+		newIndex[i] = len(layers)
+		layer.Index = len(layers)
+		layers = append(layers, layer)
+	}
+	f.Layers = layers
+
+	for _, cels := range f.frameCels {
+		for i := range cels {
+			if cels[i].layerIndex < len(newIndex) {
+				cels[i].layerIndex = newIndex[cels[i].layerIndex]
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package asevre
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Limits bounds the memory a file can make the parser allocate, so untrusted
+// files can't exhaust it with huge declared sizes. Zero fields use the
+// DefaultLimits value.
+type Limits struct {
+	MaxFileSize  int64 // Bytes of the file
+	MaxImageSize int64 // Pixels of a single image: the canvas, a cel or a tile. Tiles of a tilemap cel, colors of a palette
+	MaxTotalSize int64 // Pixels (tiles, colors) of every decoded image and palette together
+}
+
+// DefaultLimits fit any sprite made for a game while keeping a decoded file
+// under a few GiB.
+var DefaultLimits = Limits{
+	MaxFileSize:  256 << 20,
+	MaxImageSize: 1 << 26, // 8192x8192
+	MaxTotalSize: 1 << 28,
+}
+
+// withDefaults fills the unset limits
+func (l Limits) withDefaults() Limits {
+	if l.MaxFileSize <= 0 {
+		l.MaxFileSize = DefaultLimits.MaxFileSize
+	}
+	if l.MaxImageSize <= 0 {
+		l.MaxImageSize = DefaultLimits.MaxImageSize
+	}
+	if l.MaxTotalSize <= 0 {
+		l.MaxTotalSize = DefaultLimits.MaxTotalSize
+	}
+	return l
+}
+
+// budget accounts the images decoded from a file against the limits
+type budget struct {
+	limits Limits
+	total  int64
+}
+
+// take reserves count images of width x height pixels
+func (b *budget) take(what string, width, height, count int) error {
+	size := int64(width) * int64(height)
+	if size > b.limits.MaxImageSize {
+		return fmt.Errorf("%w: %s of %dx%d", ErrLimitExceeded, what, width, height)
+	}
+	b.total += size * int64(count)
+	if b.total > b.limits.MaxTotalSize {
+		return fmt.Errorf("%w: %s: more than %d pixels decoded", ErrLimitExceeded, what, b.limits.MaxTotalSize)
+	}
+	return nil
+}
+
+// readAll reads r, failing when it holds more than limit bytes
+func readAll(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: file larger than %d bytes", ErrLimitExceeded, limit)
+	}
+	return data, nil
+}
+
+// readBytes reads n bytes, checking first that they are there so a bad length
+// doesn't allocate more than the data holds
+func readBytes(r *bytes.Reader, n int) ([]BYTE, error) {
+	if n > r.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data := make([]BYTE, n)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
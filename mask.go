@@ -0,0 +1,122 @@
+package asevre
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Chunk0x2016 represents the mask chunk, deprecated: only old files have it
+type Chunk0x2016 struct {
+	X, Y     SHORT   // Position of the mask (4 bytes)
+	Width    WORD    // Width of the mask (2 bytes)
+	Height   WORD    // Height of the mask (2 bytes)
+	Reserved [8]BYTE // For future (set to zero) (8 bytes)
+	Name     STRING  // Mask name (variable length)
+	Bitmap   []BYTE  // Bit map data, height*((width+7)/8) bytes: 8 pixels per byte, the leftmost in the high bit
+}
+
+// parseChunk0x2016 parses the mask chunk
+func parseChunk0x2016(data []byte) (*Chunk0x2016, error) {
+	r := bytes.NewReader(data)
+
+	chunk := &Chunk0x2016{}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.X); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Y); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Width); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Height); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Reserved); err != nil {
+		return nil, err
+	}
+	name, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	chunk.Name = name
+
+	size := int(chunk.Height) * maskStride(int(chunk.Width))
+	if r.Len() < size {
+		return nil, fmt.Errorf("mask bitmap too short: %d bytes out of %d", r.Len(), size)
+	}
+	chunk.Bitmap = make([]BYTE, size)
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Bitmap); err != nil {
+		return nil, err
+	}
+
+	return chunk, nil
+}
+
+// Mask is a selection saved by old versions of Aseprite (mask chunk): the
+// pixels of Bounds whose bit is set.
+type Mask struct {
+	Name   string
+	Bounds image.Rectangle // Position and size in the canvas
+	Bitmap []byte          // Rows of (width+7)/8 bytes, 8 pixels per byte, the leftmost in the high bit
+}
+
+// newMask converts a mask chunk
+func newMask(chunk *Chunk0x2016) Mask {
+	at := image.Pt(int(chunk.X), int(chunk.Y))
+	return Mask{
+		Name:   string(chunk.Name.Chars),
+		Bounds: image.Rectangle{Min: at, Max: at.Add(image.Pt(int(chunk.Width), int(chunk.Height)))},
+		Bitmap: chunk.Bitmap,
+	}
+}
+
+// maskStride returns the bytes of a row of a mask bitmap
+func maskStride(width int) int {
+	return (width + 7) / 8
+}
+
+// Contains checks if the pixel at x, y of the canvas is in the mask
+func (m Mask) Contains(x, y int) bool {
+	if !image.Pt(x, y).In(m.Bounds) {
+		return false
+	}
+	x, y = x-m.Bounds.Min.X, y-m.Bounds.Min.Y
+	i := y*maskStride(m.Bounds.Dx()) + x/8
+	return i < len(m.Bitmap) && m.Bitmap[i]&(0x80>>(x%8)) != 0
+}
+
+// Image returns the mask as an alpha image placed at Bounds, opaque where the
+// pixels are in the mask.
+func (m Mask) Image() *image.Alpha {
+	img := image.NewAlpha(m.Bounds)
+	for y := m.Bounds.Min.Y; y < m.Bounds.Max.Y; y++ {
+		for x := m.Bounds.Min.X; x < m.Bounds.Max.X; x++ {
+			if m.Contains(x, y) {
+				img.SetAlpha(x, y, color.Alpha{A: 255})
+			}
+		}
+	}
+	return img
+}
+
+// scale enlarges the mask n times
+func (m Mask) scale(n int) Mask {
+	scaled := Mask{
+		Name:   m.Name,
+		Bounds: image.Rectangle{Min: m.Bounds.Min.Mul(n), Max: m.Bounds.Max.Mul(n)},
+	}
+	stride := maskStride(scaled.Bounds.Dx())
+	scaled.Bitmap = make([]byte, scaled.Bounds.Dy()*stride)
+	for y := 0; y < scaled.Bounds.Dy(); y++ {
+		for x := 0; x < scaled.Bounds.Dx(); x++ {
+			if m.Contains(m.Bounds.Min.X+x/n, m.Bounds.Min.Y+y/n) {
+				scaled.Bitmap[y*stride+x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,225 @@
+package asevre
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"slices"
+)
+
+// MergeFrames combines files sharing their canvas size, layers, palette and
+// tileset into one file: the frames are appended in order and the tags and
+// slices of every file are moved to the frames they now have. It is meant for
+// animation sets split in modules (idle.aseprite, run.aseprite, ...).
+func MergeFrames(files ...ASEFile) (ASEFile, error) {
+	if len(files) == 0 {
+		return ASEFile{}, errors.New("no files to merge")
+	}
+
+	merged := files[0]
+	for i, file := range files[1:] {
+		if err := merged.AppendTags(file); err != nil {
+			return ASEFile{}, fmt.Errorf("file %d: %w", i+1, err)
+		}
+	}
+	return merged, nil
+}
+
+// AppendTags appends the frames of another file after the frames of f, with
+// its tags and slices moved to the appended frames. Frames outside of a tag
+// are appended too, untagged. The default tags of untagged files are not
+// kept: a result without tags gets a default tag of all its frames. The files
+// must have the same canvas size, color depth and layers; indexed files must
+// also share their palette, and files with tilemaps their tileset.
+func (f *ASEFile) AppendTags(other ASEFile) error {
+	if err := f.checkMergeable(other); err != nil {
+		return err
+	}
+	offset := f.frameCount()
+	otherFrames := other.frameCount()
+
+	if len(f.Layers) == 0 {
+		f.Layers = other.Layers
+	}
+	if len(f.Tileset.Tiles) == 0 {
+		f.Tileset = other.Tileset
+	}
+	if len(f.Palette) == 0 {
+		f.Palette = other.Palette
+		f.paletteNames = other.paletteNames
+	}
+
+	// The palette indices and trim offsets are only usable for all the frames
+	if len(f.Indices) == offset && len(other.Indices) == otherFrames {
+		f.Indices = slices.Concat(f.Indices, other.Indices)
+	} else {
+		f.Indices = nil
+	}
+	if f.TrimOffsets != nil || other.TrimOffsets != nil {
+		f.TrimOffsets = slices.Concat(padPoints(f.TrimOffsets, offset), padPoints(other.TrimOffsets, otherFrames))
+	}
+
+	f.Images = slices.Concat(f.Images, other.Images)
+	f.Durations = slices.Concat(f.Durations, other.Durations)
+	f.LayerFrames = mergeLayerFrames(f.LayerFrames, other.LayerFrames, offset, otherFrames)
+	if f.FrameData != nil || other.FrameData != nil {
+		f.FrameData = slices.Concat(padFrames(f.FrameData, offset), padFrames(other.FrameData, otherFrames))
+	}
+	// Frames may have no tilemap or several, list them from the cels
+	f.Tilemaps = slices.Concat(f.celTilemaps(), other.celTilemaps())
+	f.frameCels = slices.Concat(f.frameCels, other.frameCels)
+	f.Header.FrameCount = WORD(offset + otherFrames)
+
+	// The default tags of untagged files are made again for the merged frames
+	f.State = slices.DeleteFunc(slices.Clone(f.State), func(tag ASETag) bool { return tag.synthesized })
+	for _, tag := range other.State {
+		if tag.synthesized {
+			continue
+		}
+		tag.FromFrame += offset
+		tag.ToFrame += offset
+		f.State = append(f.State, tag)
+	}
+
+	f.Slices = mergeSlices(f.Slices, other.Slices, offset)
+	f.refreshStates()
+	f.addDefaultTag()
+	return nil
+}
+
+// celTilemaps returns the tilemaps of the cels of every frame in order, or
+// the tilemaps of the file when it has no cels (read from JSON)
+func (f *ASEFile) celTilemaps() []ASETilemap {
+	if f.frameCels == nil {
+		return f.Tilemaps
+	}
+	var tilemaps []ASETilemap
+	for _, cels := range f.frameCels {
+		for _, c := range cels {
+			if c.tilemap != nil {
+				tilemaps = append(tilemaps, *c.tilemap)
+			}
+		}
+	}
+	return tilemaps
+}
+
+// checkMergeable checks that the frames of another file can follow the frames of f
+func (f *ASEFile) checkMergeable(other ASEFile) error {
+	if f.Header.Width != other.Header.Width || f.Header.Height != other.Header.Height {
+		return fmt.Errorf("canvas size mismatch: %dx%d and %dx%d", f.Header.Width, f.Header.Height, other.Header.Width, other.Header.Height)
+	}
+	if f.Header.ColorDepth != other.Header.ColorDepth {
+		return fmt.Errorf("color depth mismatch: %s and %s", f.Header.GetColorDepthDescription(), other.Header.GetColorDepthDescription())
+	}
+
+	if len(f.Layers) > 0 && len(other.Layers) > 0 {
+		if len(f.Layers) != len(other.Layers) {
+			return fmt.Errorf("layers mismatch: %d and %d layers", len(f.Layers), len(other.Layers))
+		}
+		for i, layer := range f.Layers {
+			if layer.Name != other.Layers[i].Name || layer.Type != other.Layers[i].Type {
+				return fmt.Errorf("layer %d mismatch: %q and %q", i, layer.Name, other.Layers[i].Name)
+			}
+		}
+	}
+
+	if f.Header.ColorDepth == ColorDepthIndexed && len(f.Palette) > 0 && len(other.Palette) > 0 {
+		if f.Header.TransparentIdx != other.Header.TransparentIdx || !samePalette(f.Palette, other.Palette) {
+			return errors.New("palette mismatch")
+		}
+	}
+
+	if len(f.Tileset.Tiles) > 0 && len(other.Tileset.Tiles) > 0 {
+		t, o := f.Tileset, other.Tileset
+		if t.ID != o.ID || len(t.Tiles) != len(o.Tiles) || t.TileWidth != o.TileWidth || t.TileHeight != o.TileHeight {
+			return fmt.Errorf("tileset mismatch: %d tiles of %dx%d and %d tiles of %dx%d", len(t.Tiles), t.TileWidth, t.TileHeight, len(o.Tiles), o.TileWidth, o.TileHeight)
+		}
+	}
+
+	return nil
+}
+
+// frameCount returns the number of frames of the file
+func (f *ASEFile) frameCount() int {
+	return max(len(f.Images), len(f.Durations), len(f.frameCels))
+}
+
+// samePalette checks if two palettes have the same colors
+func samePalette(a, b color.Palette) bool {
+	return slices.EqualFunc(a, b, func(x, y color.Color) bool {
+		return color.NRGBAModel.Convert(x) == color.NRGBAModel.Convert(y)
+	})
+}
+
+// padPoints returns the points for n frames, at the origin when missing
+func padPoints(points []image.Point, n int) []image.Point {
+	padded := make([]image.Point, n)
+	copy(padded, points)
+	return padded
+}
+
+// mergeLayerFrames appends the frames of the layers of another file, nil
+// unless both files have them
+func mergeLayerFrames(current, other map[string][]image.Image, n, otherN int) map[string][]image.Image {
+	if current == nil || other == nil {
+		return nil
+	}
+	merged := make(map[string][]image.Image, len(current))
+	for name, frames := range current {
+		merged[name] = slices.Concat(padImages(frames, n), padImages(other[name], otherN))
+	}
+	return merged
+}
+
+// padImages returns n images, the missing ones nil
+func padImages(images []image.Image, n int) []image.Image {
+	padded := make([]image.Image, n)
+	copy(padded, images)
+	return padded
+}
+
+// padFrames returns n frames, the missing ones empty
+func padFrames(frames []ASEFrame, n int) []ASEFrame {
+	padded := make([]ASEFrame, n)
+	copy(padded, frames)
+	return padded
+}
+
+// mergeSlices appends the keys of the slices of another file, moved by offset
+// frames. Slices of f missing in the other file are hidden from offset on.
+func mergeSlices(current, other []ASESlice, offset int) []ASESlice {
+	merged := make([]ASESlice, len(current))
+	for i, slice := range current {
+		slice.Keys = slices.Clone(slice.Keys)
+		merged[i] = slice
+	}
+
+	found := make([]bool, len(merged))
+	for _, slice := range other {
+		keys := make([]SliceKey, len(slice.Keys))
+		for i, key := range slice.Keys {
+			key.Frame += offset
+			keys[i] = key
+		}
+
+		i := slices.IndexFunc(merged, func(s ASESlice) bool { return s.Name == slice.Name })
+		if i < 0 {
+			slice.Keys = keys
+			merged = append(merged, slice)
+			continue
+		}
+		merged[i].Keys = append(merged[i].Keys, keys...)
+		if i < len(found) {
+			found[i] = true
+		}
+	}
+
+	for i, ok := range found {
+		if !ok && len(merged[i].Keys) > 0 && !merged[i].Keys[len(merged[i].Keys)-1].Bounds.Empty() {
+			merged[i].Keys = append(merged[i].Keys, SliceKey{Frame: offset})
+		}
+	}
+	return merged
+}
@@ -0,0 +1,70 @@
+package asevre
+
+import (
+	"maps"
+	"strings"
+)
+
+// DefaultMetaLayer is the name of the tilemap layer that annotates the other
+// tilemap layers, see WithMetaLayer.
+const DefaultMetaLayer = "meta"
+
+// applyMetaLayer merges the properties of the tiles of the meta layer into the
+// tiles at the same place in the other tilemap layers of every frame
+func (f *ASEFile) applyMetaLayer(name string) {
+	tileWidth, tileHeight := f.Tileset.TileWidth, f.Tileset.TileHeight
+	if name == "" || tileWidth == 0 || tileHeight == 0 {
+		return
+	}
+
+	for _, cels := range f.frameCels {
+		var metas, others []frameCel
+		for _, c := range cels {
+			if c.tilemap == nil {
+				continue
+			}
+			if c.layerIndex < len(f.Layers) && strings.EqualFold(f.Layers[c.layerIndex].Name, name) {
+				metas = append(metas, c)
+			} else {
+				others = append(others, c)
+			}
+		}
+
+		for _, meta := range metas {
+			for _, c := range others {
+				for row, tiles := range c.tilemap.Tiles {
+					for col := range tiles {
+						// Tile of the meta layer covering the top-left pixel of the tile
+						metaCol := floorDiv(c.x+col*tileWidth-meta.x, tileWidth)
+						metaRow := floorDiv(c.y+row*tileHeight-meta.y, tileHeight)
+						if metaRow < 0 || metaRow >= len(meta.tilemap.Tiles) || metaCol < 0 || metaCol >= len(meta.tilemap.Tiles[metaRow]) {
+							continue
+						}
+						properties := meta.tilemap.Tiles[metaRow][metaCol].Properties
+						if len(properties) == 0 {
+							continue
+						}
+
+						// The maps may be shared with other tiles, merge into a copy
+						tile := &tiles[col]
+						merged := maps.Clone(tile.Properties)
+						if merged == nil {
+							merged = map[string]string{}
+						}
+						maps.Copy(merged, properties)
+						tile.Properties = merged
+					}
+				}
+			}
+		}
+	}
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
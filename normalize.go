@@ -0,0 +1,236 @@
+package asevre
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"image/color"
+	"slices"
+	"time"
+)
+
+// RemoveLayers removes the layers for which remove returns true, together
+// with their cels, and composites the frames again without them.
+func (f *ASEFile) RemoveLayers(remove func(ASELayer) bool) {
+	// New index of every kept layer (-1 for removed layers)
+	newIndex := make([]int, len(f.Layers))
+	var layers []ASELayer
+	for i, layer := range f.Layers {
+		newIndex[i] = -1
+		if remove(layer) {
+			continue
+		}
+		newIndex[i] = len(layers)
+		layer.Index = len(layers)
+		layers = append(layers, layer)
+	}
+	f.Layers = layers
+
+	var tilemaps []ASETilemap
+	for frame, cels := range f.frameCels {
+		var kept []frameCel
+		for _, c := range cels {
+			if c.layerIndex < len(newIndex) {
+				if newIndex[c.layerIndex] < 0 {
+					continue
+				}
+				c.layerIndex = newIndex[c.layerIndex]
+			}
+			if c.tilemap != nil {
+				tilemaps = append(tilemaps, *c.tilemap)
+			}
+			kept = append(kept, c)
+		}
+		f.frameCels[frame] = kept
+	}
+	f.Tilemaps = tilemaps
+
+	f.Images = nil
+	if f.hasImageCels() {
+		for i := range f.frameCels {
+			f.Images = append(f.Images, f.compositeFrame(i, false))
+		}
+	}
+	if f.Indices != nil {
+		f.Indices = nil
+		for i := range f.Images {
+			f.Indices = append(f.Indices, f.compositeIndices(i))
+		}
+	}
+
+	f.refreshStates()
+}
+
+// QuantizeDurations rounds every frame duration to a multiple of a tick of the
+// given rate (e.g. 60 for 60Hz), with at least one tick per frame. The result
+// is then rounded to whole milliseconds, the unit the file stores, so it is
+// only as close to the ticks as that allows: 3 ticks of 60Hz are 50ms, 1 tick
+// is 17ms. Rates of 0 or less leave the durations unchanged.
+func (f *ASEFile) QuantizeDurations(rate int) {
+	if rate <= 0 {
+		return
+	}
+	tick := time.Second / time.Duration(rate)
+	for i, duration := range f.Durations {
+		ticks := max((duration+tick/2)/tick, 1)
+		f.Durations[i] = (ticks * time.Second / time.Duration(rate)).Round(time.Millisecond)
+	}
+	f.refreshStates()
+}
+
+// SortTags sorts the tags by name. Tags with the same name keep their order.
+func (f *ASEFile) SortTags() {
+	slices.SortStableFunc(f.State, func(a, b ASETag) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+// SharedPalette builds a palette with every color used by the frames and tiles
+// of the files. The first entry is the transparent color.
+func SharedPalette(files ...ASEFile) (color.Palette, error) {
+	palette := color.Palette{color.NRGBA{}}
+	seen := map[color.NRGBA]bool{{}: true}
+
+	addColors := func(img image.Image) {
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				if c.A == 0 {
+					continue
+				}
+				if !seen[c] {
+					seen[c] = true
+					palette = append(palette, c)
+				}
+			}
+		}
+	}
+
+	for _, file := range files {
+		for _, img := range file.Images {
+			addColors(img)
+		}
+		for _, tile := range file.Tileset.Tiles {
+			addColors(tile)
+		}
+	}
+
+	if len(palette) > 256 {
+		return nil, fmt.Errorf("too many colors for an indexed palette: %d", len(palette))
+	}
+
+	return palette, nil
+}
+
+// ConvertToIndexed converts the frames to the Indexed color depth using the
+// palette. Transparent pixels use the first fully transparent palette entry,
+// every other pixel the closest palette color.
+func (f *ASEFile) ConvertToIndexed(palette color.Palette) error {
+	if len(palette) == 0 || len(palette) > 256 {
+		return fmt.Errorf("invalid palette size: %d", len(palette))
+	}
+
+	transparent := -1
+	for i, c := range palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			transparent = i
+			break
+		}
+	}
+	if transparent < 0 {
+		return fmt.Errorf("palette has no transparent color")
+	}
+
+	f.Indices = nil
+	for i, img := range f.Images {
+		paletted := quantize(img, palette, transparent)
+		f.Images[i] = paletted
+		f.Indices = append(f.Indices, paletted.Pix)
+	}
+
+	for _, cels := range f.frameCels {
+		for i, c := range cels {
+			if c.image == nil {
+				continue
+			}
+			paletted := quantize(c.image, palette, transparent)
+			cels[i].image = paletted
+			cels[i].indices = paletted.Pix
+		}
+	}
+
+	f.Tileset.indices = make([][]byte, len(f.Tileset.Tiles))
+	for i, tile := range f.Tileset.Tiles {
+		paletted := quantize(tile, palette, transparent)
+		f.Tileset.Tiles[i] = paletted
+		f.Tileset.indices[i] = paletted.Pix
+	}
+
+	f.Palette = palette
+	f.paletteNames = nil
+	f.Header.ColorDepth = ColorDepthIndexed
+	f.Header.TransparentIdx = BYTE(transparent)
+	f.Tileset.resetFlips()
+	f.refreshStates()
+
+	return nil
+}
+
+// quantize converts an image to the palette, using the transparent index for transparent pixels
+func quantize(img image.Image, palette color.Palette, transparent int) *image.Paletted {
+	b := img.Bounds()
+	paletted := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			index := transparent
+			if _, _, _, a := c.RGBA(); a != 0 {
+				index = palette.Index(c)
+			}
+			paletted.SetColorIndex(x, y, uint8(index))
+		}
+	}
+	return paletted
+}
+
+// refreshStates rebuilds the frames and tilemaps of every tag from the file
+func (f *ASEFile) refreshStates() {
+	for i := range f.State {
+		state := &f.State[i]
+		state.Tilemaps = nil
+		state.Frames = nil
+		state.Triggers = nil
+		state.Animation.Triggers = nil
+		// Tags edited out of the frames of the file keep no frames
+		if state.Validate(f.frameCount()) != nil {
+			continue
+		}
+
+		for j := state.FromFrame; j <= state.ToFrame; j++ {
+			if j < len(f.Tilemaps) {
+				state.Tilemaps = append(state.Tilemaps, f.Tilemaps[j])
+			}
+			if j < len(f.Images) {
+				state.Frames = append(state.Frames, f.Images[j])
+			}
+		}
+
+		if state.ToFrame < len(f.Durations) {
+			state.Durations = slices.Clone(f.Durations[state.FromFrame : state.ToFrame+1])
+			// Only the entry of the tag's original index is populated
+			for k := range state.FrameDuration {
+				if state.FrameDuration[k] != nil {
+					state.FrameDuration[k] = state.Durations
+				}
+			}
+			if state.HasAnimations {
+				state.Animation.Duration = state.Durations
+			}
+		}
+
+		// Tags of a single frame fire its triggers when they start playing
+		state.Triggers = f.tagTriggers(*state)
+		state.Animation.Triggers = state.Triggers
+	}
+}
@@ -0,0 +1,295 @@
+package asevre
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// ParseOptions controls how ParseAseprite decodes a file.
+type ParseOptions struct {
+	// KeepIndices retains the palette index of every pixel of indexed sprites in ASEFile.Indices.
+	KeepIndices bool
+
+	// ResolveExternal loads the files referenced by the external files chunk
+	// (linked tilesets). By default they are parsed from the same file system,
+	// relative to the sprite.
+	ResolveExternal ExternalResolver
+
+	// MetaLayer is the name of the tilemap layer (case insensitive) whose
+	// tiles add their properties to the tiles at the same place in the other
+	// tilemap layers, DefaultMetaLayer by default.
+	MetaLayer string
+
+	// Strict fails on anything that doesn't follow the specification: frame
+	// size mismatches, bytes after the last frame, unknown or broken chunks.
+	// Otherwise these are skipped and reported in ASEFile.Warnings.
+	Strict bool
+
+	// Limits bounds the memory the file can make the parser allocate.
+	Limits Limits
+
+	// Workers is the number of goroutines decoding the cels and frames, 0 for
+	// one per CPU. The result doesn't depend on it.
+	Workers int
+
+	// ColorManagement converts the pixels and the palette from the color
+	// profile of the file to sRGB, so colors match other tools.
+	ColorManagement bool
+
+	// Trim cuts the fully transparent borders of the frames, recording their
+	// position in the canvas in ASEFile.TrimOffsets.
+	Trim bool
+
+	// KeepLayer decides which layers are parsed, all of them when nil. The
+	// cels of the other layers are never decoded and the layers are left out
+	// of ASEFile.Layers; leaving a group out leaves out its children too.
+	KeepLayer func(layer ASELayer) bool
+
+	// Frames and Tags select the frames to parse, all of them when both are
+	// nil. Only the cels of these frames are decoded, and the file holds them
+	// alone: frame numbers, tags and slice keys refer to the frames kept.
+	// Tags are clipped to the frames kept, tags without any are dropped.
+	Frames []FrameRange
+	Tags   []string // Names of the tags whose frames are parsed
+
+	// Logger receives the warnings and debug messages of the parser. Nothing
+	// is logged by default.
+	Logger *slog.Logger
+
+	// Scale enlarges the decoded file by an integer factor with
+	// nearest-neighbor scaling, with its sizes and positions, so pixel art is
+	// scaled once at load time instead of on every draw. 0 and 1 keep the
+	// original size.
+	Scale int
+
+	// Alpha is how the decoded images store their alpha: straight
+	// (*image.NRGBA) or premultiplied (*image.RGBA) for every image, or the
+	// mix of AlphaDefault.
+	Alpha AlphaMode
+
+	// LayerFrames composites the frames of every layer alone too, in
+	// ASEFile.LayerFrames.
+	LayerFrames bool
+
+	// HiddenLayers draws the layers hidden in the editor (and the layers of
+	// hidden groups) in the composited frames, which leave them out by default.
+	HiddenLayers bool
+
+	// Progress is called as the cels are decoded and the frames composited,
+	// with the steps done so far out of the total. Calls never overlap.
+	Progress func(done, total int)
+
+	parents []string // Files being parsed that refer to this one
+}
+
+// ParseOption configures ParseOptions.
+type ParseOption func(*ParseOptions)
+
+// WithPaletteIndices keeps the original palette indices of indexed sprites
+// alongside the decoded RGBA images.
+func WithPaletteIndices() ParseOption {
+	return func(o *ParseOptions) {
+		o.KeepIndices = true
+	}
+}
+
+// WithExternalResolver loads external files (linked tilesets) with resolve
+// instead of reading them next to the sprite.
+func WithExternalResolver(resolve ExternalResolver) ParseOption {
+	return func(o *ParseOptions) {
+		o.ResolveExternal = resolve
+	}
+}
+
+// WithMetaLayer annotates the tilemaps with the tilemap layer named name
+// instead of DefaultMetaLayer. The properties of the tiles of that layer (from
+// the user data of the tileset) are added to the tiles below them, so one
+// tileset of markers ("solid", "hazard", "spawn=player") describes every map.
+func WithMetaLayer(name string) ParseOption {
+	return func(o *ParseOptions) {
+		o.MetaLayer = name
+	}
+}
+
+// WithStrict fails instead of skipping what doesn't follow the specification.
+func WithStrict() ParseOption {
+	return func(o *ParseOptions) {
+		o.Strict = true
+	}
+}
+
+// WithLimits parses the file within limits instead of DefaultLimits.
+func WithLimits(limits Limits) ParseOption {
+	return func(o *ParseOptions) {
+		o.Limits = limits
+	}
+}
+
+// WithWorkers decodes the cels and frames on at most n goroutines. 1 decodes
+// everything serially.
+func WithWorkers(n int) ParseOption {
+	return func(o *ParseOptions) {
+		o.Workers = n
+	}
+}
+
+// WithColorManagement converts the colors from the color profile of the file
+// (fixed gamma or embedded ICC profile) to sRGB.
+func WithColorManagement() ParseOption {
+	return func(o *ParseOptions) {
+		o.ColorManagement = true
+	}
+}
+
+// WithTrim cuts the transparent borders of the frames. Draw every frame at
+// its ASEFile.TrimOffsets position to place it as in the canvas. Hitboxes,
+// anchors and slice frames are in the coordinates of the trimmed frames.
+func WithTrim() ParseOption {
+	return func(o *ParseOptions) {
+		o.Trim = true
+	}
+}
+
+// WithLayerFilter only parses the layers for which keep returns true. Filters
+// add up: a layer is parsed when every filter keeps it.
+func WithLayerFilter(keep func(layer ASELayer) bool) ParseOption {
+	return func(o *ParseOptions) {
+		previous := o.KeepLayer
+		o.KeepLayer = keep
+		if previous != nil {
+			o.KeepLayer = func(layer ASELayer) bool {
+				return previous(layer) && keep(layer)
+			}
+		}
+	}
+}
+
+// WithoutLayers leaves out the layers with the given names (case insensitive),
+// like editor-only guides or notes.
+func WithoutLayers(names ...string) ParseOption {
+	return WithLayerFilter(func(layer ASELayer) bool {
+		for _, name := range names {
+			if strings.EqualFold(layer.Name, name) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// WithoutLayersMatching leaves out the layers whose name matches pattern,
+// e.g. regexp.MustCompile("^_") for every layer starting with an underscore.
+func WithoutLayersMatching(pattern *regexp.Regexp) ParseOption {
+	return WithLayerFilter(func(layer ASELayer) bool {
+		return !pattern.MatchString(layer.Name)
+	})
+}
+
+// WithFrames parses the frames from one frame to another (both included)
+// instead of every frame. It can be given more than once.
+func WithFrames(from, to int) ParseOption {
+	return func(o *ParseOptions) {
+		o.Frames = append(o.Frames, FrameRange{From: from, To: to})
+	}
+}
+
+// WithTags parses the frames of the named tags instead of every frame, e.g.
+// only the "portrait" of a big character file.
+func WithTags(names ...string) ParseOption {
+	return func(o *ParseOptions) {
+		o.Tags = append(o.Tags, names...)
+	}
+}
+
+// WithLogger logs the warnings and debug messages of the parser to logger.
+func WithLogger(logger *slog.Logger) ParseOption {
+	return func(o *ParseOptions) {
+		o.Logger = logger
+	}
+}
+
+// WithScale enlarges the frames, cels and tiles n times (nearest neighbor),
+// with the sizes and positions referring to them: canvas, tiles, slices, grid.
+func WithScale(n int) ParseOption {
+	return func(o *ParseOptions) {
+		o.Scale = n
+	}
+}
+
+// WithAlpha stores every decoded image (frames, cels and tiles) with the
+// alpha of mode, e.g. AlphaPremultiplied for blend setups expecting it.
+func WithAlpha(mode AlphaMode) ParseOption {
+	return func(o *ParseOptions) {
+		o.Alpha = mode
+	}
+}
+
+// WithLayerFrames composites the frames of every layer on its own too, in
+// ASEFile.LayerFrames, to draw a layer (a character's weapon) separately.
+func WithLayerFrames() ParseOption {
+	return func(o *ParseOptions) {
+		o.LayerFrames = true
+	}
+}
+
+// WithHiddenLayers draws the hidden layers in the composited frames too, as
+// if every layer was visible.
+func WithHiddenLayers() ParseOption {
+	return func(o *ParseOptions) {
+		o.HiddenLayers = true
+	}
+}
+
+// WithProgress reports the progress of the parsing to progress, to drive a
+// progress bar while big files load.
+func WithProgress(progress func(done, total int)) ParseOption {
+	return func(o *ParseOptions) {
+		o.Progress = progress
+	}
+}
+
+// withoutSelection parses all the frames, undoing WithFrames and WithTags
+func withoutSelection() ParseOption {
+	return func(o *ParseOptions) {
+		o.Frames, o.Tags = nil, nil
+	}
+}
+
+// withParents records the files referring to the file being parsed
+func withParents(parents []string) ParseOption {
+	return func(o *ParseOptions) {
+		o.parents = parents
+	}
+}
+
+// newParseOptions applies the options over the defaults
+func newParseOptions(opts []ParseOption) ParseOptions {
+	options := ParseOptions{MetaLayer: DefaultMetaLayer}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	options.Limits = options.Limits.withDefaults()
+	if options.Logger == nil {
+		options.Logger = slog.New(discardHandler{})
+	}
+	return options
+}
+
+// discardHandler is a slog.Handler dropping every record
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// workers returns the number of goroutines decoding a file
+func (o ParseOptions) workers() int {
+	if o.Workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return o.Workers
+}
@@ -0,0 +1,216 @@
+package asevre
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+)
+
+// Palette is the palette of a file with the metadata of its colors.
+type Palette struct {
+	color.Palette          // Colors, by index
+	Names         []string // Name of every color, empty when it has none
+	Transparent   int      // Index of the transparent color of indexed sprites, -1 for other color depths
+}
+
+// ColorPalette returns the palette with the names of its colors (from the
+// 0x2019 chunk) and the transparent index.
+func (f *ASEFile) ColorPalette() Palette {
+	palette := Palette{
+		Palette:     f.Palette,
+		Names:       make([]string, len(f.Palette)),
+		Transparent: -1,
+	}
+	copy(palette.Names, f.paletteNames)
+	if f.Header.ColorDepth == ColorDepthIndexed {
+		palette.Transparent = int(f.Header.TransparentIdx)
+	}
+	return palette
+}
+
+// Name returns the name of a color, empty if it has none
+func (p Palette) Name(index int) string {
+	if index < 0 || index >= len(p.Names) {
+		return ""
+	}
+	return p.Names[index]
+}
+
+// Flags returns the flags of a color as stored in the 0x2019 chunk
+func (p Palette) Flags(index int) WORD {
+	if p.Name(index) != "" {
+		return PaletteEntryHasName
+	}
+	return 0
+}
+
+// Lookup returns the index of the first color with a name, false if there is none.
+func (p Palette) Lookup(name string) (int, bool) {
+	for i, n := range p.Names {
+		if n != "" && n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// IsTransparent checks if an index is the transparent color of an indexed sprite
+func (p Palette) IsTransparent(index int) bool {
+	return p.Transparent >= 0 && index == p.Transparent
+}
+
+// SetPaletteColor replaces a color of the palette and repaints the pixels
+// using it, see ReplacePalette.
+func (f *ASEFile) SetPaletteColor(index int, c color.Color) error {
+	if index < 0 || index >= len(f.Palette) {
+		return fmt.Errorf("palette index out of range: %d", index)
+	}
+	palette := slices.Clone(f.Palette)
+	palette[index] = c
+	return f.ReplacePalette(palette)
+}
+
+// ReplacePalette replaces the colors of the palette, which must keep its size,
+// and repaints the frames, cels and tiles so the file can be saved recolored.
+// Pixels of indexed sprites follow their palette index; pixels of the other
+// color depths are repainted when they have the exact old color of an entry.
+// Color names are kept.
+func (f *ASEFile) ReplacePalette(palette color.Palette) error {
+	if len(palette) != len(f.Palette) {
+		return fmt.Errorf("palette size mismatch: expected %d colors, got %d", len(f.Palette), len(palette))
+	}
+	palette = slices.Clone(palette)
+
+	if f.Header.ColorDepth == ColorDepthIndexed {
+		transparent := int(f.Header.TransparentIdx)
+		for _, cels := range f.frameCels {
+			for _, c := range cels {
+				if c.image != nil && c.indices != nil {
+					repaintIndexed(c.image, c.indices, palette, transparent)
+				}
+			}
+		}
+		for i, tile := range f.Tileset.Tiles {
+			if i < len(f.Tileset.indices) {
+				repaintIndexed(tile, f.Tileset.indices[i], palette, transparent)
+			}
+		}
+
+		switch {
+		case len(f.Indices) == len(f.Images):
+			for i, img := range f.Images {
+				repaintIndexed(img, f.Indices[i], palette, transparent)
+			}
+		case f.hasImageCels() && f.TrimOffsets == nil:
+			for i := range f.Images {
+				f.Images[i] = f.compositeFrame(i, false)
+			}
+		default:
+			recolorImages(f.Images, f.Palette, palette)
+		}
+	} else {
+		var images []image.Image
+		for _, cels := range f.frameCels {
+			for _, c := range cels {
+				if c.image != nil {
+					images = append(images, c.image)
+				}
+			}
+		}
+		images = append(images, f.Tileset.Tiles...)
+		images = append(images, f.Images...)
+		recolorImages(images, f.Palette, palette)
+	}
+
+	for _, frames := range f.LayerFrames {
+		recolorImages(frames, f.Palette, palette)
+	}
+
+	f.Palette = palette
+	f.Tileset.resetFlips()
+	f.refreshStates()
+	return nil
+}
+
+// repaintIndexed sets the pixels of an image to the colors of their palette indices
+func repaintIndexed(img image.Image, indices []byte, palette color.Palette, transparent int) {
+	switch img := img.(type) {
+	case *image.Paletted:
+		img.Palette = palette
+	case draw.Image:
+		b := img.Bounds()
+		if len(indices) < b.Dx()*b.Dy() {
+			return
+		}
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				img.Set(b.Min.X+x, b.Min.Y+y, indexedColor(palette, indices[y*b.Dx()+x], transparent))
+			}
+		}
+	}
+}
+
+// recolorImages repaints the pixels having the color of an old palette entry
+// with the color of the new entry. Images are changed in place, so the tiles
+// shared with the tilemaps are repainted too, and only once when listed twice.
+func recolorImages(images []image.Image, oldPalette, newPalette color.Palette) {
+	replace := map[color.NRGBA]color.Color{}
+	for i, c := range oldPalette {
+		old := color.NRGBAModel.Convert(c).(color.NRGBA)
+		if _, exists := replace[old]; !exists && old != color.NRGBAModel.Convert(newPalette[i]) {
+			replace[old] = newPalette[i]
+		}
+	}
+	if len(replace) == 0 {
+		return
+	}
+
+	seen := map[image.Image]bool{}
+	for _, img := range images {
+		dst, ok := img.(draw.Image)
+		if !ok || seen[img] {
+			continue
+		}
+		seen[img] = true
+		b := dst.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if c, exists := replace[color.NRGBAModel.Convert(dst.At(x, y)).(color.NRGBA)]; exists {
+					dst.Set(x, y, c)
+				}
+			}
+		}
+	}
+}
+
+// applyOldPalette writes the colors of the packets of an old palette chunk over
+// the palette, growing it as needed. Every packet skips entries after the
+// colors of the previous one. The colors of 6-bit chunks (0x0011) are scaled
+// to 8 bits.
+func applyOldPalette(palette color.Palette, packets []Packet, sixBit bool) color.Palette {
+	index := 0
+	for _, packet := range packets {
+		index += int(packet.NumberOfPalEntriesToSkipFromTheLastPacket)
+		for _, c := range packet.Colors {
+			r, g, b := c.Red, c.Green, c.Blue
+			if sixBit {
+				r, g, b = sixBitColor(r), sixBitColor(g), sixBitColor(b)
+			}
+			for len(palette) <= index {
+				palette = append(palette, color.RGBA{A: 255})
+			}
+			// Transparency comes from the header transparent index instead
+			palette[index] = color.RGBA{R: r, G: g, B: b, A: 255}
+			index++
+		}
+	}
+	return palette
+}
+
+// sixBitColor scales a color component from 0-63 to 0-255
+func sixBitColor(v BYTE) BYTE {
+	v &= 0x3f
+	return v<<2 | v>>4
+}
@@ -0,0 +1,14 @@
+package asevre
+
+import "slices"
+
+// Chunk0x2017 represents the path chunk. The specification reserves its type
+// but never defined its layout, so it keeps the data as it is.
+type Chunk0x2017 struct {
+	Data []BYTE // Data of the chunk, undecoded
+}
+
+// parseChunk0x2017 parses the path chunk
+func parseChunk0x2017(data []byte) (*Chunk0x2017, error) {
+	return &Chunk0x2017{Data: slices.Clone(data)}, nil
+}
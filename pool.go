@@ -0,0 +1,88 @@
+package asevre
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// zlibReader is a zlib reader with its source, reused through zlibReaders
+type zlibReader struct {
+	src bytes.Reader
+	r   io.ReadCloser
+}
+
+var (
+	// zlibReaders keeps the zlib readers (and their inflate state) between
+	// the decompressions of cels and tilesets
+	zlibReaders sync.Pool
+
+	// pixelBuffers keeps the buffers of the cel pixels decoded into images
+	pixelBuffers sync.Pool
+)
+
+// inflate decompresses the zlib data into buf, which it must fill exactly:
+// data inflating to more than len(buf) bytes is an error, so a small chunk
+// can't inflate to gigabytes, and so is data inflating to less, truncated
+// streams included. The stream is read to its end, which checks its checksum.
+func inflate(buf, data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("input data is empty")
+	}
+
+	z, _ := zlibReaders.Get().(*zlibReader)
+	if z == nil {
+		z = &zlibReader{}
+	}
+	z.src.Reset(data)
+	var err error
+	if z.r == nil {
+		z.r, err = zlib.NewReader(&z.src)
+	} else {
+		err = z.r.(zlib.Resetter).Reset(&z.src, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create zlib reader: %w", err)
+	}
+	defer func() {
+		z.src.Reset(nil)
+		zlibReaders.Put(z)
+	}()
+
+	n, err := io.ReadFull(z.r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("decompressed data of %d bytes, want %d: %w", n, len(buf), io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy decompressed data: %w", err)
+	}
+	// Nothing must be left but the end of the stream
+	var extra [1]byte
+	switch _, err := io.ReadFull(z.r, extra[:]); err {
+	case io.EOF:
+		return nil
+	case nil:
+		return fmt.Errorf("decompressed data larger than %d bytes", len(buf))
+	default:
+		return fmt.Errorf("failed to copy decompressed data: %w", err)
+	}
+}
+
+// getPixelBuffer returns a buffer of size bytes from pixelBuffers, to give
+// back with putPixelBuffer once its bytes aren't used
+func getPixelBuffer(size int) *[]byte {
+	buf, _ := pixelBuffers.Get().(*[]byte)
+	if buf == nil || cap(*buf) < size {
+		b := make([]byte, size)
+		return &b
+	}
+	*buf = (*buf)[:size]
+	return buf
+}
+
+// putPixelBuffer gives back a buffer of getPixelBuffer
+func putPixelBuffer(buf *[]byte) {
+	pixelBuffers.Put(buf)
+}
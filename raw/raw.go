@@ -0,0 +1,106 @@
+// Package raw exposes the decoded data of an Aseprite file with a stable and
+// documented memory layout, so plugins (optimizers, compressors, custom
+// exporters) can work on the pixels and tiles directly.
+//
+// Everything is stored row by row, from top to bottom, left to right, exactly
+// as in the .aseprite format once the zlib data is decompressed.
+package raw
+
+import (
+	"image/color"
+	"time"
+)
+
+// Format is the pixel format of a plane.
+type Format int
+
+const (
+	FormatRGBA      Format = 32 // 4 bytes per pixel: Red, Green, Blue, Alpha (non-premultiplied)
+	FormatGrayscale Format = 16 // 2 bytes per pixel: Value, Alpha
+	FormatIndexed   Format = 8  // 1 byte per pixel: Palette index
+)
+
+// BytesPerPixel returns the size of a pixel in bytes
+func (f Format) BytesPerPixel() int {
+	return int(f) / 8
+}
+
+// Plane is a rectangle of pixels.
+//
+// Pixel (x, y) starts at Pix[(y*Width + x) * Format.BytesPerPixel()].
+type Plane struct {
+	Width, Height int
+	Format        Format
+	Pix           []byte
+}
+
+// PixelOffset returns the index in Pix of the first byte of pixel (x, y)
+func (p *Plane) PixelOffset(x, y int) int {
+	return (y*p.Width + x) * p.Format.BytesPerPixel()
+}
+
+// Bit layout of a tile in a TileGrid (32 bits per tile)
+const (
+	TileIDMask       uint32 = 0x1fffffff // Tile ID (lower 29 bits)
+	TileXFlipMask    uint32 = 0x80000000 // X flip (bit 31)
+	TileYFlipMask    uint32 = 0x40000000 // Y flip (bit 30)
+	TileDiagFlipMask uint32 = 0x20000000 // Diagonal flip (bit 29)
+)
+
+// TileGrid is a tilemap. Tile (col, row) is Tiles[row*Columns + col], with the
+// tile ID and flip flags packed as described by the Tile*Mask constants.
+type TileGrid struct {
+	Columns, Rows int
+	Tiles         []uint32
+}
+
+// At returns the packed tile at (col, row)
+func (g *TileGrid) At(col, row int) uint32 {
+	return g.Tiles[row*g.Columns+col]
+}
+
+// Cel is the content of a layer in a frame. Image cels have a Plane, tilemap
+// cels a TileGrid. X and Y are the position of the cel in the canvas.
+type Cel struct {
+	Layer   int
+	X, Y    int
+	Opacity uint8
+	ZIndex  int
+	Plane   *Plane
+	Tiles   *TileGrid
+}
+
+// Frame holds the cels of a frame, in layer order.
+type Frame struct {
+	Duration time.Duration
+	Cels     []Cel
+}
+
+// Tileset stores its tiles as one vertical strip: a plane of TileWidth x
+// (TileHeight * Count) pixels where tile i starts at row i * TileHeight.
+type Tileset struct {
+	TileWidth, TileHeight int
+	Count                 int
+	Strip                 *Plane
+}
+
+// Tile returns the pixels of tile i (a TileWidth x TileHeight plane sharing the strip memory)
+func (t *Tileset) Tile(i int) *Plane {
+	size := t.TileWidth * t.TileHeight * t.Strip.Format.BytesPerPixel()
+	return &Plane{
+		Width:  t.TileWidth,
+		Height: t.TileHeight,
+		Format: t.Strip.Format,
+		Pix:    t.Strip.Pix[i*size : (i+1)*size],
+	}
+}
+
+// Sprite is the decoded content of a file.
+type Sprite struct {
+	Width, Height    int
+	Format           Format
+	Palette          []color.NRGBA
+	TransparentIndex uint8 // Transparent palette index (only for FormatIndexed)
+	Frames           []Frame
+	Tilesets         []Tileset
+}
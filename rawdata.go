@@ -0,0 +1,150 @@
+package asevre
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/retroblast-engine/asevre/raw"
+)
+
+// Raw returns the decoded cels, tiles and palette of the file with the memory
+// layout documented in the raw package. The returned data is a copy. The
+// planes of indexed sprites are RGBA when their palette indices aren't known:
+// files parsed without WithPaletteIndices, and tiles from external files.
+func (f *ASEFile) Raw() *raw.Sprite {
+	format := raw.Format(f.Header.ColorDepth)
+
+	sprite := &raw.Sprite{
+		Width:            int(f.Header.Width),
+		Height:           int(f.Header.Height),
+		Format:           format,
+		TransparentIndex: f.Header.TransparentIdx,
+	}
+
+	for _, c := range f.Palette {
+		sprite.Palette = append(sprite.Palette, color.NRGBAModel.Convert(c).(color.NRGBA))
+	}
+
+	for i := range f.frameCels {
+		frame := raw.Frame{}
+		if i < len(f.Durations) {
+			frame.Duration = f.Durations[i]
+		}
+
+		for _, c := range f.sortedCels(i) {
+			cel := raw.Cel{
+				Layer:   c.layerIndex,
+				X:       c.x,
+				Y:       c.y,
+				Opacity: c.opacity,
+				ZIndex:  c.zIndex,
+			}
+			switch {
+			case c.image != nil:
+				cel.Plane = rawPlane(c.image, c.indices, format)
+			case c.tilemap != nil:
+				cel.Tiles = rawTileGrid(c.tilemap)
+			}
+			frame.Cels = append(frame.Cels, cel)
+		}
+
+		sprite.Frames = append(sprite.Frames, frame)
+	}
+
+	if len(f.Tileset.Tiles) > 0 {
+		// The strip has a single format, RGBA unless every tile has its indices
+		stripFormat := format
+		if format == raw.FormatIndexed {
+			for i, tile := range f.Tileset.Tiles {
+				if i >= len(f.Tileset.indices) || !hasIndices(tile, f.Tileset.indices[i]) {
+					stripFormat = raw.FormatRGBA
+					break
+				}
+			}
+		}
+
+		tileset := raw.Tileset{
+			TileWidth:  f.Tileset.TileWidth,
+			TileHeight: f.Tileset.TileHeight,
+			Count:      len(f.Tileset.Tiles),
+			Strip:      &raw.Plane{Width: f.Tileset.TileWidth, Height: f.Tileset.TileHeight * len(f.Tileset.Tiles), Format: stripFormat},
+		}
+		for i, tile := range f.Tileset.Tiles {
+			var indices []byte
+			if i < len(f.Tileset.indices) {
+				indices = f.Tileset.indices[i]
+			}
+			tileset.Strip.Pix = append(tileset.Strip.Pix, rawPlane(tile, indices, stripFormat).Pix...)
+		}
+		sprite.Tilesets = append(sprite.Tilesets, tileset)
+	}
+
+	return sprite
+}
+
+// hasIndices checks if indices holds the palette index of every pixel of img
+func hasIndices(img image.Image, indices []byte) bool {
+	b := img.Bounds()
+	return len(indices) == b.Dx()*b.Dy()
+}
+
+// rawPlane converts an image (or its palette indices) to a plane of the given
+// format, RGBA for indexed images without their indices
+func rawPlane(img image.Image, indices []byte, format raw.Format) *raw.Plane {
+	if format == raw.FormatIndexed && !hasIndices(img, indices) {
+		format = raw.FormatRGBA
+	}
+
+	b := img.Bounds()
+	plane := &raw.Plane{
+		Width:  b.Dx(),
+		Height: b.Dy(),
+		Format: format,
+		Pix:    make([]byte, 0, b.Dx()*b.Dy()*format.BytesPerPixel()),
+	}
+
+	if format == raw.FormatIndexed {
+		plane.Pix = append(plane.Pix, indices...)
+		return plane
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if format == raw.FormatGrayscale {
+				plane.Pix = append(plane.Pix, c.R, c.A)
+			} else {
+				plane.Pix = append(plane.Pix, c.R, c.G, c.B, c.A)
+			}
+		}
+	}
+
+	return plane
+}
+
+// rawTileGrid packs the tiles of a tilemap into a tile grid
+func rawTileGrid(tilemap *ASETilemap) *raw.TileGrid {
+	grid := &raw.TileGrid{
+		Columns: tilemap.TilemapColumns,
+		Rows:    tilemap.TilemapRows,
+		Tiles:   make([]uint32, 0, tilemap.TilemapColumns*tilemap.TilemapRows),
+	}
+
+	for _, row := range tilemap.Tiles {
+		for _, tile := range row {
+			value := uint32(tile.ID) & raw.TileIDMask
+			if tile.XFlip {
+				value |= raw.TileXFlipMask
+			}
+			if tile.YFlip {
+				value |= raw.TileYFlipMask
+			}
+			if tile.DiagonalFlip {
+				value |= raw.TileDiagFlipMask
+			}
+			grid.Tiles = append(grid.Tiles, value)
+		}
+	}
+
+	return grid
+}
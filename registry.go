@@ -0,0 +1,45 @@
+package asevre
+
+import "sync"
+
+// ChunkHandler decodes the data of a chunk of a frame into a value kept in
+// ASEFile.CustomChunks. Errors skip the chunk like the errors of the built-in
+// chunks (or fail the parsing in strict mode).
+type ChunkHandler func(header Header, frame int, data []byte) (any, error)
+
+// CustomChunk is the value a ChunkHandler decoded from a chunk.
+type CustomChunk struct {
+	Frame int  // Frame holding the chunk
+	Type  WORD // Chunk type
+	Value any  // Value returned by the handler
+}
+
+var (
+	chunkHandlersMu sync.RWMutex
+	chunkHandlers   = map[WORD]ChunkHandler{}
+)
+
+// RegisterChunkHandler installs the handler of a chunk type, replacing the
+// previous one; a nil handler removes it. Handlers of types out of the
+// specification (custom or future chunks) keep the chunks from being skipped
+// as unknown. Handlers of types asevre already decodes get their chunks too.
+//
+// Handlers are called while files are parsed, on any goroutine: register them
+// before parsing, typically in an init function.
+func RegisterChunkHandler(chunkType WORD, handler ChunkHandler) {
+	chunkHandlersMu.Lock()
+	defer chunkHandlersMu.Unlock()
+	if handler == nil {
+		delete(chunkHandlers, chunkType)
+		return
+	}
+	chunkHandlers[chunkType] = handler
+}
+
+// chunkHandler returns the handler registered for a chunk type
+func chunkHandler(chunkType WORD) (ChunkHandler, bool) {
+	chunkHandlersMu.RLock()
+	defer chunkHandlersMu.RUnlock()
+	handler, exists := chunkHandlers[chunkType]
+	return handler, exists
+}
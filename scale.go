@@ -0,0 +1,138 @@
+package asevre
+
+import (
+	"image"
+)
+
+// scale enlarges the file n times with nearest-neighbor scaling: frames, cels
+// and tiles, and every position and size that refers to their pixels, so the
+// file reads as if it had been drawn that big.
+func (f *ASEFile) scale(n int) {
+	if n <= 1 {
+		return
+	}
+
+	// Linked cels and the tags share their images, scale each image once
+	type scaledImage struct {
+		image   image.Image
+		indices []byte
+	}
+	scaled := map[image.Image]scaledImage{}
+	scaleImage := func(img image.Image, indices []byte) (image.Image, []byte) {
+		if img == nil {
+			return nil, indices
+		}
+		if s, exists := scaled[img]; exists {
+			return s.image, s.indices
+		}
+		b := img.Bounds()
+		s := scaledImage{image: scaleNearest(img, n, n), indices: scaleIndices(indices, b.Dx(), b.Dy(), n)}
+		scaled[img] = s
+		return s.image, s.indices
+	}
+
+	f.Header.Width *= WORD(n)
+	f.Header.Height *= WORD(n)
+	f.Header.GridX *= SHORT(n)
+	f.Header.GridY *= SHORT(n)
+	f.Header.GridWidth *= WORD(n)
+	f.Header.GridHeight *= WORD(n)
+
+	for i, img := range f.Images {
+		var indices []byte
+		if i < len(f.Indices) {
+			indices = f.Indices[i]
+		}
+		img, indices = scaleImage(img, indices)
+		f.Images[i] = img
+		if i < len(f.Indices) {
+			f.Indices[i] = indices
+		}
+	}
+	for i := range f.TrimOffsets {
+		f.TrimOffsets[i] = f.TrimOffsets[i].Mul(n)
+	}
+
+	for i, tile := range f.Tileset.Tiles {
+		var indices []byte
+		if i < len(f.Tileset.indices) {
+			indices = f.Tileset.indices[i]
+		}
+		tile, indices = scaleImage(tile, indices)
+		f.Tileset.Tiles[i] = tile
+		if i < len(f.Tileset.indices) {
+			f.Tileset.indices[i] = indices
+		}
+	}
+	f.Tileset.TileWidth *= n
+	f.Tileset.TileHeight *= n
+
+	// The tilemaps of the cels and of f.Tilemaps share their rows
+	seenRows := map[*Tile]bool{}
+	scaleTilemap := func(tilemap *ASETilemap) {
+		for _, row := range tilemap.Tiles {
+			if len(row) == 0 || seenRows[&row[0]] {
+				continue
+			}
+			seenRows[&row[0]] = true
+			for col := range row {
+				tile := &row[col]
+				tile.Width *= n
+				tile.Height *= n
+				tile.X *= float64(n)
+				tile.Y *= float64(n)
+			}
+		}
+		f.Tileset.setTileImages(tilemap)
+	}
+	for i := range f.Tilemaps {
+		scaleTilemap(&f.Tilemaps[i])
+	}
+
+	for _, cels := range f.frameCels {
+		for i := range cels {
+			c := &cels[i]
+			c.x *= n
+			c.y *= n
+			c.image, c.indices = scaleImage(c.image, c.indices)
+			if c.tilemap != nil {
+				scaleTilemap(c.tilemap)
+			}
+			if c.bounds != nil {
+				bounds := CelBounds{X: c.bounds.X * float64(n), Y: c.bounds.Y * float64(n), Width: c.bounds.Width * float64(n), Height: c.bounds.Height * float64(n)}
+				c.bounds = &bounds
+			}
+		}
+	}
+
+	for i := range f.Slices {
+		for j := range f.Slices[i].Keys {
+			key := &f.Slices[i].Keys[j]
+			key.Bounds = image.Rectangle{Min: key.Bounds.Min.Mul(n), Max: key.Bounds.Max.Mul(n)}
+			key.Center = image.Rectangle{Min: key.Center.Min.Mul(n), Max: key.Center.Max.Mul(n)}
+			key.Pivot = key.Pivot.Mul(n)
+		}
+	}
+
+	for i := range f.Masks {
+		f.Masks[i] = f.Masks[i].scale(n)
+	}
+
+	f.Tileset.resetFlips()
+	f.refreshStates()
+}
+
+// scaleIndices scales a plane of palette indices n times, nil stays nil
+func scaleIndices(indices []byte, width, height, n int) []byte {
+	if indices == nil || len(indices) < width*height {
+		return nil
+	}
+	scaled := make([]byte, 0, width*height*n*n)
+	for y := 0; y < height*n; y++ {
+		row := indices[y/n*width : (y/n+1)*width]
+		for x := 0; x < width*n; x++ {
+			scaled = append(scaled, row[x/n])
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,253 @@
+package asevre
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"slices"
+)
+
+// Slice flags (0x2022 chunk)
+const (
+	SliceFlagNinePatch DWORD = 1 // It's a 9-patches slice
+	SliceFlagHasPivot  DWORD = 2 // Has pivot information
+)
+
+// SliceKey0x2022 is a slice key of the slice chunk, valid from its frame onward.
+type SliceKey0x2022 struct {
+	FrameNumber  DWORD // Frame number, this slice is valid from this frame to the end of the animation (4 bytes)
+	X, Y         LONG  // Slice X and Y origin coordinates in the sprite (8 bytes)
+	Width        DWORD // Slice width, can be 0 if this slice is hidden in the animation from the given frame (4 bytes)
+	Height       DWORD // Slice height (4 bytes)
+	CenterX      LONG  // Center X position (relative to slice bounds), only for 9-patches slices (4 bytes)
+	CenterY      LONG  // Center Y position (4 bytes)
+	CenterWidth  DWORD // Center width (4 bytes)
+	CenterHeight DWORD // Center height (4 bytes)
+	PivotX       LONG  // Pivot X position (relative to the slice origin), only if the slice has a pivot (4 bytes)
+	PivotY       LONG  // Pivot Y position (4 bytes)
+}
+
+// Chunk0x2022 represents a slice chunk
+type Chunk0x2022 struct {
+	NumberOfKeys DWORD            // Number of "slice keys" (4 bytes)
+	Flags        DWORD            // Flags (4 bytes)
+	Reserved     DWORD            // Reserved (4 bytes)
+	Name         STRING           // Name (variable length)
+	Keys         []SliceKey0x2022 // Slice keys
+}
+
+// parseChunk0x2022 parses the slice chunk
+func parseChunk0x2022(data []byte) (*Chunk0x2022, error) {
+	r := bytes.NewReader(data)
+
+	chunk := &Chunk0x2022{}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.NumberOfKeys); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Flags); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Reserved); err != nil {
+		return nil, err
+	}
+	name, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	chunk.Name = name
+
+	for i := DWORD(0); i < chunk.NumberOfKeys; i++ {
+		key := SliceKey0x2022{}
+		if err := binary.Read(r, binary.LittleEndian, &key.FrameNumber); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &key.X); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &key.Y); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &key.Width); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &key.Height); err != nil {
+			return nil, err
+		}
+		if chunk.Flags&SliceFlagNinePatch != 0 {
+			if err := binary.Read(r, binary.LittleEndian, &key.CenterX); err != nil {
+				return nil, err
+			}
+			if err := binary.Read(r, binary.LittleEndian, &key.CenterY); err != nil {
+				return nil, err
+			}
+			if err := binary.Read(r, binary.LittleEndian, &key.CenterWidth); err != nil {
+				return nil, err
+			}
+			if err := binary.Read(r, binary.LittleEndian, &key.CenterHeight); err != nil {
+				return nil, err
+			}
+		}
+		if chunk.Flags&SliceFlagHasPivot != 0 {
+			if err := binary.Read(r, binary.LittleEndian, &key.PivotX); err != nil {
+				return nil, err
+			}
+			if err := binary.Read(r, binary.LittleEndian, &key.PivotY); err != nil {
+				return nil, err
+			}
+		}
+		chunk.Keys = append(chunk.Keys, key)
+	}
+
+	return chunk, nil
+}
+
+// SliceKey is the shape of a slice from a frame onward.
+type SliceKey struct {
+	Frame  int             // First frame using this key
+	Bounds image.Rectangle // Slice bounds in the sprite, empty if the slice is hidden from this frame
+	Center image.Rectangle // Center of a 9-patches slice, relative to the slice bounds
+	Pivot  image.Point     // Pivot point, relative to the slice origin
+}
+
+// ASESlice represents a slice of the sprite (hitboxes, anchors, 9-patches, ...).
+type ASESlice struct {
+	Name     string
+	Flags    DWORD      // SliceFlagNinePatch and SliceFlagHasPivot
+	Keys     []SliceKey // Keys ordered by frame
+	UserData *UserData  // Slice user data, nil if not set
+}
+
+// IsNinePatch checks if the slice has a center (9-patches slice)
+func (s ASESlice) IsNinePatch() bool {
+	return s.Flags&SliceFlagNinePatch != 0
+}
+
+// HasPivot checks if the slice has a pivot point
+func (s ASESlice) HasPivot() bool {
+	return s.Flags&SliceFlagHasPivot != 0
+}
+
+// KeyAt returns the key of the slice used in the given frame. It returns false
+// if the slice starts after the frame.
+func (s ASESlice) KeyAt(frame int) (SliceKey, bool) {
+	var key SliceKey
+	found := false
+	for _, k := range s.Keys {
+		if k.Frame > frame {
+			break
+		}
+		key, found = k, true
+	}
+	return key, found
+}
+
+// newASESlice converts the slice chunk to a slice
+func newASESlice(chunk *Chunk0x2022) ASESlice {
+	slice := ASESlice{
+		Name:  string(chunk.Name.Chars),
+		Flags: chunk.Flags,
+	}
+	for _, k := range chunk.Keys {
+		key := SliceKey{
+			Frame:  int(k.FrameNumber),
+			Bounds: image.Rect(int(k.X), int(k.Y), int(k.X)+int(k.Width), int(k.Y)+int(k.Height)),
+			Pivot:  image.Pt(int(k.PivotX), int(k.PivotY)),
+		}
+		if chunk.Flags&SliceFlagNinePatch != 0 {
+			key.Center = image.Rect(int(k.CenterX), int(k.CenterY), int(k.CenterX)+int(k.CenterWidth), int(k.CenterY)+int(k.CenterHeight))
+		}
+		slice.Keys = append(slice.Keys, key)
+	}
+	return slice
+}
+
+// Hitboxes returns the bounds of the slices visible in a frame, keyed by slice
+// name ("hit", "hurt", ...). Several slices may share a name. The bounds are in
+// frame coordinates, so they are drawn over the frame image as they are: the
+// canvas for canvas-sized frames, moved by the trim offset of trimmed frames
+// (WithTrim). With names, only these slices are returned.
+func (f *ASEFile) Hitboxes(frame int, names ...string) map[string][]image.Rectangle {
+	hitboxes := map[string][]image.Rectangle{}
+	for _, slice := range f.Slices {
+		if len(names) > 0 && !slices.Contains(names, slice.Name) {
+			continue
+		}
+		key, ok := slice.KeyAt(frame)
+		if !ok || key.Bounds.Empty() {
+			continue
+		}
+		hitboxes[slice.Name] = append(hitboxes[slice.Name], key.Bounds.Sub(f.trimOffset(frame)))
+	}
+	return hitboxes
+}
+
+// TagHitboxes returns the hitboxes of every frame of a tag, in frame order.
+func (f *ASEFile) TagHitboxes(tag ASETag, names ...string) []map[string][]image.Rectangle {
+	var hitboxes []map[string][]image.Rectangle
+	for frame := tag.FromFrame; frame <= tag.ToFrame; frame++ {
+		hitboxes = append(hitboxes, f.Hitboxes(frame, names...))
+	}
+	return hitboxes
+}
+
+// Anchor returns the pivot of a slice in a frame, in frame coordinates (see
+// Hitboxes). Draw the frame translated by minus the anchor to keep the same
+// point of the sprite at the same place while its silhouette changes
+// (attacks, jumps). It returns false if the slice has no pivot or isn't in
+// the frame.
+func (f *ASEFile) Anchor(frame int, sliceName string) (image.Point, bool) {
+	for _, slice := range f.Slices {
+		if slice.Name != sliceName || !slice.HasPivot() {
+			continue
+		}
+		if key, ok := slice.KeyAt(frame); ok {
+			return key.Bounds.Min.Add(key.Pivot).Sub(f.trimOffset(frame)), true
+		}
+	}
+	return image.Point{}, false
+}
+
+// Anchors returns the anchor of a slice in every frame, see Anchor. Frames
+// before the first key of the slice use the anchor of that key.
+func (f *ASEFile) Anchors(sliceName string) []image.Point {
+	anchors := make([]image.Point, len(f.Images))
+	found := false
+	for frame := range anchors {
+		anchor, ok := f.Anchor(frame, sliceName)
+		if !ok {
+			continue
+		}
+		if !found {
+			for i := range frame {
+				anchors[i] = anchor
+			}
+			found = true
+		}
+		anchors[frame] = anchor
+	}
+	return anchors
+}
+
+// SliceFrames returns the animation of a slice: the region of the slice cut
+// out of every frame, so one file can hold several sprites. Frames where the
+// slice is missing are nil. It returns nil if there is no slice with that name.
+func (f *ASEFile) SliceFrames(name string) []image.Image {
+	i := slices.IndexFunc(f.Slices, func(s ASESlice) bool { return s.Name == name })
+	if i < 0 {
+		return nil
+	}
+
+	frames := make([]image.Image, len(f.Images))
+	for frame, img := range f.Images {
+		key, ok := f.Slices[i].KeyAt(frame)
+		if !ok || img == nil || key.Bounds.Empty() {
+			continue
+		}
+		// Trimmed frames only hold the part of the canvas at their trim offset
+		cropped := image.NewNRGBA(image.Rect(0, 0, key.Bounds.Dx(), key.Bounds.Dy()))
+		draw.Draw(cropped, cropped.Rect, img, key.Bounds.Min.Sub(f.trimOffset(frame)), draw.Src)
+		frames[frame] = cropped
+	}
+	return frames
+}
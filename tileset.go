@@ -0,0 +1,34 @@
+package asevre
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Image returns the tiles of the tileset as one image, a vertical strip like
+// the one Aseprite exports, with the place of every tile in it.
+func (t *ASETileset) Image() (*image.NRGBA, []image.Rectangle) {
+	return t.Grid(1)
+}
+
+// Grid returns the tiles of the tileset as one image of rows of columns tiles,
+// tile 0 first, with the place of every tile in it. Columns below 1 make a
+// single row.
+func (t *ASETileset) Grid(columns int) (*image.NRGBA, []image.Rectangle) {
+	if columns < 1 {
+		columns = max(len(t.Tiles), 1)
+	}
+	rows := (len(t.Tiles) + columns - 1) / columns
+	columns = min(columns, max(len(t.Tiles), 1))
+
+	img := image.NewNRGBA(image.Rect(0, 0, columns*t.TileWidth, rows*t.TileHeight))
+	rects := make([]image.Rectangle, len(t.Tiles))
+	for i, tile := range t.Tiles {
+		at := image.Pt(i%columns*t.TileWidth, i/columns*t.TileHeight)
+		rects[i] = image.Rectangle{Min: at, Max: at.Add(image.Pt(t.TileWidth, t.TileHeight))}
+		if tile != nil {
+			draw.Draw(img, rects[i], tile, tile.Bounds().Min, draw.Src)
+		}
+	}
+	return img, rects
+}
@@ -0,0 +1,72 @@
+package asevre
+
+import "strings"
+
+// Trigger is an event attached to a frame in Aseprite through the user data
+// text of a cel: every line "kind:value" of the text is a trigger, like
+// "sfx:step" or "vfx:dust", so art can start sounds and effects as it plays.
+// Lines without a colon are left out, the text can keep notes.
+type Trigger struct {
+	Frame int    // Frame of the cel
+	Layer int    // Layer index of the cel
+	Kind  string // Text before the first colon, "sfx" in "sfx:step"
+	Value string // Text after the first colon, "step" in "sfx:step"
+}
+
+// parseTriggers reads the triggers of the user data text of a cel
+func parseTriggers(frame, layer int, text string) []Trigger {
+	var triggers []Trigger
+	for _, line := range strings.Split(text, "\n") {
+		kind, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		kind = strings.TrimSpace(kind)
+		if !found || kind == "" {
+			continue
+		}
+		triggers = append(triggers, Trigger{Frame: frame, Layer: layer, Kind: kind, Value: strings.TrimSpace(value)})
+	}
+	return triggers
+}
+
+// Triggers returns the triggers of a frame, read from the user data of its
+// cels in drawing order.
+func (f *ASEFile) Triggers(frame int) []Trigger {
+	if frame < 0 || frame >= len(f.frameCels) {
+		return nil
+	}
+	var triggers []Trigger
+	for _, c := range f.sortedCels(frame) {
+		if c.userData != nil && c.userData.Text != "" {
+			triggers = append(triggers, parseTriggers(frame, c.layerIndex, c.userData.Text)...)
+		}
+	}
+	return triggers
+}
+
+// tagTriggers returns the triggers of every frame of a tag, nil if it has none
+func (f *ASEFile) tagTriggers(tag ASETag) [][]Trigger {
+	var triggers [][]Trigger
+	for frame := tag.FromFrame; frame <= tag.ToFrame; frame++ {
+		if frameTriggers := f.Triggers(frame); frameTriggers != nil {
+			if triggers == nil {
+				triggers = make([][]Trigger, tag.ToFrame-tag.FromFrame+1)
+			}
+			triggers[frame-tag.FromFrame] = frameTriggers
+		}
+	}
+	return triggers
+}
+
+// fireTriggers calls OnTrigger with the triggers of the current frame. Without
+// OnTrigger they are left to fire once it is set, on Play.
+func (a *Animation) fireTriggers() {
+	if a.OnTrigger == nil {
+		return
+	}
+	a.fired = true
+	if a.Index < 0 || a.Index >= len(a.Triggers) {
+		return
+	}
+	for _, trigger := range a.Triggers[a.Index] {
+		a.OnTrigger(trigger)
+	}
+}
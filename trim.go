@@ -0,0 +1,74 @@
+package asevre
+
+import (
+	"image"
+	"image/draw"
+)
+
+// OpaqueBounds returns the smallest rectangle holding the pixels of an image
+// that aren't fully transparent, an empty rectangle if there are none.
+func OpaqueBounds(img image.Image) image.Rectangle {
+	b := img.Bounds()
+	alpha := func(x, y int) bool {
+		_, _, _, a := img.At(x, y).RGBA()
+		return a != 0
+	}
+	// Read the alpha byte directly from the usual image types
+	switch img := img.(type) {
+	case *image.RGBA:
+		alpha = func(x, y int) bool { return img.Pix[img.PixOffset(x, y)+3] != 0 }
+	case *image.NRGBA:
+		alpha = func(x, y int) bool { return img.Pix[img.PixOffset(x, y)+3] != 0 }
+	}
+
+	bounds := image.Rectangle{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if alpha(x, y) {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return bounds
+}
+
+// trim cuts the transparent borders of the frames, recording where they were
+// in the canvas. Fully transparent frames become a single transparent pixel.
+func (f *ASEFile) trim() {
+	f.TrimOffsets = make([]image.Point, len(f.Images))
+	for i, img := range f.Images {
+		if img == nil {
+			continue
+		}
+		b := img.Bounds()
+		r := OpaqueBounds(img)
+		if r.Empty() {
+			r = image.Rectangle{Min: b.Min, Max: b.Min.Add(image.Pt(1, 1))}
+		}
+
+		trimmed := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+		draw.Draw(trimmed, trimmed.Rect, img, r.Min, draw.Src)
+		f.Images[i] = trimmed
+		f.TrimOffsets[i] = r.Min.Sub(b.Min)
+
+		// The palette indices are cut the same way
+		if i < len(f.Indices) {
+			indices := make([]byte, 0, r.Dx()*r.Dy())
+			for y := r.Min.Y - b.Min.Y; y < r.Max.Y-b.Min.Y; y++ {
+				row := y*b.Dx() + r.Min.X - b.Min.X
+				indices = append(indices, f.Indices[i][row:row+r.Dx()]...)
+			}
+			f.Indices[i] = indices
+		}
+	}
+	f.refreshStates()
+}
+
+// trimOffset returns the position in the canvas of the image of a frame, the
+// origin for frames that aren't trimmed
+func (f *ASEFile) trimOffset(frame int) image.Point {
+	if frame < 0 || frame >= len(f.TrimOffsets) {
+		return image.Point{}
+	}
+	return f.TrimOffsets[frame]
+}
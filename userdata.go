@@ -0,0 +1,345 @@
+package asevre
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// User data flags (0x2020 chunk)
+const (
+	UserDataHasText       DWORD = 1 // Has text
+	UserDataHasColor      DWORD = 2 // Has color
+	UserDataHasProperties DWORD = 4 // Has properties
+)
+
+// Property value types of the user data properties maps
+const (
+	PropertyBool       WORD = 0x0001
+	PropertyInt8       WORD = 0x0002
+	PropertyUint8      WORD = 0x0003
+	PropertyInt16      WORD = 0x0004
+	PropertyUint16     WORD = 0x0005
+	PropertyInt32      WORD = 0x0006
+	PropertyUint32     WORD = 0x0007
+	PropertyInt64      WORD = 0x0008
+	PropertyUint64     WORD = 0x0009
+	PropertyFixed      WORD = 0x000A
+	PropertyFloat      WORD = 0x000B
+	PropertyDouble     WORD = 0x000C
+	PropertyString     WORD = 0x000D
+	PropertyPoint      WORD = 0x000E
+	PropertySize       WORD = 0x000F
+	PropertyRect       WORD = 0x0010
+	PropertyVector     WORD = 0x0011
+	PropertyProperties WORD = 0x0012
+	PropertyUUID       WORD = 0x0013
+)
+
+// LoopStartMarker is the cel user data text marking the first frame of the
+// looping section of a tag. Frames before it are played only once (intro).
+const LoopStartMarker = "loopstart"
+
+// Chunk0x2020 represents the user data chunk. It is attached to the entity
+// (layer, cel, tag, ...) read right before it.
+type Chunk0x2020 struct {
+	Flags      DWORD                    // Flags (4 bytes)
+	Text       STRING                   // Text, if flags has bit 1 (variable length)
+	Color      [4]BYTE                  // Color (RGBA), if flags has bit 2 (4 bytes)
+	Properties map[DWORD]map[string]any // Properties maps by key, if flags has bit 4 (variable length)
+}
+
+// GetText returns the user data text
+func (c *Chunk0x2020) GetText() string {
+	return string(c.Text.Chars)
+}
+
+// GetColor returns the user data color, if any
+func (c *Chunk0x2020) GetColor() (color.Color, bool) {
+	if c.Flags&UserDataHasColor == 0 {
+		return nil, false
+	}
+	return color.NRGBA{R: c.Color[0], G: c.Color[1], B: c.Color[2], A: c.Color[3]}, true
+}
+
+// UserData is the user data Aseprite attaches to the sprite, layers, cels,
+// tags, tilesets and slices.
+//
+// Property values use the Go type matching their Aseprite type: bool, int8,
+// uint8, int16, uint16, int32, uint32, int64, uint64, float32, float64 (fixed
+// and double), string, image.Point (point and size), image.Rectangle, []any
+// (vector), map[string]any (nested properties) and UUID.
+type UserData struct {
+	Text       string                    // Text
+	Color      color.Color               // Color, nil if not set
+	Properties map[string]any            // User properties
+	Extensions map[uint32]map[string]any // Properties of the extensions, by extension entry ID
+}
+
+// Property returns the value of a user property
+func (u *UserData) Property(name string) (any, bool) {
+	if u == nil {
+		return nil, false
+	}
+	value, ok := u.Properties[name]
+	return value, ok
+}
+
+// newUserData converts the user data chunk to its public form
+func newUserData(chunk *Chunk0x2020) *UserData {
+	userData := &UserData{Text: chunk.GetText()}
+	if c, ok := chunk.GetColor(); ok {
+		userData.Color = c
+	}
+	for key, properties := range chunk.Properties {
+		if key == 0 {
+			userData.Properties = properties
+			continue
+		}
+		if userData.Extensions == nil {
+			userData.Extensions = map[uint32]map[string]any{}
+		}
+		userData.Extensions[uint32(key)] = properties
+	}
+	return userData
+}
+
+// parseChunk0x2020 parses the user data chunk
+func parseChunk0x2020(data []byte) (*Chunk0x2020, error) {
+	r := bytes.NewReader(data)
+
+	chunk := &Chunk0x2020{}
+	if err := binary.Read(r, binary.LittleEndian, &chunk.Flags); err != nil {
+		return nil, err
+	}
+	if chunk.Flags&UserDataHasText != 0 {
+		text, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		chunk.Text = text
+	}
+	if chunk.Flags&UserDataHasColor != 0 {
+		if err := binary.Read(r, binary.LittleEndian, &chunk.Color); err != nil {
+			return nil, err
+		}
+	}
+	if chunk.Flags&UserDataHasProperties != 0 {
+		var size, numMaps DWORD
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &numMaps); err != nil {
+			return nil, err
+		}
+
+		chunk.Properties = map[DWORD]map[string]any{}
+		for i := DWORD(0); i < numMaps; i++ {
+			var key DWORD
+			if err := binary.Read(r, binary.LittleEndian, &key); err != nil {
+				return nil, err
+			}
+			properties, err := readProperties(r)
+			if err != nil {
+				return nil, fmt.Errorf("properties map %d: %v", key, err)
+			}
+			chunk.Properties[key] = properties
+		}
+	}
+
+	return chunk, nil
+}
+
+// readString reads a STRING (length + UTF-8 characters)
+func readString(r io.Reader) (STRING, error) {
+	var s STRING
+	if err := binary.Read(r, binary.LittleEndian, &s.Length); err != nil {
+		return s, err
+	}
+	s.Chars = make([]BYTE, s.Length)
+	if err := binary.Read(r, binary.LittleEndian, &s.Chars); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// readProperties reads the number of properties followed by every name, type and value
+func readProperties(r io.Reader) (map[string]any, error) {
+	var numProperties DWORD
+	if err := binary.Read(r, binary.LittleEndian, &numProperties); err != nil {
+		return nil, err
+	}
+
+	properties := make(map[string]any, min(numProperties, 64))
+	for i := DWORD(0); i < numProperties; i++ {
+		name, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		var valueType WORD
+		if err := binary.Read(r, binary.LittleEndian, &valueType); err != nil {
+			return nil, err
+		}
+		value, err := readPropertyValue(r, valueType)
+		if err != nil {
+			return nil, fmt.Errorf("property %q: %v", name.Chars, err)
+		}
+		properties[string(name.Chars)] = value
+	}
+
+	return properties, nil
+}
+
+// readPropertyValue reads a property value of the given type
+func readPropertyValue(r io.Reader, valueType WORD) (any, error) {
+	switch valueType {
+	case PropertyBool:
+		var v BYTE
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v != 0, err
+	case PropertyInt8:
+		var v int8
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case PropertyUint8:
+		var v uint8
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case PropertyInt16:
+		var v int16
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case PropertyUint16:
+		var v uint16
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case PropertyInt32:
+		var v int32
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case PropertyUint32:
+		var v uint32
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case PropertyInt64:
+		var v int64
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case PropertyUint64:
+		var v uint64
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case PropertyFixed:
+		var v FIXED
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return fixedToFloat(v), err
+	case PropertyFloat:
+		var v uint32
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return math.Float32frombits(v), err
+	case PropertyDouble:
+		var v uint64
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return math.Float64frombits(v), err
+	case PropertyString:
+		s, err := readString(r)
+		return string(s.Chars), err
+	case PropertyPoint, PropertySize:
+		var v [2]int32
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return image.Pt(int(v[0]), int(v[1])), err
+	case PropertyRect:
+		// Origin and size
+		var v [4]int32
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return image.Rect(int(v[0]), int(v[1]), int(v[0]+v[2]), int(v[1]+v[3])), err
+	case PropertyVector:
+		var numElements DWORD
+		var elementType WORD
+		if err := binary.Read(r, binary.LittleEndian, &numElements); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &elementType); err != nil {
+			return nil, err
+		}
+		// The count is not trusted, the slice grows with the elements actually read
+		elements := make([]any, 0, min(numElements, 64))
+		for i := DWORD(0); i < numElements; i++ {
+			// Type 0 means every element has its own type
+			t := elementType
+			if t == 0 {
+				if err := binary.Read(r, binary.LittleEndian, &t); err != nil {
+					return nil, err
+				}
+			}
+			element, err := readPropertyValue(r, t)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		return elements, nil
+	case PropertyProperties:
+		return readProperties(r)
+	case PropertyUUID:
+		var v UUID
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	}
+	return nil, fmt.Errorf("unknown property type 0x%04x", valueType)
+}
+
+// TileProperties returns the user data as tile properties: every user property
+// with its value as text, and every word of the text (separated by spaces or
+// commas) as a tag. "key:value" and "key=value" words set the key to the
+// value, other words are set to "true".
+func (u *UserData) TileProperties() map[string]string {
+	if u == nil {
+		return nil
+	}
+	properties := map[string]string{}
+	for name, value := range u.Properties {
+		properties[name] = fmt.Sprint(value)
+	}
+	words := strings.FieldsFunc(u.Text, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	for _, word := range words {
+		if key, value, found := strings.Cut(word, ":"); found {
+			properties[key] = value
+		} else if key, value, found := strings.Cut(word, "="); found {
+			properties[key] = value
+		} else {
+			properties[word] = "true"
+		}
+	}
+	if len(properties) == 0 {
+		return nil
+	}
+	return properties
+}
+
+// userDataTarget is the kind of entity a user data chunk is attached to
+type userDataTarget int
+
+const (
+	userDataNone userDataTarget = iota
+	userDataSprite
+	userDataLayer
+	userDataCel
+	userDataTags
+	userDataTileset
+	userDataTile
+	userDataSlice
+)
+
+// isLoopStart checks if the user data marks the start of a loop section
+func isLoopStart(userData *UserData) bool {
+	return userData != nil && strings.EqualFold(strings.TrimSpace(userData.Text), LoopStartMarker)
+}
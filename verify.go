@@ -0,0 +1,196 @@
+package asevre
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// VerifyReport describes the structure of a file checked by Verify and every
+// problem found in it.
+type VerifyReport struct {
+	FileSize     int64 // Size of the file
+	DeclaredSize int64 // File size of the header
+	FrameCount   int   // Frames declared by the header
+	Frames       []FrameReport
+	Problems     []error // Problems found, chunk problems as *ChunkError
+}
+
+// FrameReport describes a frame of a file checked by Verify.
+type FrameReport struct {
+	Offset         int64 // Offset of the frame header in the file
+	Bytes          int64 // Bytes of the frame header and the chunks read
+	DeclaredBytes  int64 // Bytes in frame of the frame header
+	Chunks         int   // Chunks read
+	DeclaredChunks int   // Number of chunks of the frame header
+	Streams        int   // Zlib streams checked (compressed cels, tilesets)
+}
+
+// OK checks if the file has no problems
+func (r VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Verify checks the structure of an .aseprite file without decoding it:
+// magic numbers, the declared file size, the bytes and chunks of every frame,
+// and the integrity (checksum and size) of every zlib stream. It only fails
+// when the file can't be read; problems are listed in the report, so it fits
+// asset validation in CI.
+func Verify(path string) (VerifyReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	return VerifyBytes(data), nil
+}
+
+// VerifyBytes checks the structure of the data of an .aseprite file, see Verify.
+func VerifyBytes(data []byte) VerifyReport {
+	report := VerifyReport{FileSize: int64(len(data))}
+	r := bytes.NewReader(data)
+	offset := func() int64 { return report.FileSize - int64(r.Len()) }
+
+	var header Header
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		report.Problems = append(report.Problems, fmt.Errorf("header: %w: %v", ErrTruncatedChunk, err))
+		return report
+	}
+	report.DeclaredSize = int64(header.FileSize)
+	report.FrameCount = int(header.FrameCount)
+	if header.MagicNumberHeader != 0xA5E0 {
+		report.Problems = append(report.Problems, fmt.Errorf("%w: header 0x%04x", ErrBadMagic, header.MagicNumberHeader))
+	}
+	if report.DeclaredSize != report.FileSize {
+		report.Problems = append(report.Problems, fmt.Errorf("file size mismatch: declared %d, got %d", report.DeclaredSize, report.FileSize))
+	}
+
+	for i := 0; i < report.FrameCount; i++ {
+		frame := FrameReport{Offset: offset()}
+		var frameHeader FrameHeader
+		if err := binary.Read(r, binary.LittleEndian, &frameHeader); err != nil {
+			report.Problems = append(report.Problems, fmt.Errorf("frame %d: %w: %v", i, ErrTruncatedChunk, err))
+			return report
+		}
+		if frameHeader.MagicNumber != 0xF1FA {
+			// The frames can't be found past a broken one
+			report.Problems = append(report.Problems, fmt.Errorf("%w: frame %d 0x%04x", ErrBadMagic, i, frameHeader.MagicNumber))
+			report.Frames = append(report.Frames, frame)
+			return report
+		}
+		frame.DeclaredBytes = int64(frameHeader.BytesInFrame)
+		frame.DeclaredChunks = int(frameHeader.NumberOfChunks())
+
+		truncated := false
+		for j := 0; j < frame.DeclaredChunks; j++ {
+			chunk := Chunk{Offset: offset()}
+			if err := binary.Read(r, binary.LittleEndian, &chunk.ChunkSize); err != nil {
+				report.Problems = append(report.Problems, newChunkError(i, chunk, err))
+				truncated = true
+				break
+			}
+			if err := binary.Read(r, binary.LittleEndian, &chunk.ChunkType); err != nil {
+				report.Problems = append(report.Problems, newChunkError(i, chunk, err))
+				truncated = true
+				break
+			}
+			if !chunk.IsValid() {
+				report.Problems = append(report.Problems, newChunkError(i, chunk, fmt.Errorf("invalid chunk detected: size %d", chunk.ChunkSize)))
+				truncated = true
+				break
+			}
+			if int64(chunk.ChunkSize-6) > int64(r.Len()) {
+				report.Problems = append(report.Problems, newChunkError(i, chunk, fmt.Errorf("%w: size %d, %d bytes left", ErrTruncatedChunk, chunk.ChunkSize, r.Len())))
+				truncated = true
+				break
+			}
+			chunk.ChunkData = make([]BYTE, chunk.ChunkSize-6)
+			_, _ = io.ReadFull(r, chunk.ChunkData)
+			frame.Chunks++
+
+			streams, err := verifyStreams(header, chunk)
+			frame.Streams += streams
+			if err != nil {
+				report.Problems = append(report.Problems, newChunkError(i, chunk, err))
+			}
+		}
+		frame.Bytes = offset() - frame.Offset
+		report.Frames = append(report.Frames, frame)
+		if truncated {
+			return report
+		}
+
+		if frame.Chunks != frame.DeclaredChunks {
+			report.Problems = append(report.Problems, fmt.Errorf("frame %d: chunk count mismatch: declared %d, got %d", i, frame.DeclaredChunks, frame.Chunks))
+		}
+		if frame.Bytes != frame.DeclaredBytes {
+			report.Problems = append(report.Problems, fmt.Errorf("frame %d: frame size mismatch: expected %d, got %d", i, frame.DeclaredBytes, frame.Bytes))
+		}
+	}
+
+	if r.Len() > 0 {
+		report.Problems = append(report.Problems, fmt.Errorf("%d bytes left non-parsed", r.Len()))
+	}
+	return report
+}
+
+// verifyStreams checks the zlib streams of a chunk, returning how many it has
+func verifyStreams(header Header, chunk Chunk) (int, error) {
+	bytesPerPixel := int(header.ColorDepth) / 8
+
+	switch chunk.ChunkType {
+	case 0x2005:
+		cel, err := parseChunk0x2005(chunk.ChunkData)
+		if err != nil {
+			return 0, err
+		}
+		switch cel.CelType {
+		case CompressedImageData:
+			if len(cel.Data) < 4 {
+				return 0, fmt.Errorf("%w: compressed image cel", ErrTruncatedChunk)
+			}
+			width := int(binary.LittleEndian.Uint16(cel.Data))
+			height := int(binary.LittleEndian.Uint16(cel.Data[2:]))
+			return 1, verifyZlib(cel.Data[4:], width*height*bytesPerPixel)
+		case CompressedTilemapData:
+			if len(cel.Data) < 32 {
+				return 0, fmt.Errorf("%w: compressed tilemap cel", ErrTruncatedChunk)
+			}
+			width := int(binary.LittleEndian.Uint16(cel.Data))
+			height := int(binary.LittleEndian.Uint16(cel.Data[2:]))
+			bitsPerTile := int(binary.LittleEndian.Uint16(cel.Data[4:]))
+			return 1, verifyZlib(cel.Data[32:], width*height*bitsPerTile/8)
+		}
+	case 0x2023:
+		tileset, err := parseChunk0x2023(chunk.ChunkData)
+		if err != nil {
+			return 0, err
+		}
+		if tileset.GetTilesetFlags().IncludeTilesInsideFile {
+			size := int(tileset.NumberOfTiles) * int(tileset.TileWidth) * int(tileset.TileHeight) * bytesPerPixel
+			return 1, verifyZlib(tileset.CompressedTilesetImage, size)
+		}
+	}
+	return 0, nil
+}
+
+// verifyZlib reads a whole zlib stream, which checks its checksum, and
+// compares its size to the expected one
+func verifyZlib(data []byte, size int) error {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("zlib stream: %w", err)
+	}
+	defer r.Close()
+
+	n, err := io.Copy(io.Discard, io.LimitReader(r, int64(size)+1))
+	if err != nil {
+		return fmt.Errorf("zlib stream: %w", err)
+	}
+	if n != int64(size) {
+		return fmt.Errorf("zlib stream size mismatch: expected %d bytes, got %d", size, n)
+	}
+	return nil
+}
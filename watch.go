@@ -0,0 +1,103 @@
+package asevre
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay waits for the editor to finish writing before the file is parsed again
+const reloadDelay = 100 * time.Millisecond
+
+// Watcher reloads an .aseprite file every time it changes on disk.
+type Watcher struct {
+	path     string
+	onReload func(ASEFile)
+	opts     []ParseOption
+	watcher  *fsnotify.Watcher
+
+	mu  sync.Mutex
+	err error // Last error while reloading
+}
+
+// Watch calls onReload with the parsed file every time the file at path is
+// saved, so sprites can be live-reloaded while they are edited in Aseprite.
+// onReload runs on the watcher goroutine: hand the file over to the game loop
+// (e.g. through a channel) rather than touching game state from it.
+//
+// Saves that can't be parsed are skipped, see Err.
+func Watch(path string, onReload func(ASEFile), opts ...ParseOption) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// Editors often replace the file instead of writing it, watch its directory
+	if err := fsWatcher.Add(filepath.Dir(path)); err != nil {
+		fsWatcher.Close()
+		return nil, err
+	}
+
+	w := &Watcher{
+		path:     filepath.Clean(path),
+		onReload: onReload,
+		opts:     opts,
+		watcher:  fsWatcher,
+	}
+	go w.run()
+	return w, nil
+}
+
+// run waits for the changes of the file and reloads it once they settle down
+func (w *Watcher) run() {
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				timer.Stop()
+				return
+			}
+			if filepath.Clean(event.Name) != w.path || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			timer.Reset(reloadDelay)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				timer.Stop()
+				return
+			}
+			w.setErr(err)
+
+		case <-timer.C:
+			file, err := LoadAseprite(w.path, w.opts...)
+			w.setErr(err)
+			if err == nil {
+				w.onReload(file)
+			}
+		}
+	}
+}
+
+// setErr records the result of the last reload
+func (w *Watcher) setErr(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.err = err
+}
+
+// Err returns the error of the last reload, nil if it succeeded.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close stops watching the file.
+func (w *Watcher) Close() error {
+	return w.watcher.Close()
+}